@@ -1,16 +1,29 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/cache"
 	"github.com/EngSteven/pso-http-server/internal/handlers"
 	"github.com/EngSteven/pso-http-server/internal/jobs"
 	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/tracing"
+	"github.com/EngSteven/pso-http-server/internal/util"
 	"github.com/EngSteven/pso-http-server/internal/workers"
 )
 
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
 func getenvInt(key string, def int) int {
 	if v := os.Getenv(key); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
@@ -20,12 +33,84 @@ func getenvInt(key string, def int) int {
 	return def
 }
 
+func getenvFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// openJobStore abre el almacenamiento de jobs elegido con JOB_STORE:
+// journal (por defecto), segmented o memory.
+func openJobStore() (jobs.JobStore, error) {
+	switch os.Getenv("JOB_STORE") {
+	case "", "journal":
+		return jobs.OpenJournalStore(getenv("JOB_JOURNAL_FILE", "data/jobs_journal.jsonl"))
+	case "segmented":
+		segBytes := int64(getenvInt("JOB_STORE_SEGMENT_MB", 16)) * 1024 * 1024
+		return jobs.OpenSegmentedStore(getenv("JOB_STORE_DIR", "data/jobs_store"), segBytes)
+	case "memory":
+		return jobs.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("JOB_STORE desconocido: %q", os.Getenv("JOB_STORE"))
+	}
+}
+
+// setupLogging configura el logger estructurado y el access log a partir del entorno.
+func setupLogging() {
+	util.SetLogLevel(os.Getenv("LOG_LEVEL"))
+
+	maxBytes := int64(getenvInt("LOG_MAX_SIZE_MB", 100)) * 1024 * 1024
+	interval := getenvDuration("LOG_ROTATE_INTERVAL", 24*time.Hour)
+	backups := getenvInt("LOG_MAX_BACKUPS", 7)
+	maxAge := getenvDuration("LOG_MAX_AGE", 7*24*time.Hour)
+
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		f, err := util.OpenRotatingFile(path, maxBytes, interval, backups, maxAge)
+		if err != nil {
+			util.Error("failed to open log file", util.Fields{"path": path, "error": err.Error()})
+			os.Exit(1)
+		}
+		util.SetOutput(f)
+	}
+
+	var accessOut io.Writer
+	if path := os.Getenv("ACCESS_LOG_FILE"); path != "" {
+		f, err := util.OpenRotatingFile(path, maxBytes, interval, backups, maxAge)
+		if err != nil {
+			util.Error("failed to open access log file", util.Fields{"path": path, "error": err.Error()})
+			os.Exit(1)
+		}
+		accessOut = f
+	}
+	util.ConfigureAccessLog(os.Getenv("ACCESS_LOG_FORMAT"), accessOut)
+}
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	setupLogging()
+
+	// MODE=worker: ejecuta jobs de otro servidor en lugar de aceptarlos
+	if getenv("MODE", "server") == "worker" {
+		runWorker(port)
+		return
+	}
+
 	// configuraciones dinámicas
 	workersFib := getenvInt("WORKERS_FIBONACCI", 2)
 	queueFib := getenvInt("QUEUE_FIBONACCI", 5)
@@ -35,6 +120,24 @@ func main() {
 	timeoutFib := getenvInt("TIMEOUT_FIBONACCI", 3000)
 	workers.SetTimeout("fibonacci", timeoutFib)
 
+	// reintentos: RETRY_MAX_ATTEMPTS_<COMANDO> ajusta la política por defecto
+	for _, cmd := range []string{"sortfile", "wordcount", "grep", "hashfile", "compress"} {
+		if n := getenvInt("RETRY_MAX_ATTEMPTS_"+strings.ToUpper(cmd), -1); n >= 0 {
+			p := jobs.RetryPolicyFor(cmd)
+			p.MaxAttempts = n
+			jobs.SetRetryPolicy(cmd, p)
+		}
+	}
+
+	// tracing: buffer de trazas recientes y exportadores OTLP opcionales
+	tracing.SetBufferSize(getenvInt("TRACE_BUFFER_SIZE", 256))
+	if path := os.Getenv("TRACE_EXPORT_FILE"); path != "" {
+		tracing.AddExporter(&tracing.FileExporter{Path: path})
+	}
+	if url := os.Getenv("TRACE_EXPORT_URL"); url != "" {
+		tracing.AddExporter(&tracing.HTTPExporter{URL: url})
+	}
+
 	// crear servidor
 	srv := server.NewServer(":" + port)
 
@@ -66,16 +169,106 @@ func main() {
 	workers.InitPool("compress", 1, 2)
 
 	// job manager con configuraciones dinámicas
-	jobMgr, err := jobs.NewJobManager("data/jobs_journal.jsonl", qDepth, maxTotal)
+	store, err := openJobStore()
 	if err != nil {
-		log.Fatalf("failed to init job manager: %v", err)
+		util.Error("failed to open job store", util.Fields{"error": err.Error()})
+		os.Exit(1)
 	}
+	jobMgr, err := jobs.NewJobManagerWithStore(store, qDepth, maxTotal)
+	if err != nil {
+		util.Error("failed to init job manager", util.Fields{"error": err.Error()})
+		os.Exit(1)
+	}
+	jobMgr.ConfigureCompaction(jobs.CompactionConfig{
+		MaxBytes: int64(getenvInt("JOURNAL_COMPACT_MB", 64)) * 1024 * 1024,
+		Interval: getenvDuration("JOURNAL_COMPACT_INTERVAL", time.Hour),
+	})
+	jobMgr.ConfigureScheduler(jobs.SchedulerConfig{
+		Weights: map[jobs.Priority]int{
+			jobs.PriorityHigh:   getenvInt("SCHED_WEIGHT_HIGH", 4),
+			jobs.PriorityNormal: getenvInt("SCHED_WEIGHT_NORMAL", 2),
+			jobs.PriorityLow:    getenvInt("SCHED_WEIGHT_LOW", 1),
+		},
+		Aging:          getenvDuration("SCHED_AGING", 30*time.Second),
+		BlockedBackoff: getenvDuration("SCHED_BLOCKED_BACKOFF", 200*time.Millisecond),
+	})
+	blobs, err := jobs.OpenBlobStore(getenv("RESULT_BLOB_DIR", "data/results"), getenvInt("RESULT_BLOB_THRESHOLD_KB", 64)*1024)
+	if err != nil {
+		util.Error("failed to open result blob store", util.Fields{"error": err.Error()})
+		os.Exit(1)
+	}
+	jobMgr.ConfigureResultBlobs(blobs)
+	jobMgr.ConfigureRetention(jobs.RetentionPolicy{
+		MaxAge: getenvDuration("JOB_RETENTION_MAX_AGE", 24*time.Hour),
+		MaxCount: map[string]int{
+			jobs.StatusDone:     getenvInt("JOB_RETENTION_MAX_DONE", 0),
+			jobs.StatusError:    getenvInt("JOB_RETENTION_MAX_ERROR", 0),
+			jobs.StatusCanceled: getenvInt("JOB_RETENTION_MAX_CANCELED", 0),
+			jobs.StatusTimeout:  getenvInt("JOB_RETENTION_MAX_TIMEOUT", 0),
+		},
+		MaxResultBytes: int64(getenvInt("JOB_RETENTION_MAX_RESULT_MB", 256)) * 1024 * 1024,
+		SweepInterval:  getenvDuration("JOB_RETENTION_SWEEP_INTERVAL", time.Minute),
+		TombstoneTTL:   getenvDuration("JOB_TOMBSTONE_TTL", 24*time.Hour),
+	})
+	webhookSecret := os.Getenv("WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		util.Warn("WEBHOOK_SECRET no definido: los callbacks de jobs se envían sin firma", nil)
+	}
+	jobMgr.ConfigureWebhooks(jobs.WebhookConfig{
+		Secret:         webhookSecret,
+		MaxAttempts:    getenvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		InitialBackoff: getenvDuration("WEBHOOK_INITIAL_BACKOFF", time.Second),
+		MaxBackoff:     getenvDuration("WEBHOOK_MAX_BACKOFF", time.Minute),
+		Timeout:        getenvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+	})
+	if err := jobMgr.ConfigureWorkflows(getenv("WORKFLOWS_FILE", "data/workflows.json")); err != nil {
+		util.Error("failed to load workflows", util.Fields{"error": err.Error()})
+		os.Exit(1)
+	}
+	jobMgr.ConfigureIdempotency(getenvDuration("IDEMPOTENCY_TTL", jobs.DefaultIdempotencyTTL))
+	if err := jobMgr.ConfigureSchedules(getenv("SCHEDULES_FILE", "data/schedules.json")); err != nil {
+		util.Error("failed to load schedules", util.Fields{"error": err.Error()})
+		os.Exit(1)
+	}
+	// workers remotos: sin heartbeat durante REMOTE_LEASE_TTL sus jobs vuelven a la cola
+	jobMgr.ConfigureRemote(jobs.RemoteConfig{LeaseTTL: getenvDuration("REMOTE_LEASE_TTL", 15*time.Second)})
+	workerToken := os.Getenv("WORKER_TOKEN")
+	allowNoToken := os.Getenv("WORKER_ALLOW_NO_TOKEN") == "true"
+	switch {
+	case workerToken == "" && allowNoToken:
+		util.Warn("WORKER_TOKEN no definido y WORKER_ALLOW_NO_TOKEN=true: cualquiera puede registrarse como worker y enviar resultados", nil)
+	case workerToken == "":
+		util.Info("WORKER_TOKEN no definido: los endpoints de workers remotos están desactivados", nil)
+	}
+	handlers.ConfigureRemoteWorkers(workerToken, allowNoToken)
 	handlers.InitializeJobManager(jobMgr)
 
+	// cache de resultados de los comandos deterministas; CACHE_MAX_ENTRIES=0 lo desactiva
+	if n := getenvInt("CACHE_MAX_ENTRIES", 1024); n > 0 {
+		handlers.ConfigureResultCache(cache.New(cache.Config{
+			MaxEntries: n,
+			MaxBytes:   int64(getenvInt("CACHE_MAX_MB", 64)) * 1024 * 1024,
+			TTL:        getenvDuration("CACHE_TTL", 10*time.Minute),
+		}))
+	}
+
+	// Retry-After de los submits rechazados durante un drain
+	handlers.ConfigureDrain(getenvDuration("DRAIN_RETRY_AFTER", 30*time.Second))
+
+	// umbrales de /readyz
+	handlers.ConfigureHealth(handlers.HealthConfig{
+		QueueSaturation:    getenvFloat("READY_QUEUE_SATURATION", 0.9),
+		DispatcherMaxStall: time.Duration(getenvInt("READY_DISPATCHER_MAX_STALL_MS", 5000)) * time.Millisecond,
+		JournalMaxSyncAge:  time.Duration(getenvInt("READY_JOURNAL_MAX_SYNC_AGE_MS", 30000)) * time.Millisecond,
+		AcceptMaxErrors:    int64(getenvInt("HEALTH_ACCEPT_MAX_ERRORS", 10)),
+	})
+
 	// register routes
 	srv.Router.Handle("/help", handlers.HelpHandler)
 	srv.Router.Handle("/status", handlers.StatusHandler)
 	srv.Router.Handle("/metrics", handlers.MetricsHandler)
+	srv.Router.Handle("/healthz", handlers.HealthzHandler)
+	srv.Router.Handle("/readyz", handlers.ReadyzHandler)
 
 	srv.Router.Handle("/fibonacci", handlers.FibonacciHandler)
 	srv.Router.Handle("/createfile", handlers.CreateFileHandler)
@@ -108,11 +301,50 @@ func main() {
 	// jobs endpoints
 	srv.Router.Handle("/jobs/submit", handlers.JobsSubmitHandler)
 	srv.Router.Handle("/jobs/status", handlers.JobsStatusHandler)
+	srv.Router.Handle("/jobs/history", handlers.JobsHistoryHandler)
+	srv.Router.Handle("/jobs/list", handlers.JobsListHandler)
+	srv.Router.Handle("/jobs/wait", handlers.JobsWaitHandler)
+	srv.Router.Handle("/jobs/events", handlers.JobsEventsHandler)
 	srv.Router.Handle("/jobs/result", handlers.JobsResultHandler)
 	srv.Router.Handle("/jobs/cancel", handlers.JobsCancelHandler)
+	srv.Router.Handle("/jobs/pause", handlers.JobsPauseHandler)
+	srv.Router.Handle("/jobs/resume", handlers.JobsResumeHandler)
+	srv.Router.Handle("/jobs/reprioritize", handlers.JobsReprioritizeHandler)
+	srv.Router.Handle("/jobs/bulk/cancel", handlers.JobsBulkCancelHandler)
+	srv.Router.Handle("/jobs/bulk/delete", handlers.JobsBulkDeleteHandler)
+	srv.Router.Handle("/jobs/bulk/reprioritize", handlers.JobsBulkReprioritizeHandler)
+	srv.Router.Handle("/queues", handlers.QueuesHandler)
+	srv.Router.Handle("/queues/pause", handlers.QueuesPauseHandler)
+	srv.Router.Handle("/queues/resume", handlers.QueuesResumeHandler)
+	srv.Router.Handle("/workflows/submit", handlers.WorkflowsSubmitHandler)
+	srv.Router.Handle("/workflows/status", handlers.WorkflowsStatusHandler)
+	srv.Router.Handle("/workflows/cancel", handlers.WorkflowsCancelHandler)
+	srv.Router.Handle("/schedules/create", handlers.SchedulesCreateHandler)
+	srv.Router.Handle("/schedules/list", handlers.SchedulesListHandler)
+	srv.Router.Handle("/schedules/pause", handlers.SchedulesPauseHandler)
+	srv.Router.Handle("/schedules/resume", handlers.SchedulesResumeHandler)
+	srv.Router.Handle("/schedules/delete", handlers.SchedulesDeleteHandler)
+	srv.Router.Handle("/admin/journal", handlers.JournalStatsHandler)
+	srv.Router.Handle("/admin/journal/compact", handlers.JournalCompactHandler)
+	srv.Router.Handle("/workers", handlers.WorkersHandler)
+	srv.Router.Handle("/workers/register", handlers.WorkersRegisterHandler)
+	srv.Router.Handle("/workers/poll", handlers.WorkersPollHandler)
+	srv.Router.Handle("/workers/heartbeat", handlers.WorkersHeartbeatHandler)
+	srv.Router.Handle("/workers/progress", handlers.WorkersProgressHandler)
+	srv.Router.Handle("/workers/complete", handlers.WorkersCompleteHandler)
+	srv.Router.Handle("/admin/drain", handlers.DrainHandler)
+	srv.Router.Handle("/admin/drain/status", handlers.DrainStatusHandler)
+	srv.Router.Handle("/admin/undrain", handlers.UndrainHandler)
+	srv.Router.Handle("/admin/cache", handlers.CacheStatsHandler)
+	srv.Router.Handle("/admin/cache/invalidate", handlers.CacheInvalidateHandler)
+
+	// tracing
+	srv.Router.Handle("/debug/traces", handlers.TracesHandler)
+	srv.Router.HandlePrefix("/debug/traces/", handlers.TraceHandler)
 
-	log.Printf("Servidor escuchando en http://localhost:%s\n", port)
+	util.Info("servidor iniciado", util.Fields{"url": "http://localhost:" + port, "pid": os.Getpid()})
 	if err := srv.Start(); err != nil {
-		log.Fatalf("Error al iniciar servidor: %v", err)
+		util.Error("error al iniciar servidor", util.Fields{"error": err.Error()})
+		os.Exit(1)
 	}
 }
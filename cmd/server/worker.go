@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/handlers"
+	"github.com/EngSteven/pso-http-server/internal/remote"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// runWorker arranca el modo worker (MODE=worker): toma jobs del manager en
+// MANAGER_URL y sirve en port solo /healthz y /worker con su estado.
+func runWorker(port string) {
+	managerURL := os.Getenv("MANAGER_URL")
+	if managerURL == "" {
+		util.Error("MODE=worker requiere MANAGER_URL", nil)
+		os.Exit(1)
+	}
+	host, _ := os.Hostname()
+	var commands []string
+	for _, c := range strings.Split(getenv("WORKER_COMMANDS", "mandelbrot,pi"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			commands = append(commands, c)
+		}
+	}
+
+	w := remote.New(remote.Config{
+		ManagerURL:  managerURL,
+		Name:        getenv("WORKER_NAME", host+":"+port),
+		Commands:    commands,
+		Concurrency: getenvInt("WORKER_CONCURRENCY", 2),
+		PollTimeout: getenvDuration("WORKER_POLL_TIMEOUT", 30*time.Second),
+		Token:       os.Getenv("WORKER_TOKEN"),
+	})
+	go w.Run(make(chan struct{}))
+
+	srv := server.NewServer(":" + port)
+	srv.Router.Handle("/healthz", handlers.HealthzHandler)
+	srv.Router.Handle("/worker", func(req *types.Request) *types.Response {
+		b, _ := json.MarshalIndent(w.Status(), "", "  ")
+		return server.NewResponse(200, "OK", "application/json", b)
+	})
+
+	util.Info("worker iniciado", util.Fields{"url": "http://localhost:" + port, "manager": managerURL, "commands": commands, "pid": os.Getpid()})
+	if err := srv.Start(); err != nil {
+		util.Error("error al iniciar servidor", util.Fields{"error": err.Error()})
+		os.Exit(1)
+	}
+}
@@ -12,12 +12,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/progress"
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
 )
 
 // CompressFile comprime un archivo usando gzip o xz y devuelve métricas de tiempo y tamaño.
-func CompressFile(name, codec string, cancelCh <-chan struct{}) *types.Response {
+// El avance de gzip se reporta por bytes leídos; xz corre como proceso externo
+// y sólo reporta la fase.
+func CompressFile(name, codec string, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
 	start := time.Now()
 
 	if name == "" {
@@ -57,6 +60,8 @@ func CompressFile(name, codec string, cancelCh <-chan struct{}) *types.Response
 
 		buf := make([]byte, 64*1024)
 		reader := bufio.NewReader(inFile)
+		var readBytes int64
+		rep.Update(0, "compressing")
 		for {
 			select {
 			case <-cancelCh:
@@ -70,6 +75,10 @@ func CompressFile(name, codec string, cancelCh <-chan struct{}) *types.Response
 			n, err := reader.Read(buf)
 			if n > 0 {
 				writer.Write(buf[:n])
+				readBytes += int64(n)
+				if inputSize > 0 {
+					rep.Update(float64(readBytes)/float64(inputSize), "")
+				}
 			}
 			if err == io.EOF {
 				break
@@ -93,6 +102,7 @@ func CompressFile(name, codec string, cancelCh <-chan struct{}) *types.Response
 
 		cmd.Stdout = outFile
 		cmd.Stderr = os.Stderr
+		rep.Phase("compressing")
 
 		if err := cmd.Run(); err != nil {
 			msg := fmt.Sprintf(`{"error":"xz compression failed: %v"}`, err)
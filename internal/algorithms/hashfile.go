@@ -10,12 +10,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash"
+	"io"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
 )
 
 // HashFile calcula el hash de un archivo usando el algoritmo indicado (sha256 por defecto).
@@ -70,6 +72,9 @@ func HashFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 			totalBytes += int64(n)
 		}
 		if err != nil {
+			if err != io.EOF {
+				util.Warn("error leyendo archivo para hash", util.Fields{"file": name, "error": err.Error()})
+			}
 			break
 		}
 	}
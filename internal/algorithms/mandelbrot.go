@@ -6,13 +6,15 @@ import (
 	"os"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/progress"
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
 )
 
 // Mandelbrot genera un mapa de iteraciones del conjunto de Mandelbrot.
-// Si saveFile=true, guarda un archivo PGM en disco.
-func Mandelbrot(width, height, maxIter int, saveFile bool, cancelCh <-chan struct{}) *types.Response {
+// Si saveFile=true, guarda un archivo PGM en disco. El avance se reporta por fila.
+func Mandelbrot(width, height, maxIter int, saveFile bool, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
 	start := time.Now()
 
 	if width <= 0 || height <= 0 || maxIter <= 0 {
@@ -64,11 +66,13 @@ func Mandelbrot(width, height, maxIter int, saveFile bool, cancelCh <-chan struc
 				}
 				grid[py][px] = iter
 			}
+			rep.Update(float64(py+1)/float64(height), "rendering")
 		}
 	}
 
 	filename := ""
 	if saveFile {
+		rep.Phase("saving")
 		filename = fmt.Sprintf("mandelbrot_%dx%d_%d.pgm", width, height, maxIter)
 		savePGM(filename, grid, maxIter)
 	}
@@ -89,6 +93,7 @@ func Mandelbrot(width, height, maxIter int, saveFile bool, cancelCh <-chan struc
 func savePGM(filename string, grid [][]int, maxIter int) {
 	f, err := os.Create(filename)
 	if err != nil {
+		util.Warn("no se pudo guardar el PGM", util.Fields{"file": filename, "error": err.Error()})
 		return
 	}
 	defer f.Close()
@@ -8,13 +8,14 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/progress"
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
 )
 
 // MatrixMultiply genera dos matrices NxN pseudoaleatorias y calcula su producto.
-// Devuelve el hash SHA256 del resultado.
-func MatrixMultiply(size int, seed int64, cancelCh <-chan struct{}) *types.Response {
+// Devuelve el hash SHA256 del resultado. El avance se reporta por fila de C.
+func MatrixMultiply(size int, seed int64, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
 	start := time.Now()
 
 	if size <= 0 || size > 1000 {
@@ -23,6 +24,7 @@ func MatrixMultiply(size int, seed int64, cancelCh <-chan struct{}) *types.Respo
 	}
 
 	rand.Seed(seed)
+	rep.Update(0, "generating")
 
 	// Crear matrices A y B con valores pseudoaleatorios
 	A := make([][]float64, size)
@@ -56,8 +58,10 @@ func MatrixMultiply(size int, seed int64, cancelCh <-chan struct{}) *types.Respo
 				}
 				_ = sum
 			}
+			rep.Update(0.95*float64(i+1)/float64(size), "multiplying")
 		}
 	}
+	rep.Phase("hashing")
 
 	// Calcular hash SHA-256 del resultado
 	h := sha256.New()
@@ -5,12 +5,13 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/progress"
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
 )
 
 // CalculatePi calcula π usando el algoritmo de Chudnovsky truncado.
-func CalculatePi(digits int, cancelCh <-chan struct{}) *types.Response {
+func CalculatePi(digits int, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
 	start := time.Now()
 
 	if digits <= 0 || digits > 10000 {
@@ -27,7 +28,8 @@ func CalculatePi(digits int, cancelCh <-chan struct{}) *types.Response {
 
 	// Configurar precisión (un poco mayor que los dígitos solicitados)
 	prec := uint(digits * 4)
-	bigPi := chudnovskyPi(prec, cancelCh)
+	bigPi := chudnovskyPi(prec, cancelCh, rep)
+	rep.Phase("formatting")
 
 	// Formatear el resultado a string truncado
 	piStr := bigPi.Text('f', digits)
@@ -43,7 +45,7 @@ func CalculatePi(digits int, cancelCh <-chan struct{}) *types.Response {
 
 // --- Implementación del algoritmo de Chudnovsky (iterativa truncada) ---
 
-func chudnovskyPi(prec uint, cancelCh <-chan struct{}) *big.Float {
+func chudnovskyPi(prec uint, cancelCh <-chan struct{}, rep *progress.Reporter) *big.Float {
 	// Configurar precisión alta
 	bigPi := new(big.Float).SetPrec(prec)
 
@@ -89,6 +91,7 @@ func chudnovskyPi(prec uint, cancelCh <-chan struct{}) *big.Float {
 		term := new(big.Float).SetPrec(prec).Quo(termNum, termDen)
 
 		sum.Add(sum, term)
+		rep.Update(0.95*float64(n+1)/float64(terms), "series")
 	}
 
 	// Calcular π ≈ 426880 * sqrt(10005) / sum
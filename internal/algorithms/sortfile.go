@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/progress"
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
 )
@@ -18,7 +19,8 @@ import (
 // Parámetros:
 //   name  = nombre del archivo a leer
 //   algo  = algoritmo de ordenamiento ("quick" o "merge")
-func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
+// El avance se reparte en lectura (40%), ordenamiento (30%) y escritura (30%).
+func SortFile(name, algo string, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
 	start := time.Now()
 
 	if name == "" {
@@ -44,6 +46,11 @@ func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 		return server.NewResponse(500, "Internal Server Error", "application/json", []byte(msg))
 	}
 	defer file.Close()
+	var totalBytes, readBytes, lines int64
+	if info, err := file.Stat(); err == nil {
+		totalBytes = info.Size()
+	}
+	rep.Update(0, "reading")
 
 	var numbers []int
 	scanner := bufio.NewScanner(file)
@@ -55,6 +62,10 @@ func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 				[]byte(`{"error":"operation cancelled while reading"}`))
 		default:
 		}
+		readBytes += int64(len(scanner.Bytes())) + 1
+		if lines++; totalBytes > 0 && lines%progressEvery == 0 {
+			rep.Update(0.4*float64(readBytes)/float64(totalBytes), "")
+		}
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
@@ -67,6 +78,7 @@ func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 
 	// --- 2️⃣ Ordenar ---
 	sortStart := time.Now()
+	rep.Update(0.4, "sorting")
 	switch algo {
 	case "merge":
 		numbers = mergeSort(numbers, cancelCh)
@@ -77,6 +89,7 @@ func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 			[]byte(`{"error":"invalid algorithm: must be merge or quick"}`))
 	}
 	sortTime := time.Since(sortStart)
+	rep.Update(0.7, "writing")
 
 	// --- 3️⃣ Escribir archivo de salida ---
 	writeStart := time.Now()
@@ -89,7 +102,10 @@ func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 	defer outFile.Close()
 
 	writer := bufio.NewWriter(outFile)
-	for _, n := range numbers {
+	for i, n := range numbers {
+		if i%progressEvery == 0 {
+			rep.Update(0.7+0.3*float64(i)/float64(len(numbers)), "")
+		}
 		select {
 		case <-cancelCh:
 			return server.NewResponse(499, "Client Closed Request", "application/json",
@@ -116,6 +132,9 @@ func SortFile(name, algo string, cancelCh <-chan struct{}) *types.Response {
 	return server.NewResponse(200, "OK", "application/json", data)
 }
 
+// cada cuántos elementos se reporta avance en los bucles por línea
+const progressEvery = 4096
+
 // --- MergeSort con soporte para cancelación ---
 func mergeSort(arr []int, cancelCh <-chan struct{}) []int {
 	if len(arr) <= 1 {
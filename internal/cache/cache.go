@@ -0,0 +1,236 @@
+// Package cache memoriza las respuestas de comandos deterministas. Es un LRU
+// acotado por cantidad de entradas y por bytes, con vencimiento por TTL, que
+// además colapsa pedidos idénticos concurrentes en un solo cálculo.
+package cache
+
+import (
+	"container/list"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// Config acota el tamaño y la vida de las entradas. Un valor <= 0 desactiva
+// el límite correspondiente.
+type Config struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+}
+
+// Stats son los contadores del cache desde que se creó.
+type Stats struct {
+	Entries       int   `json:"entries"`
+	Bytes         int64 `json:"bytes"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Collapsed     int64 `json:"collapsed"` // pedidos que esperaron un cálculo en curso
+	Evictions     int64 `json:"evictions"` // entradas sacadas por los límites de tamaño
+	Expired       int64 `json:"expired"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+type entry struct {
+	key       string
+	command   string
+	resp      *types.Response
+	size      int64
+	expiresAt time.Time // cero si no vence
+}
+
+// call es un cálculo en curso; los pedidos con la misma clave esperan done.
+type call struct {
+	done chan struct{}
+	resp *types.Response
+}
+
+// Cache es seguro para uso concurrente.
+type Cache struct {
+	mu       sync.Mutex
+	cfg      Config
+	ll       *list.List // frente = usada más recientemente
+	items    map[string]*list.Element
+	inflight map[string]*call
+	gen      uint64 // aumenta con cada Invalidate, ver Do
+	bytes    int64
+	stats    Stats
+	now      func() time.Time
+}
+
+func New(cfg Config) *Cache {
+	return &Cache{
+		cfg:      cfg,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		inflight: make(map[string]*call),
+		now:      time.Now,
+	}
+}
+
+// Key arma la clave de un comando y sus parámetros. Los parámetros se ordenan
+// y los vacíos se descartan, así el orden en el query no cambia la clave.
+func Key(command string, params map[string]string) string {
+	v := url.Values{}
+	for k, val := range params {
+		if val != "" {
+			v.Set(k, val)
+		}
+	}
+	return command + "?" + v.Encode()
+}
+
+// Do devuelve la respuesta guardada bajo key o, si no hay, la calcula con fn.
+// Si otro pedido ya está calculando la misma clave espera su resultado en vez
+// de repetir el trabajo. cached indica que la respuesta no la calculó este
+// llamado. Solo se guardan y se comparten respuestas 200 sin Stream; si el
+// cálculo en curso falla (cola llena, timeout) quienes esperaban lo intentan
+// por su cuenta.
+func (c *Cache) Do(key string, fn func() *types.Response) (resp *types.Response, cached bool) {
+	c.mu.Lock()
+	for {
+		if e := c.lookupLocked(key); e != nil {
+			c.stats.Hits++
+			c.mu.Unlock()
+			return clone(e.resp), true
+		}
+		cl, ok := c.inflight[key]
+		if !ok {
+			break
+		}
+		c.stats.Collapsed++
+		c.mu.Unlock()
+		<-cl.done
+		if cacheable(cl.resp) {
+			return clone(cl.resp), true
+		}
+		c.mu.Lock()
+	}
+	c.stats.Misses++
+	cl := &call{done: make(chan struct{})}
+	c.inflight[key] = cl
+	gen := c.gen
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		// una invalidación durante el cálculo puede haber vuelto viejo el resultado
+		if gen == c.gen {
+			c.addLocked(key, cl.resp)
+		}
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+	resp = fn()
+	// quien llama agrega headers a resp, las esperas y el cache usan una copia
+	cl.resp = clone(resp)
+	return resp, false
+}
+
+// Invalidate descarta las entradas de command, o todas si command es "".
+// Devuelve cuántas se descartaron.
+func (c *Cache) Invalidate(command string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	n := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); command == "" || e.command == command {
+			c.removeLocked(el)
+			n++
+		}
+		el = next
+	}
+	c.stats.Invalidations += int64(n)
+	return n
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = c.ll.Len()
+	st.Bytes = c.bytes
+	return st
+}
+
+func (c *Cache) lookupLocked(key string) *entry {
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	if !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt) {
+		c.removeLocked(el)
+		c.stats.Expired++
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	return e
+}
+
+// cacheable indica si resp puede guardarse o compartirse entre pedidos.
+func cacheable(resp *types.Response) bool {
+	return resp != nil && resp.StatusCode == 200 && resp.Stream == nil
+}
+
+func (c *Cache) addLocked(key string, resp *types.Response) {
+	if !cacheable(resp) {
+		return
+	}
+	size := responseSize(resp)
+	if c.cfg.MaxBytes > 0 && size > c.cfg.MaxBytes {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.removeLocked(el)
+	}
+	e := &entry{key: key, command: key, resp: resp, size: size}
+	if i := strings.IndexByte(key, '?'); i >= 0 {
+		e.command = key[:i]
+	}
+	if c.cfg.TTL > 0 {
+		e.expiresAt = c.now().Add(c.cfg.TTL)
+	}
+	c.items[key] = c.ll.PushFront(e)
+	c.bytes += size
+
+	for c.ll.Len() > 0 && ((c.cfg.MaxEntries > 0 && c.ll.Len() > c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes)) {
+		c.removeLocked(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	e := el.Value.(*entry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
+
+func responseSize(r *types.Response) int64 {
+	n := int64(len(r.Body) + len(r.StatusText))
+	for k, v := range r.Headers {
+		n += int64(len(k) + len(v))
+	}
+	return n
+}
+
+// clone copia la respuesta y sus headers; el cuerpo se comparte porque nadie
+// lo modifica después de armarlo.
+func clone(r *types.Response) *types.Response {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.Headers = make(map[string]string, len(r.Headers))
+	for k, v := range r.Headers {
+		c.Headers[k] = v
+	}
+	return &c
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+func okResponse(body string) *types.Response {
+	return &types.Response{StatusCode: 200, StatusText: "OK",
+		Headers: map[string]string{"Content-Type": "application/json"}, Body: []byte(body)}
+}
+
+func TestKeyIgnoresOrderAndEmptyParams(t *testing.T) {
+	a := Key("pi", map[string]string{"digits": "10", "x": "1"})
+	b := Key("pi", map[string]string{"x": "1", "digits": "10", "empty": ""})
+	if a != b {
+		t.Fatalf("keys differ: %q %q", a, b)
+	}
+	if a == Key("hash", map[string]string{"digits": "10", "x": "1"}) {
+		t.Fatal("command must be part of the key")
+	}
+}
+
+func TestLRUAndTTL(t *testing.T) {
+	c := New(Config{MaxEntries: 2, TTL: time.Minute})
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	calls := 0
+	get := func(key string) bool {
+		_, cached := c.Do(key, func() *types.Response { calls++; return okResponse(key) })
+		return cached
+	}
+
+	get("pi?a")
+	get("pi?b")
+	if !get("pi?a") {
+		t.Fatal("expected hit for a")
+	}
+	get("pi?c") // saca a b, la menos usada
+	if get("pi?b") {
+		t.Fatal("b should have been evicted")
+	}
+	now = now.Add(2 * time.Minute)
+	if get("pi?c") {
+		t.Fatal("c should have expired")
+	}
+	st := c.Stats()
+	if st.Hits != 1 || st.Evictions != 2 || st.Expired != 1 || st.Entries != 2 || calls != 5 {
+		t.Fatalf("unexpected stats %+v calls=%d", st, calls)
+	}
+}
+
+func TestErrorsAreNotCached(t *testing.T) {
+	c := New(Config{})
+	fail := func() *types.Response {
+		return &types.Response{StatusCode: 503, Body: []byte(`{"error":"queue full"}`)}
+	}
+	c.Do("pi?a", fail)
+	if _, cached := c.Do("pi?a", fail); cached {
+		t.Fatal("error responses must not be cached")
+	}
+}
+
+func TestConcurrentRequestsCollapse(t *testing.T) {
+	c := New(Config{})
+	var computed int32
+	release := make(chan struct{})
+	fn := func() *types.Response {
+		atomic.AddInt32(&computed, 1)
+		<-release
+		return okResponse("42")
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan *types.Response, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := c.Do("factor?n=42", fn)
+			results <- resp
+		}()
+	}
+	// espera a que todos estén adentro antes de liberar el cálculo
+	for c.Stats().Collapsed+c.Stats().Misses < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := atomic.LoadInt32(&computed); n != 1 {
+		t.Fatalf("computed %d times, want 1", n)
+	}
+	seen := map[*types.Response]bool{}
+	for r := range results {
+		if string(r.Body) != "42" || seen[r] {
+			t.Fatalf("bad or shared response %+v", r)
+		}
+		seen[r] = true
+	}
+}
+
+// Si el cálculo en curso falla, quienes esperaban no reciben ese error como
+// si viniera del cache: lo vuelven a intentar.
+func TestFailedCallIsNotShared(t *testing.T) {
+	c := New(Config{})
+	var computed int32
+	release := make(chan struct{})
+	fn := func() *types.Response {
+		if atomic.AddInt32(&computed, 1) == 1 {
+			<-release
+			return &types.Response{StatusCode: 503, Body: []byte(`{"error":"queue full"}`)}
+		}
+		return okResponse("42")
+	}
+
+	type result struct {
+		resp   *types.Response
+		cached bool
+	}
+	var wg sync.WaitGroup
+	results := make(chan result, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, cached := c.Do("factor?n=42", fn)
+			results <- result{resp, cached}
+		}()
+	}
+	for c.Stats().Collapsed+c.Stats().Misses < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	var failed int
+	for r := range results {
+		if r.resp.StatusCode != 200 {
+			if r.cached {
+				t.Fatalf("error shared as cached: %+v", r.resp)
+			}
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("%d requests got the error, want only the one that computed it", failed)
+	}
+	if n := atomic.LoadInt32(&computed); n < 2 {
+		t.Fatalf("computed %d times, waiters did not retry", n)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c := New(Config{})
+	c.Do("pi?digits=5", func() *types.Response { return okResponse("3.1415") })
+	c.Do("hash?text=a", func() *types.Response { return okResponse("h") })
+	if n := c.Invalidate("pi"); n != 1 {
+		t.Fatalf("invalidated %d, want 1", n)
+	}
+	if _, cached := c.Do("hash?text=a", nil); !cached {
+		t.Fatal("other commands must stay cached")
+	}
+
+	// un resultado calculado durante una invalidación no se guarda
+	c.Do("pi?digits=5", func() *types.Response {
+		c.Invalidate("")
+		return okResponse("3.1415")
+	})
+	if st := c.Stats(); st.Entries != 0 {
+		t.Fatalf("entries = %d after invalidation, want 0", st.Entries)
+	}
+}
@@ -0,0 +1,190 @@
+// Package cron interpreta expresiones cron de cinco campos y calcula la
+// próxima ejecución.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expression es una expresión cron ya interpretada: un bitset por campo.
+type Expression struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // el campo era "*", ver Next
+	source                        string
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// atajos equivalentes a expresiones de cinco campos
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse interpreta "minuto hora día-del-mes mes día-de-la-semana". Cada campo
+// acepta *, valores, rangos (1-5), listas (1,3,5), pasos (*/15, 10-40/10) y
+// nombres de mes y día (jan, mon). También acepta @hourly, @daily, @weekly,
+// @monthly y @yearly. El domingo es 0 o 7.
+func Parse(spec string) (*Expression, error) {
+	spec = strings.TrimSpace(spec)
+	expanded := spec
+	if m, ok := macros[strings.ToLower(spec)]; ok {
+		expanded = m
+	}
+	parts := strings.Fields(expanded)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron: se esperaban 5 campos, hay %d en %q", len(parts), spec)
+	}
+
+	e := &Expression{source: spec}
+	var err error
+	if e.minute, err = parseField(parts[0], minuteField); err != nil {
+		return nil, err
+	}
+	if e.hour, err = parseField(parts[1], hourField); err != nil {
+		return nil, err
+	}
+	if e.dom, err = parseField(parts[2], domField); err != nil {
+		return nil, err
+	}
+	if e.month, err = parseField(parts[3], monthField); err != nil {
+		return nil, err
+	}
+	if e.dow, err = parseField(parts[4], dowField); err != nil {
+		return nil, err
+	}
+	// el 7 también es domingo
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.domAny = parts[2] == "*" || parts[2] == "?"
+	e.dowAny = parts[4] == "*" || parts[4] == "?"
+	return e, nil
+}
+
+// String devuelve la expresión tal como se recibió.
+func (e *Expression) String() string {
+	return e.source
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: paso inválido en %s: %q", f.name, item)
+			}
+			rng, step = item[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("cron: rango invertido en %s: %q", f.name, item)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("cron: valor inválido en %s: %q (%d-%d)", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next devuelve el primer instante estrictamente posterior a t que cumple la
+// expresión, en la zona horaria de t. Si no existe en los próximos cinco años
+// (por ejemplo "0 0 30 2 *") devuelve el tiempo cero.
+//
+// Como en cron clásico, si día del mes y día de la semana están restringidos
+// basta con que se cumpla uno de los dos.
+func (e *Expression) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if e.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if e.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if e.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (e *Expression) dayMatches(t time.Time) bool {
+	dom := e.dom&(1<<uint(t.Day())) != 0
+	dow := e.dow&(1<<uint(t.Weekday())) != 0
+	if e.domAny || e.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,47 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC) // miércoles
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)}, // 7 = domingo
+		// día del mes y día de la semana restringidos: basta uno
+		{"0 12 15 * fri", time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)},
+		{"5,10 8-9 1 */3 *", time.Date(2024, 4, 1, 8, 5, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		if got := e.Next(base); !got.Equal(c.want) {
+			t.Errorf("%q: got %v, want %v", c.spec, got, c.want)
+		}
+	}
+
+	e, _ := Parse("0 0 30 2 *")
+	if got := e.Next(base); !got.IsZero() {
+		t.Errorf("30 de febrero no debería existir, got %v", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: se esperaba un error", spec)
+		}
+	}
+}
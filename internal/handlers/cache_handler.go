@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/EngSteven/pso-http-server/internal/cache"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// resultCache memoriza las respuestas de los comandos deterministas; nil lo desactiva.
+var resultCache *cache.Cache
+
+// ConfigureResultCache activa el cache de resultados de los endpoints directos.
+func ConfigureResultCache(c *cache.Cache) {
+	resultCache = c
+}
+
+// cachedPoolSubmit es HandlePoolSubmit con memoización. params son los
+// parámetros ya validados y normalizados que determinan el resultado; nil
+// indica que este pedido no debe cachearse (por ejemplo porque escribe un
+// archivo o usa una semilla aleatoria). El cliente puede saltarse el cache con
+// cache=false o Cache-Control: no-cache. La respuesta lleva X-Cache.
+func cachedPoolSubmit(req *types.Request, command string, params map[string]string, job workers.JobFunc, priority int) *types.Response {
+	if resultCache == nil {
+		return workers.HandlePoolSubmit(command, job, priority)
+	}
+	if params == nil || cacheBypassed(req) {
+		resp := workers.HandlePoolSubmit(command, job, priority)
+		setCacheHeader(resp, "BYPASS")
+		return resp
+	}
+
+	resp, cached := resultCache.Do(cache.Key(command, params), func() *types.Response {
+		return workers.HandlePoolSubmit(command, job, priority)
+	})
+	if cached {
+		setCacheHeader(resp, "HIT")
+	} else {
+		setCacheHeader(resp, "MISS")
+	}
+	return resp
+}
+
+func cacheBypassed(req *types.Request) bool {
+	if v := req.Query.Get("cache"); v == "false" || v == "0" {
+		return true
+	}
+	cc := strings.ToLower(req.Headers["cache-control"])
+	return strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store")
+}
+
+func setCacheHeader(resp *types.Response, value string) {
+	if resp == nil {
+		return
+	}
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers["X-Cache"] = value
+}
+
+// CacheStatsHandler maneja /admin/cache: contadores del cache de resultados.
+func CacheStatsHandler(req *types.Request) *types.Response {
+	if resultCache == nil {
+		return server.NewResponse(404, "Not Found", "application/json",
+			[]byte(`{"error":"result cache disabled"}`))
+	}
+	b, _ := json.MarshalIndent(resultCache.Stats(), "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// CacheInvalidateHandler maneja /admin/cache/invalidate[?command=NAME]: descarta
+// las entradas de un comando, o todas si no se indica ninguno.
+func CacheInvalidateHandler(req *types.Request) *types.Response {
+	if resultCache == nil {
+		return server.NewResponse(404, "Not Found", "application/json",
+			[]byte(`{"error":"result cache disabled"}`))
+	}
+	command := req.Query.Get("command")
+	n := resultCache.Invalidate(command)
+	b, _ := json.Marshal(map[string]interface{}{"command": command, "invalidated": n})
+	return server.NewResponse(200, "OK", "application/json", b)
+}
@@ -18,7 +18,7 @@ func CompressHandler(req *types.Request) *types.Response {
 	}
 
 	jobFn := func(cancelCh <-chan struct{}) *types.Response {
-		return algorithms.CompressFile(name, codec, cancelCh)
+		return algorithms.CompressFile(name, codec, cancelCh, nil)
 	}
 
 	return workers.HandlePoolSubmit("compress", jobFn, workers.PriorityNormal)
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// drainRetryAfter es el Retry-After de los submits rechazados por un drain.
+var drainRetryAfter = 30 * time.Second
+
+// ConfigureDrain fija el Retry-After que se sugiere durante un drain.
+func ConfigureDrain(retryAfter time.Duration) {
+	if retryAfter > 0 {
+		drainRetryAfter = retryAfter
+	}
+}
+
+// drainingResponse es el 503 de un submit rechazado por un drain.
+func drainingResponse(err error) *types.Response {
+	secs := int((drainRetryAfter + time.Second - 1) / time.Second)
+	msg, _ := json.Marshal(map[string]interface{}{
+		"error":          err.Error(),
+		"retry_after_ms": drainRetryAfter.Milliseconds(),
+	})
+	resp := server.NewResponse(503, "Service Unavailable", "application/json", msg)
+	resp.Headers["Retry-After"] = strconv.Itoa(secs)
+	return resp
+}
+
+// ------------------------------------------------------------
+// /admin/drain?[command=CMD][&wait=true&timeout_ms=N]
+// Sin command drena todo el JobManager. Los jobs en cola y en ejecución
+// terminan; los submits nuevos reciben 503 con Retry-After.
+// ------------------------------------------------------------
+func DrainHandler(req *types.Request) *types.Response {
+	st := globalJobMgr.Drain(req.Query.Get("command"))
+	return drainStatusResponse(req, st)
+}
+
+// ------------------------------------------------------------
+// /admin/undrain?[command=CMD]
+// ------------------------------------------------------------
+func UndrainHandler(req *types.Request) *types.Response {
+	st := globalJobMgr.Undrain(req.Query.Get("command"))
+	return drainStatusResponse(req, st)
+}
+
+// ------------------------------------------------------------
+// /admin/drain/status?[wait=true&timeout_ms=N]
+// ------------------------------------------------------------
+func DrainStatusHandler(req *types.Request) *types.Response {
+	return drainStatusResponse(req, globalJobMgr.DrainStatus())
+}
+
+// drainStatusResponse devuelve st o, con wait=true, espera a que el drain
+// termine; timed_out indica que venció el timeout antes.
+func drainStatusResponse(req *types.Request, st jobs.DrainStatus) *types.Response {
+	body := map[string]interface{}{"drain": st}
+	if req.Query.Get("wait") == "true" {
+		timeout, ok := waitTimeout(req)
+		if !ok {
+			return server.NewResponse(400, "Bad Request", "application/json",
+				[]byte(`{"error":"invalid timeout_ms"}`))
+		}
+		st = globalJobMgr.WaitDrained(timeout)
+		body["drain"] = st
+		body["timed_out"] = !st.Drained && (st.Draining || len(st.Commands) > 0)
+	}
+	b, _ := json.MarshalIndent(body, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
@@ -28,5 +28,6 @@ func FactorHandler(req *types.Request) *types.Response {
 		return algorithms.Factorize(n, cancelCh)
 	}
 
-	return workers.HandlePoolSubmit("factor", jobFn, workers.PriorityNormal)
+	params := map[string]string{"n": strconv.FormatInt(n, 10)}
+	return cachedPoolSubmit(req, "factor", params, jobFn, workers.PriorityNormal)
 }
@@ -41,5 +41,6 @@ func FibonacciHandler(req *types.Request) *types.Response {
 		}
 
 	// Maneja el pool y los posibles errores 
-	return workers.HandlePoolSubmit("fibonacci", jobFn, prio)
+	params := map[string]string{"num": strconv.Itoa(n)}
+	return cachedPoolSubmit(req, "fibonacci", params, jobFn, prio)
 }
\ No newline at end of file
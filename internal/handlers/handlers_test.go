@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// useJobManager instala un JobManager en memoria como globalJobMgr durante
+// el test.
+func useJobManager(t *testing.T) *jobs.JobManager {
+	t.Helper()
+	return useJobStore(t, jobs.NewMemoryStore())
+}
+
+// useJobStore es useJobManager sobre un store ya cargado.
+func useJobStore(t *testing.T, store jobs.JobStore) *jobs.JobManager {
+	t.Helper()
+	jm, err := jobs.NewJobManagerWithStore(store, 4, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := globalJobMgr
+	globalJobMgr = jm
+	t.Cleanup(func() {
+		globalJobMgr = prev
+		jm.Close()
+	})
+	return jm
+}
+
+// get arma un GET a path con la query dada.
+func get(path string, query url.Values) *types.Request {
+	return &types.Request{Method: "GET", Path: path, RawQuery: query.Encode(), Query: query, Headers: map[string]string{}}
+}
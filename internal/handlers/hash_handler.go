@@ -18,5 +18,5 @@ func HashHandler(req *types.Request) *types.Response {
 	}
 
 	// Usa un pool genérico o "hash"
-	return workers.HandlePoolSubmit("hash", jobFn, workers.PriorityNormal)
+	return cachedPoolSubmit(req, "hash", map[string]string{"text": text}, jobFn, workers.PriorityNormal)
 }
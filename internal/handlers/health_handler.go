@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/metrics"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// HealthConfig define los umbrales de /healthz y /readyz.
+type HealthConfig struct {
+	QueueSaturation    float64       // fracción de la cola de un pool a partir de la cual no está listo
+	DispatcherMaxStall time.Duration // tiempo máximo sin iteraciones del dispatcher
+	JournalMaxSyncAge  time.Duration // antigüedad máxima del último fsync del journal
+	AcceptMaxErrors    int64         // errores consecutivos de Accept tolerados
+}
+
+var healthCfg = HealthConfig{
+	QueueSaturation:    0.9,
+	DispatcherMaxStall: 5 * time.Second,
+	JournalMaxSyncAge:  30 * time.Second,
+	AcceptMaxErrors:    10,
+}
+
+// ConfigureHealth reemplaza los umbrales por defecto. Los valores <= 0 se ignoran.
+func ConfigureHealth(cfg HealthConfig) {
+	if cfg.QueueSaturation > 0 {
+		healthCfg.QueueSaturation = cfg.QueueSaturation
+	}
+	if cfg.DispatcherMaxStall > 0 {
+		healthCfg.DispatcherMaxStall = cfg.DispatcherMaxStall
+	}
+	if cfg.JournalMaxSyncAge > 0 {
+		healthCfg.JournalMaxSyncAge = cfg.JournalMaxSyncAge
+	}
+	if cfg.AcceptMaxErrors > 0 {
+		healthCfg.AcceptMaxErrors = cfg.AcceptMaxErrors
+	}
+}
+
+// HealthCheck es el resultado de una verificación individual.
+type HealthCheck struct {
+	Name   string      `json:"name"`
+	Status string      `json:"status"` // "ok" o "fail"
+	Detail string      `json:"detail,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// HealthReport es el cuerpo JSON de /healthz y /readyz.
+type HealthReport struct {
+	Status    string        `json:"status"` // "ok" o "degraded"
+	Checks    []HealthCheck `json:"checks"`
+	Timestamp string        `json:"timestamp"`
+}
+
+func check(name string, ok bool, detail string, data interface{}) HealthCheck {
+	st := "ok"
+	if !ok {
+		st = "fail"
+	}
+	return HealthCheck{Name: name, Status: st, Detail: detail, Data: data}
+}
+
+func healthResponse(checks []HealthCheck) *types.Response {
+	report := HealthReport{Status: "ok", Checks: checks, Timestamp: time.Now().Format(time.RFC3339Nano)}
+	for _, c := range checks {
+		if c.Status != "ok" {
+			report.Status = "degraded"
+		}
+	}
+	body, _ := json.MarshalIndent(report, "", "  ")
+	if report.Status != "ok" {
+		return server.NewResponse(503, "Service Unavailable", "application/json", body)
+	}
+	return server.NewResponse(200, "OK", "application/json", body)
+}
+
+func acceptLoopCheck() HealthCheck {
+	st := metrics.GetAcceptLoopState()
+	switch {
+	case !st.Running:
+		return check("accept_loop", false, "accept loop not running", st)
+	case st.ConsecutiveErrors >= healthCfg.AcceptMaxErrors:
+		return check("accept_loop", false, fmt.Sprintf("%d consecutive accept errors", st.ConsecutiveErrors), st)
+	}
+	return check("accept_loop", true, "", st)
+}
+
+// HealthzHandler maneja /healthz: el proceso está vivo y el accept loop responde.
+func HealthzHandler(req *types.Request) *types.Response {
+	return healthResponse([]HealthCheck{
+		check("process", true, "", nil),
+		acceptLoopCheck(),
+	})
+}
+
+// ReadyzHandler maneja /readyz: pools bajo el umbral de saturación, sin drain
+// global, dispatcher del JobManager activo y journal escribible con fsync reciente.
+func ReadyzHandler(req *types.Request) *types.Response {
+	checks := []HealthCheck{acceptLoopCheck()}
+
+	pools := workers.GetAllPools()
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := pools[name]
+		info := p.Info()
+		capacity := p.QueueCapacity()
+		usage := 0.0
+		if capacity > 0 {
+			usage = float64(info.QueueLength) / float64(capacity)
+		}
+		data := map[string]interface{}{
+			"queue_length":   info.QueueLength,
+			"queue_capacity": capacity,
+			"busy_workers":   info.BusyWorkers,
+			"workers":        info.Workers,
+			"usage":          usage,
+		}
+		ok := usage < healthCfg.QueueSaturation
+		detail := ""
+		if !ok {
+			detail = fmt.Sprintf("queue usage %.0f%% >= %.0f%%", usage*100, healthCfg.QueueSaturation*100)
+		}
+		checks = append(checks, check("pool:"+name, ok, detail, data))
+	}
+
+	if globalJobMgr == nil {
+		checks = append(checks, check("job_manager", false, "job manager not initialized", nil))
+		return healthResponse(checks)
+	}
+
+	queued, max := globalJobMgr.QueueUsage()
+	qUsage := 0.0
+	if max > 0 {
+		qUsage = float64(queued) / float64(max)
+	}
+	qOK := qUsage < healthCfg.QueueSaturation
+	qDetail := ""
+	if !qOK {
+		qDetail = fmt.Sprintf("job queue usage %.0f%% >= %.0f%%", qUsage*100, healthCfg.QueueSaturation*100)
+	}
+	checks = append(checks, check("job_queue", qOK, qDetail, map[string]interface{}{"queued": queued, "max": max}))
+
+	ds := globalJobMgr.DrainStatus()
+	if ds.Draining {
+		checks = append(checks, check("drain", false, "job manager draining", ds))
+	} else {
+		checks = append(checks, check("drain", true, "", ds))
+	}
+
+	dh := globalJobMgr.DispatcherHealth()
+	switch {
+	case !dh.Alive:
+		checks = append(checks, check("dispatcher", false, "dispatcher goroutine not running", dh))
+	case time.Duration(dh.StallMs)*time.Millisecond > healthCfg.DispatcherMaxStall:
+		checks = append(checks, check("dispatcher", false, fmt.Sprintf("no dispatcher activity for %d ms", dh.StallMs), dh))
+	default:
+		checks = append(checks, check("dispatcher", true, "", dh))
+	}
+
+	jh := globalJobMgr.CheckJournal(healthCfg.JournalMaxSyncAge)
+	switch {
+	case !jh.Writable:
+		checks = append(checks, check("journal", false, "journal not writable: "+jh.LastError, jh))
+	case time.Duration(jh.SyncAgeMs)*time.Millisecond > healthCfg.JournalMaxSyncAge:
+		checks = append(checks, check("journal", false, fmt.Sprintf("last fsync %d ms ago", jh.SyncAgeMs), jh))
+	default:
+		checks = append(checks, check("journal", true, "", jh))
+	}
+
+	return healthResponse(checks)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/metrics"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// flakyStore es un store "durable" cuyo Probe falla mientras err no sea nil.
+type flakyStore struct {
+	*jobs.MemoryStore
+	mu  sync.Mutex
+	err error
+}
+
+func (s *flakyStore) Location() string { return "flaky" }
+func (s *flakyStore) Sync() error      { return nil }
+func (s *flakyStore) Probe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+func (s *flakyStore) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// failedChecks devuelve los checks en estado "fail" del reporte.
+func failedChecks(t *testing.T, res *types.Response) map[string]bool {
+	t.Helper()
+	var report HealthReport
+	if err := json.Unmarshal(res.Body, &report); err != nil {
+		t.Fatal(err)
+	}
+	failed := map[string]bool{}
+	for _, c := range report.Checks {
+		if c.Status != "ok" {
+			failed[c.Name] = true
+		}
+	}
+	return failed
+}
+
+// /readyz responde 503 mientras el journal no es escribible o un pool está
+// saturado, y /healthz sigue en 200 porque el proceso está vivo.
+func TestReadyzFailingChecks(t *testing.T) {
+	metrics.AcceptLoopStarted()
+	defer metrics.AcceptLoopStopped()
+	store := &flakyStore{MemoryStore: jobs.NewMemoryStore()}
+	jm := useJobStore(t, store)
+	for i := 0; !jm.DispatcherHealth().Alive; i++ {
+		if i > 500 {
+			t.Fatal("el dispatcher no arrancó")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if res := ReadyzHandler(get("/readyz", url.Values{})); res.StatusCode != 200 {
+		t.Fatalf("readyz = %d: %s", res.StatusCode, res.Body)
+	}
+
+	store.fail(errors.New("disk full"))
+	res := ReadyzHandler(get("/readyz", url.Values{}))
+	if res.StatusCode != 503 || !failedChecks(t, res)["journal"] {
+		t.Fatalf("readyz con journal roto = %d: %s", res.StatusCode, res.Body)
+	}
+	if res := HealthzHandler(get("/healthz", url.Values{})); res.StatusCode != 200 {
+		t.Fatalf("healthz = %d: %s", res.StatusCode, res.Body)
+	}
+	store.fail(nil)
+	if res := ReadyzHandler(get("/readyz", url.Values{})); res.StatusCode != 200 {
+		t.Fatalf("readyz tras recuperar el journal = %d: %s", res.StatusCode, res.Body)
+	}
+
+	// un worker ocupado y la cola llena
+	pool := workers.InitPool("readyz-test", 1, 2)
+	release := make(chan struct{})
+	block := func(<-chan struct{}) *types.Response { <-release; return nil }
+	defer close(release)
+	started := make(chan struct{})
+	pool.Enqueue(func(c <-chan struct{}) *types.Response { close(started); return block(c) }, 0)
+	<-started
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := pool.Enqueue(block, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res = ReadyzHandler(get("/readyz", url.Values{}))
+	if res.StatusCode != 503 || !failedChecks(t, res)["pool:readyz-test"] {
+		t.Fatalf("readyz con pool saturado = %d: %s", res.StatusCode, res.Body)
+	}
+	if res := HealthzHandler(get("/healthz", url.Values{})); res.StatusCode != 200 {
+		t.Fatalf("healthz con pool saturado = %d", res.StatusCode)
+	}
+}
@@ -25,16 +25,54 @@ func HelpHandler(req *types.Request) *types.Response {
 		HTTPEndpoints: []string{
 			"/help",
 			"/status",
-			"/metrics",
+			"/metrics[?format=prometheus]",
+			"/healthz",
+			"/readyz",
 			"/reverse?text=...",
 			"/toupper?text=...",
 			"/fibonacci?num=...",
 			"/createfile?name=...&content=...&repeat=x",
 			"/deletefile?name=...",
-			"/jobs/submit?task=TASK&<params>",
+			"/jobs/submit?task=TASK&<params>[&labels=k=v,...][&owner=O][&callback_url=URL][&run_at=RFC3339|unix_ms | &delay_ms=N][&idempotency_key=K][&max_attempts=N&backoff=fixed|linear|exponential&retry_delay_ms=&retry_max_delay_ms=&retry_on=error,timeout,503]",
 			"/jobs/status?id=JOBID",
+			"/jobs/history?id=JOBID",
+			"/jobs/list?[status=&command=&priority=&label=k=v&owner=&created_after=&created_before=&sort=&order=&limit=&cursor=&include_params=&include_result=]",
 			"/jobs/result?id=JOBID",
+			"/jobs/wait?id=JOBID[&timeout_ms=N]",
+			"/jobs/events[?id=JOBID|label=k=v] (text/event-stream, Last-Event-ID para reanudar)",
 			"/jobs/cancel?id=JOBID",
+			"/jobs/pause?id=JOBID",
+			"/jobs/resume?id=JOBID",
+			"/jobs/reprioritize?id=JOBID&priority=high|normal|low",
+			"/jobs/bulk/cancel?label=k=v&owner=O[&status=&command=]",
+			"/jobs/bulk/delete?label=k=v&owner=O[&status=&command=]",
+			"/jobs/bulk/reprioritize?priority=high|normal|low&label=k=v&owner=O[&status=&command=]",
+			"/queues",
+			"/queues/pause?command=CMD",
+			"/queues/resume?command=CMD",
+			"POST /workflows/submit {\"steps\":[{\"id\",\"command\",\"params\":{\"name\":\"${step.output_file}\"},\"depends_on\"}]}",
+			"/workflows/status?id=WORKFLOWID",
+			"/workflows/cancel?id=WORKFLOWID",
+			"/schedules/create?cron=EXPR&task=TASK&<params>[&name=&timezone=&priority=&labels=k=v,...]",
+			"/schedules/list",
+			"/schedules/pause?id=ID",
+			"/schedules/resume?id=ID",
+			"/schedules/delete?id=ID",
+			"/admin/journal",
+			"/admin/journal/compact",
+			"/workers",
+			"/workers/register?name=&commands=mandelbrot,pi&capacity=N",
+			"/workers/poll?worker=ID[&timeout_ms=N]",
+			"/workers/heartbeat?worker=ID&jobs=JOBID,...",
+			"/workers/progress?worker=ID&job=JOBID&fraction=0.5[&phase=]",
+			"POST /workers/complete?worker=ID&job=JOBID&status_code=200[&content_type=]",
+			"/admin/drain?[command=CMD][&wait=true&timeout_ms=N]",
+			"/admin/drain/status?[wait=true&timeout_ms=N]",
+			"/admin/undrain?[command=CMD]",
+			"/admin/cache",
+			"/admin/cache/invalidate[?command=NAME]",
+			"/debug/traces",
+			"/debug/traces/{id}[?format=otlp][&export=true]",
 		},
 		JobCommands: []string{
 			"fibonacci",
@@ -52,6 +90,10 @@ func HelpHandler(req *types.Request) *types.Response {
 			"Todos los endpoints soportan HTTP/1.0 y devuelven JSON.",
 			"Los comandos listados en 'job_commands' pueden ejecutarse vía /jobs/submit.",
 			"Los tiempos y concurrencia son configurables mediante variables de entorno.",
+			"fibonacci, isprime, factor, pi, hash, matrixmul (con seed) y mandelbrot (sin save) se cachean; X-Cache indica HIT, MISS o BYPASS y cache=false lo saltea.",
+			"Durante un drain /jobs/submit responde 503 con Retry-After; sin command drena todo y /readyz deja de estar listo.",
+			"Con MODE=worker y MANAGER_URL el binario toma jobs de WORKER_COMMANDS de otro servidor; mientras haya un worker vivo para un comando sus jobs no usan el pool local y vuelven a la cola si el lease vence. Manager y workers comparten WORKER_TOKEN (sin él hace falta WORKER_ALLOW_NO_TOKEN=true).",
+			"Las operaciones /jobs/bulk/* requieren al menos una etiqueta u owner y devuelven el resultado de cada job.",
 		},
 	}
 
@@ -29,5 +29,10 @@ func IsPrimeHandler(req *types.Request) *types.Response {
 		return algorithms.IsPrime(n, method, cancelCh)
 	}
 
-	return workers.HandlePoolSubmit("isprime", jobFn, workers.PriorityNormal)
+	keyMethod := method
+	if keyMethod == "" {
+		keyMethod = "trial" // el método por defecto, misma entrada del cache
+	}
+	params := map[string]string{"n": strconv.FormatInt(n, 10), "method": keyMethod}
+	return cachedPoolSubmit(req, "isprime", params, jobFn, workers.PriorityNormal)
 }
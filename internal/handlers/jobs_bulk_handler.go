@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// ------------------------------------------------------------
+// /jobs/bulk/cancel?label=k=v&owner=O[&status=&command=]
+// ------------------------------------------------------------
+func JobsBulkCancelHandler(req *types.Request) *types.Response {
+	return bulkResponse(req, globalJobMgr.BulkCancel)
+}
+
+// ------------------------------------------------------------
+// /jobs/bulk/delete?label=k=v&owner=O[&status=&command=]
+// Solo borra jobs terminados; los activos se informan como skipped.
+// ------------------------------------------------------------
+func JobsBulkDeleteHandler(req *types.Request) *types.Response {
+	return bulkResponse(req, globalJobMgr.BulkDelete)
+}
+
+// ------------------------------------------------------------
+// /jobs/bulk/reprioritize?priority=high|normal|low&label=k=v&owner=O[&status=&command=]
+// ------------------------------------------------------------
+func JobsBulkReprioritizeHandler(req *types.Request) *types.Response {
+	p := jobs.Priority(req.Query.Get("priority"))
+	switch p {
+	case jobs.PriorityHigh, jobs.PriorityNormal, jobs.PriorityLow:
+	default:
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid priority, expected high, normal or low"}`))
+	}
+	return bulkResponse(req, func(filter jobs.ListFilter) ([]jobs.BulkResult, error) {
+		return globalJobMgr.BulkReprioritize(filter, p)
+	})
+}
+
+// bulkResponse arma el selector desde la query, aplica op y responde con el
+// resultado de cada job y un resumen por resultado.
+func bulkResponse(req *types.Request, op func(jobs.ListFilter) ([]jobs.BulkResult, error)) *types.Response {
+	var filter jobs.ListFilter
+	var err error
+	if filter.Labels, err = parseLabels(req.Query["label"]); err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+	filter.Owner = req.Query.Get("owner")
+	filter.Statuses = splitList(req.Query["status"])
+	filter.Commands = splitList(req.Query["command"])
+
+	results, err := op(filter)
+	if errors.Is(err, jobs.ErrEmptySelector) {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+
+	summary := make(map[string]int)
+	for _, r := range results {
+		summary[r.Outcome]++
+	}
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"matched": len(results),
+		"summary": summary,
+		"results": results,
+	}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
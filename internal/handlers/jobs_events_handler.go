@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 120 * time.Second
+	sseHeartbeat       = 15 * time.Second
+)
+
+// ------------------------------------------------------------
+// /jobs/wait?id=JOBID&timeout_ms=N
+// Bloquea hasta que el job termina o vence el timeout; timed_out indica cuál.
+// ------------------------------------------------------------
+func JobsWaitHandler(req *types.Request) *types.Response {
+	id := req.Query.Get("id")
+	if id == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing id parameter"}`))
+	}
+	timeout, ok := waitTimeout(req)
+	if !ok {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid timeout_ms"}`))
+	}
+
+	meta, done, err := globalJobMgr.Wait(id, timeout)
+	if err != nil {
+		return jobLookupError(id, err)
+	}
+	body := jobStatusBody(meta)
+	body["timed_out"] = !done
+	b, _ := json.MarshalIndent(body, "", "  ")
+	return withJobID(server.NewResponse(200, "OK", "application/json", b), id)
+}
+
+// waitTimeout lee timeout_ms, acotado a maxWaitTimeout.
+func waitTimeout(req *types.Request) (time.Duration, bool) {
+	v := req.Query.Get("timeout_ms")
+	if v == "" {
+		return defaultWaitTimeout, true
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms < 0 {
+		return 0, false
+	}
+	if timeout := time.Duration(ms) * time.Millisecond; timeout < maxWaitTimeout {
+		return timeout, true
+	}
+	return maxWaitTimeout, true
+}
+
+// ------------------------------------------------------------
+// /jobs/events[?id=JOBID | ?label=k=v]
+// Server-sent events con los cambios de estado y progreso. Con id, se envía
+// primero el estado actual y el stream termina cuando el job termina.
+// Con el header Last-Event-ID se reenvían los eventos posteriores que el
+// cliente no recibió; si ya no están disponibles se vuelve a enviar el estado.
+// ------------------------------------------------------------
+func JobsEventsHandler(req *types.Request) *types.Response {
+	var filter jobs.EventFilter
+	filter.JobID = req.Query.Get("id")
+	labels, err := parseLabels(req.Query["label"])
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+	filter.Labels = labels
+
+	// suscribirse antes de leer el estado inicial para no perder transiciones
+	var (
+		sub      *jobs.Subscription
+		missed   []jobs.Event
+		resumed  bool
+		lastSeen = req.Headers["last-event-id"]
+	)
+	if lastSeen != "" {
+		seq, err := strconv.ParseUint(lastSeen, 10, 64)
+		if err != nil {
+			return server.NewResponse(400, "Bad Request", "application/json",
+				[]byte(`{"error":"invalid Last-Event-ID"}`))
+		}
+		sub, missed, resumed = globalJobMgr.SubscribeSince(filter, 256, seq)
+	} else {
+		sub = globalJobMgr.Subscribe(filter, 256)
+	}
+	var initial *jobs.JobMeta
+	if filter.JobID != "" {
+		initial, err = globalJobMgr.GetMeta(filter.JobID)
+		if err != nil {
+			sub.Close()
+			return jobLookupError(filter.JobID, err)
+		}
+	}
+
+	resp := server.NewResponse(200, "OK", "text/event-stream", nil)
+	delete(resp.Headers, "Content-Length")
+	resp.Headers["Cache-Control"] = "no-cache"
+	resp.Stream = func(w io.Writer) error {
+		defer sub.Close()
+		if lastSeen != "" && !resumed {
+			if _, err := fmt.Fprintf(w, ": events after %s are no longer available\n\n", lastSeen); err != nil {
+				return err
+			}
+		}
+		for _, e := range missed {
+			if err := writeSSE(w, e); err != nil {
+				return err
+			}
+			if filter.JobID != "" && isFinished(e) {
+				return nil
+			}
+		}
+		// al reanudar sin huecos el estado ya se conoce, salvo que el job haya terminado
+		if resumed && initial != nil && !isFinished(jobs.Event{Type: jobs.EventStatus, Status: initial.Status}) {
+			initial = nil
+		}
+		if initial != nil {
+			e := jobs.Event{
+				Type:     jobs.EventStatus,
+				JobID:    initial.ID,
+				Command:  initial.Command,
+				Priority: initial.Priority,
+				Status:   initial.Status,
+				Error:    initial.Error,
+				Labels:   initial.Labels,
+				At:       initial.UpdatedAt,
+			}
+			if err := writeSSE(w, e); err != nil || isFinished(e) {
+				return err
+			}
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		var reported int64
+		for {
+			select {
+			case e := <-sub.C:
+				if d := sub.Dropped(); d != reported {
+					if _, err := fmt.Fprintf(w, ": dropped %d events\n\n", d-reported); err != nil {
+						return err
+					}
+					reported = d
+				}
+				if err := writeSSE(w, e); err != nil {
+					return err
+				}
+				if filter.JobID != "" && isFinished(e) {
+					return nil
+				}
+			case <-heartbeat.C:
+				// también sirve para detectar que el cliente se desconectó
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return resp
+}
+
+// writeSSE escribe un evento; el estado inicial no tiene secuencia y va sin id.
+func writeSSE(w io.Writer, e jobs.Event) error {
+	data, _ := json.Marshal(e)
+	if e.Seq == 0 {
+		_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+	return err
+}
+
+// isFinished indica si el evento es la transición a un estado terminal.
+func isFinished(e jobs.Event) bool {
+	if e.Type != jobs.EventStatus {
+		return false
+	}
+	switch e.Status {
+	case jobs.StatusDone, jobs.StatusError, jobs.StatusCanceled, jobs.StatusTimeout, jobs.StatusExpired:
+		return true
+	}
+	return false
+}
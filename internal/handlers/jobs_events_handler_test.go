@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+)
+
+// Con Last-Event-ID el stream reenvía solo los eventos que el cliente no vio.
+func TestJobsEventsResume(t *testing.T) {
+	jm := useJobManager(t)
+	jm.PauseQueue("reverse")
+	all := jm.Subscribe(jobs.EventFilter{}, 16)
+	id, err := jm.Submit("reverse", map[string]string{"text": "abc"}, jobs.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := (<-all.C).Seq
+	all.Close()
+	if _, err := jm.PauseJob(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jm.ResumeJob(id); err != nil {
+		t.Fatal(err)
+	}
+
+	req := get("/jobs/events", url.Values{"id": {id}})
+	req.Headers["last-event-id"] = strconv.FormatUint(seen, 10)
+	res := JobsEventsHandler(req)
+	if res.StatusCode != 200 || res.Stream == nil {
+		t.Fatalf("status = %d: %s", res.StatusCode, res.Body)
+	}
+	if err := jm.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := res.Stream(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, block := range strings.Split(strings.TrimSpace(buf.String()), "\n\n") {
+		if !strings.HasPrefix(block, "id: ") {
+			t.Fatalf("evento sin id al reanudar: %q", block)
+		}
+		got = append(got, strings.SplitN(block, "\n", 2)[0])
+	}
+	want := []string{"id: " + strconv.FormatUint(seen+1, 10), "id: " + strconv.FormatUint(seen+2, 10), "id: " + strconv.FormatUint(seen+3, 10)}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("eventos = %v, want %v\n%s", got, want, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"status":"paused"`) || !strings.Contains(buf.String(), `"status":"canceled"`) {
+		t.Fatalf("stream = %s", buf.String())
+	}
+
+	req.Headers["last-event-id"] = "abc"
+	if res := JobsEventsHandler(req); res.StatusCode != 400 {
+		t.Fatalf("Last-Event-ID inválido: status = %d", res.StatusCode)
+	}
+}
+
+type brokenConn struct{}
+
+func (brokenConn) Write(p []byte) (int, error) { return 0, errors.New("connection reset") }
+
+// Cuando el cliente se desconecta el stream termina y libera la suscripción.
+func TestJobsEventsClientGone(t *testing.T) {
+	jm := useJobManager(t)
+	jm.PauseQueue("reverse")
+	before := jm.Subscribers()
+
+	res := JobsEventsHandler(get("/jobs/events", url.Values{}))
+	if jm.Subscribers() != before+1 {
+		t.Fatalf("suscripciones = %d, want %d", jm.Subscribers(), before+1)
+	}
+	done := make(chan error, 1)
+	go func() { done <- res.Stream(brokenConn{}) }()
+	if _, err := jm.Submit("reverse", map[string]string{"text": "abc"}, jobs.PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("el stream no reportó el error de escritura")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("el stream siguió abierto tras la desconexión")
+	}
+	if n := jm.Subscribers(); n != before {
+		t.Fatalf("suscripciones = %d tras la desconexión, want %d", n, before)
+	}
+}
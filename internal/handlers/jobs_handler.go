@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/EngSteven/pso-http-server/internal/jobs"
 	"github.com/EngSteven/pso-http-server/internal/server"
@@ -53,11 +58,85 @@ func JobsSubmitHandler(req *types.Request) *types.Response {
 		pr = jobs.PriorityNormal
 	}
 
+	labels, err := parseLabels(req.Query["labels"])
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+
+	callbackURL := req.Query.Get("callback_url")
+	if callbackURL != "" {
+		u, err := url.Parse(callbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return server.NewResponse(400, "Bad Request", "application/json",
+				[]byte(`{"error":"invalid callback_url, expected an absolute http(s) URL"}`))
+		}
+	}
+
+	retry, err := parseRetryPolicy(req.Query, task)
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+
+	runAt, err := parseRunAt(req.Query)
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+
+	owner := req.Query.Get("owner")
+	if len(owner) > 255 {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"owner longer than 255 characters"}`))
+	}
+
+	// Idempotency-Key por header o parámetro; el header tiene prioridad
+	idemKey := req.Headers["idempotency-key"]
+	if idemKey == "" {
+		idemKey = req.Query.Get("idempotency_key")
+	}
+	if len(idemKey) > 255 {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"idempotency key longer than 255 characters"}`))
+	}
+
 	params := queryToMap(req.Query)
+	delete(params, "idempotency_key")
+	delete(params, "run_at")
+	delete(params, "delay_ms")
 	delete(params, "task")
 	delete(params, "priority")
+	delete(params, "labels")
+	delete(params, "owner")
+	delete(params, "callback_url")
+	for _, k := range retryParams {
+		delete(params, k)
+	}
 
-	jobID, err := globalJobMgr.Submit(task, params, pr)
+	jobID, replayed, err := globalJobMgr.SubmitIdempotent(task, params, pr, jobs.SubmitOptions{
+		TraceID:        req.TraceID,
+		ParentSpanID:   req.SpanID,
+		Labels:         labels,
+		Owner:          owner,
+		CallbackURL:    callbackURL,
+		Retry:          retry,
+		RunAt:          runAt,
+		IdempotencyKey: idemKey,
+	})
+	if errors.Is(err, jobs.ErrIdempotencyConflict) {
+		b, _ := json.MarshalIndent(map[string]string{
+			"error":  err.Error(),
+			"job_id": jobID,
+		}, "", "  ")
+		return withJobID(server.NewResponse(409, "Conflict", "application/json", b), jobID)
+	}
+	if replayed {
+		return idempotentReplay(jobID)
+	}
+	if errors.Is(err, jobs.ErrDraining) {
+		return drainingResponse(err)
+	}
 	if err == jobs.ErrJobQueueFull {
 		return server.NewResponse(503, "Service Unavailable", "application/json",
 			[]byte(`{"error":"queue full","retry_after_ms":1000}`))
@@ -68,11 +147,147 @@ func JobsSubmitHandler(req *types.Request) *types.Response {
 	}
 
 	resp := map[string]interface{}{
-		"job_id": jobID,
-		"status": "queued",
+		"job_id":   jobID,
+		"status":   "queued",
+		"trace_id": req.TraceID,
+	}
+	if owner != "" {
+		resp["owner"] = owner
+	}
+	if runAt != nil {
+		resp["run_at"] = runAt.Format(time.RFC3339Nano)
 	}
 	b, _ := json.MarshalIndent(resp, "", "  ")
-	return server.NewResponse(200, "OK", "application/json", b)
+	return withJobID(server.NewResponse(200, "OK", "application/json", b), jobID)
+}
+
+// parseRunAt interpreta run_at (RFC3339 o unix ms) o delay_ms; devuelve nil
+// si el job debe correr de inmediato.
+func parseRunAt(q url.Values) (*time.Time, error) {
+	runAtStr, delayStr := q.Get("run_at"), q.Get("delay_ms")
+	if runAtStr != "" && delayStr != "" {
+		return nil, errors.New("use either run_at or delay_ms")
+	}
+	var at time.Time
+	switch {
+	case runAtStr != "":
+		t, err := parseTimeParam(runAtStr)
+		if err != nil {
+			return nil, errors.New("invalid run_at, expected RFC3339 or unix milliseconds")
+		}
+		at = t
+	case delayStr != "":
+		ms, err := strconv.ParseInt(delayStr, 10, 64)
+		if err != nil || ms < 0 {
+			return nil, errors.New("invalid delay_ms")
+		}
+		at = time.Now().Add(time.Duration(ms) * time.Millisecond)
+	default:
+		return nil, nil
+	}
+	return &at, nil
+}
+
+// parámetros de submit que ajustan la política de reintentos del comando
+var retryParams = []string{"max_attempts", "backoff", "retry_delay_ms", "retry_max_delay_ms", "retry_on"}
+
+// parseRetryPolicy arma la política de reintentos pedida en el submit a partir
+// de la política por defecto del comando. retry_on acepta estados (error,
+// timeout) y códigos HTTP del resultado (500, 503...). Devuelve nil si no se
+// pidió nada.
+func parseRetryPolicy(q url.Values, task string) (*jobs.RetryPolicy, error) {
+	given := false
+	for _, k := range retryParams {
+		if q.Get(k) != "" {
+			given = true
+		}
+	}
+	if !given {
+		return nil, nil
+	}
+
+	p := jobs.RetryPolicyFor(task)
+	ints := map[string]*int{
+		"max_attempts":       &p.MaxAttempts,
+		"retry_delay_ms":     &p.InitialDelayMs,
+		"retry_max_delay_ms": &p.MaxDelayMs,
+	}
+	for k, dst := range ints {
+		if v := q.Get(k); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", k)
+			}
+			*dst = n
+		}
+	}
+	if v := q.Get("backoff"); v != "" {
+		p.Backoff = v
+	}
+	if v := q.Get("retry_on"); v != "" {
+		p.RetryOn, p.RetryCodes = nil, nil
+		for _, item := range splitList(q["retry_on"]) {
+			if code, err := strconv.Atoi(item); err == nil {
+				p.RetryCodes = append(p.RetryCodes, code)
+			} else {
+				p.RetryOn = append(p.RetryOn, item)
+			}
+		}
+		if len(p.RetryOn) == 0 {
+			// solo códigos: se reintentan los errores con esos códigos
+			p.RetryOn = []string{jobs.StatusError}
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// idempotentReplay responde a un submit repetido con el job original y su
+// estado actual, que puede ser expired si la retención ya lo eliminó.
+func idempotentReplay(jobID string) *types.Response {
+	status := jobs.StatusExpired
+	if meta, err := globalJobMgr.GetMeta(jobID); err == nil {
+		status = meta.Status
+	}
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"job_id":   jobID,
+		"status":   status,
+		"replayed": true,
+	}, "", "  ")
+	resp := server.NewResponse(200, "OK", "application/json", b)
+	resp.Headers["Idempotent-Replayed"] = "true"
+	return withJobID(resp, jobID)
+}
+
+// withJobID agrega el header X-Job-Id, que el access log usa para correlacionar.
+func withJobID(resp *types.Response, id string) *types.Response {
+	if id != "" {
+		resp.Headers["X-Job-Id"] = id
+	}
+	return resp
+}
+
+// jobLookupError traduce los errores de GetMeta: 404 si no existe, 410 con la
+// hora de expiración si la política de retención lo eliminó.
+func jobLookupError(id string, err error) *types.Response {
+	var expired *jobs.ExpiredError
+	switch {
+	case errors.As(err, &expired):
+		b, _ := json.MarshalIndent(map[string]interface{}{
+			"error":      "job expired",
+			"id":         id,
+			"expired_at": expired.ExpiredAt.Format(time.RFC3339Nano),
+		}, "", "  ")
+		return withJobID(server.NewResponse(410, "Gone", "application/json", b), id)
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return server.NewResponse(404, "Not Found", "application/json",
+			[]byte(`{"error":"job not found"}`))
+	default:
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
 }
 
 // ------------------------------------------------------------
@@ -86,33 +301,93 @@ func JobsStatusHandler(req *types.Request) *types.Response {
 	}
 
 	meta, err := globalJobMgr.GetMeta(id)
-	if err == jobs.ErrJobNotFound {
-		return server.NewResponse(404, "Not Found", "application/json",
-			[]byte(`{"error":"job not found"}`))
+	if err != nil {
+		return jobLookupError(id, err)
+	}
+
+	b, _ := json.MarshalIndent(jobStatusBody(meta), "", "  ")
+	return withJobID(server.NewResponse(200, "OK", "application/json", b), id)
+}
+
+// JobsHistoryHandler maneja /jobs/history?id=JOBID: la línea de tiempo de
+// estados del job, con hora, actor y motivo de cada transición.
+func JobsHistoryHandler(req *types.Request) *types.Response {
+	id := req.Query.Get("id")
+	if id == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing id parameter"}`))
+	}
+
+	meta, err := globalJobMgr.GetMeta(id)
+	if err != nil {
+		return jobLookupError(id, err)
+	}
+
+	history := meta.History
+	if history == nil {
+		history = []jobs.Transition{} // jobs guardados antes de registrar el historial
 	}
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"id":      meta.ID,
+		"command": meta.Command,
+		"status":  meta.Status,
+		"history": history,
+	}, "", "  ")
+	return withJobID(server.NewResponse(200, "OK", "application/json", b), id)
+}
 
-	// Calcular progreso simple según estado
-	progress := 0
+// jobStatusBody arma la respuesta de estado de un job con el progreso real
+// reportado por el algoritmo; eta_ms es null si aún no se puede estimar.
+func jobStatusBody(meta *jobs.JobMeta) map[string]interface{} {
+	var progress float64
+	var phase string
+	var eta interface{}
 	switch meta.Status {
-	case jobs.StatusQueued:
-		progress = 0
 	case jobs.StatusRunning:
-		progress = 50
+		if snap, ok := globalJobMgr.Progress(meta.ID); ok {
+			progress = math.Round(snap.Fraction*1000) / 10
+			phase = snap.Phase
+			if snap.HasETA {
+				eta = snap.ETA.Milliseconds()
+			}
+		}
 	case jobs.StatusDone:
 		progress = 100
-	default:
-		progress = 0
+		eta = 0
 	}
 
 	statusResp := map[string]interface{}{
 		"id":       meta.ID,
 		"status":   meta.Status,
 		"progress": progress,
-		"eta_ms":   0,
+		"eta_ms":   eta,
 	}
-
-	b, _ := json.MarshalIndent(statusResp, "", "  ")
-	return server.NewResponse(200, "OK", "application/json", b)
+	if phase != "" {
+		statusResp["phase"] = phase
+	}
+	if meta.Error != "" && meta.Status != jobs.StatusQueued {
+		statusResp["error"] = meta.Error
+	}
+	if meta.Attempt > 0 {
+		statusResp["attempt"] = meta.Attempt
+	}
+	if len(meta.AttemptErrors) > 0 {
+		statusResp["attempt_errors"] = meta.AttemptErrors
+	}
+	if meta.RunAt != nil && meta.Status == jobs.StatusQueued && meta.Attempt == 0 {
+		statusResp["run_at"] = meta.RunAt.Format(time.RFC3339Nano)
+	}
+	if meta.RetryAt != nil {
+		statusResp["retry_at"] = meta.RetryAt.Format(time.RFC3339Nano)
+	}
+	if meta.CallbackURL != "" {
+		statusResp["callback"] = map[string]interface{}{
+			"url":        meta.CallbackURL,
+			"status":     meta.DeliveryStatus,
+			"deliveries": meta.Deliveries,
+		}
+	}
+	return statusResp
 }
 
 // ------------------------------------------------------------
@@ -126,9 +401,8 @@ func JobsResultHandler(req *types.Request) *types.Response {
 	}
 
 	meta, err := globalJobMgr.GetMeta(id)
-	if err == jobs.ErrJobNotFound {
-		return server.NewResponse(404, "Not Found", "application/json",
-			[]byte(`{"error":"job not found"}`))
+	if err != nil {
+		return jobLookupError(id, err)
 	}
 
 	if meta.Status != jobs.StatusDone {
@@ -136,6 +410,32 @@ func JobsResultHandler(req *types.Request) *types.Response {
 		return server.NewResponse(409, "Conflict", "application/json", []byte(msg))
 	}
 
+	// resultados grandes: se envían directo desde el blob, ya verificado
+	if ref := meta.ResultRef; ref != nil {
+		f, err := globalJobMgr.OpenResult(meta)
+		if errors.Is(err, jobs.ErrResultCorrupted) {
+			return server.NewResponse(500, "Internal Server Error", "application/json",
+				[]byte(`{"error":"result checksum mismatch"}`))
+		}
+		if err != nil {
+			msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+			return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+		}
+		ctype := ref.ContentType
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		resp := server.NewResponse(ref.StatusCode, ref.StatusText, ctype, nil)
+		resp.Headers["Content-Length"] = strconv.FormatInt(ref.Size, 10)
+		resp.Headers["X-Content-Sha256"] = ref.SHA256
+		resp.Stream = func(w io.Writer) error {
+			defer f.Close()
+			_, err := io.Copy(w, f)
+			return err
+		}
+		return withJobID(resp, id)
+	}
+
 	// Decodificar el types.Response guardado en meta.Result
 	var res types.Response
 	if err := json.Unmarshal([]byte(meta.Result), &res); err != nil {
@@ -147,11 +447,11 @@ func JobsResultHandler(req *types.Request) *types.Response {
 	var body map[string]interface{}
 	if err := json.Unmarshal(res.Body, &body); err == nil {
 		b, _ := json.MarshalIndent(body, "", "  ")
-		return server.NewResponse(200, "OK", "application/json", b)
+		return withJobID(server.NewResponse(200, "OK", "application/json", b), id)
 	}
 
 	// Si no era JSON, devolver cuerpo literal
-	return server.NewResponse(200, "OK", res.Headers["Content-Type"], res.Body)
+	return withJobID(server.NewResponse(200, "OK", res.Headers["Content-Type"], res.Body), id)
 }
 
 // ------------------------------------------------------------
@@ -165,11 +465,14 @@ func JobsCancelHandler(req *types.Request) *types.Response {
 	}
 
 	err := globalJobMgr.Cancel(id)
+	if errors.Is(err, jobs.ErrJobExpired) {
+		return jobLookupError(id, err)
+	}
 	switch err {
 	case nil:
 		resp := map[string]string{"status": "canceled"}
 		b, _ := json.MarshalIndent(resp, "", "  ")
-		return server.NewResponse(200, "OK", "application/json", b)
+		return withJobID(server.NewResponse(200, "OK", "application/json", b), id)
 	case jobs.ErrJobNotFound:
 		return server.NewResponse(404, "Not Found", "application/json",
 			[]byte(`{"error":"job not found"}`))
@@ -181,3 +484,24 @@ func JobsCancelHandler(req *types.Request) *types.Response {
 		return server.NewResponse(500, "Internal Server Error", "application/json", []byte(msg))
 	}
 }
+
+// ------------------------------------------------------------
+// /admin/journal  y  /admin/journal/compact
+// ------------------------------------------------------------
+func JournalStatsHandler(req *types.Request) *types.Response {
+	b, _ := json.MarshalIndent(globalJobMgr.CompactionStats(), "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+func JournalCompactHandler(req *types.Request) *types.Response {
+	if err := globalJobMgr.Compact(); err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		if errors.Is(err, jobs.ErrCompactionUnsupported) {
+			// p. ej. JOB_STORE=memory: es la configuración, no una falla
+			return server.NewResponse(501, "Not Implemented", "application/json", msg)
+		}
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+	b, _ := json.MarshalIndent(globalJobMgr.CompactionStats(), "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
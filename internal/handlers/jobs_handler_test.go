@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Con el store en memoria compactar no es posible, pero no es un error del
+// servidor.
+func TestJournalCompactUnsupported(t *testing.T) {
+	useJobManager(t)
+	res := JournalCompactHandler(get("/admin/journal/compact", url.Values{}))
+	if res.StatusCode != 501 || !strings.Contains(string(res.Body), "does not support compaction") {
+		t.Fatalf("status = %d: %s", res.StatusCode, res.Body)
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// jobListItem es la vista de un job en /jobs/list.
+type jobListItem struct {
+	ID        string            `json:"id"`
+	Command   string            `json:"command"`
+	Priority  jobs.Priority     `json:"priority"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Params    map[string]string `json:"params,omitempty"`
+	Result    json.RawMessage   `json:"result,omitempty"`
+	ResultRef *jobs.ResultRef   `json:"result_ref,omitempty"`
+}
+
+// ------------------------------------------------------------
+// /jobs/list?status=&command=&priority=&created_after=&created_before=
+// &label=k=v&owner=O&sort=created_at|updated_at&order=asc|desc
+// &limit=N&cursor=C&include_params=true&include_result=false
+// ------------------------------------------------------------
+func JobsListHandler(req *types.Request) *types.Response {
+	filter, opts, err := parseListQuery(req)
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+	includeParams := req.Query.Get("include_params") != "false"
+	includeResult := req.Query.Get("include_result") == "true"
+
+	page, err := globalJobMgr.List(filter, opts)
+	if errors.Is(err, jobs.ErrInvalidCursor) {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid cursor"}`))
+	}
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+
+	items := make([]jobListItem, 0, len(page.Jobs))
+	for _, meta := range page.Jobs {
+		item := jobListItem{
+			ID:        meta.ID,
+			Command:   meta.Command,
+			Priority:  meta.Priority,
+			Status:    meta.Status,
+			Error:     meta.Error,
+			Labels:    meta.Labels,
+			Owner:     meta.Owner,
+			CreatedAt: meta.CreatedAt,
+			UpdatedAt: meta.UpdatedAt,
+		}
+		if includeParams {
+			item.Params = meta.Params
+		}
+		if includeResult {
+			item.Result = resultBody(meta)
+			item.ResultRef = meta.ResultRef
+		}
+		items = append(items, item)
+	}
+
+	resp := map[string]interface{}{
+		"jobs":        items,
+		"count":       len(items),
+		"total":       page.Total,
+		"summary":     page.Summary,
+		"next_cursor": page.NextCursor,
+	}
+	b, _ := json.MarshalIndent(resp, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// parseListQuery arma el filtro y las opciones de paginación desde la query.
+func parseListQuery(req *types.Request) (jobs.ListFilter, jobs.ListOptions, error) {
+	var filter jobs.ListFilter
+	opts := jobs.ListOptions{SortBy: "created_at", Desc: true, Limit: defaultListLimit}
+
+	filter.Statuses = splitList(req.Query["status"])
+	filter.Commands = splitList(req.Query["command"])
+	for _, p := range splitList(req.Query["priority"]) {
+		switch jobs.Priority(p) {
+		case jobs.PriorityHigh, jobs.PriorityNormal, jobs.PriorityLow:
+			filter.Priorities = append(filter.Priorities, jobs.Priority(p))
+		default:
+			return filter, opts, fmt.Errorf("invalid priority %q", p)
+		}
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseTimeParam(req.Query.Get("created_after")); err != nil {
+		return filter, opts, fmt.Errorf("invalid created_after: %v", err)
+	}
+	if filter.CreatedBefore, err = parseTimeParam(req.Query.Get("created_before")); err != nil {
+		return filter, opts, fmt.Errorf("invalid created_before: %v", err)
+	}
+	if filter.Labels, err = parseLabels(req.Query["label"]); err != nil {
+		return filter, opts, err
+	}
+	filter.Owner = req.Query.Get("owner")
+
+	switch s := req.Query.Get("sort"); s {
+	case "", "created_at", "updated_at":
+		if s != "" {
+			opts.SortBy = s
+		}
+	default:
+		return filter, opts, fmt.Errorf("invalid sort %q", s)
+	}
+	switch o := req.Query.Get("order"); o {
+	case "", "desc":
+	case "asc":
+		opts.Desc = false
+	default:
+		return filter, opts, fmt.Errorf("invalid order %q", o)
+	}
+	if l := req.Query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return filter, opts, fmt.Errorf("invalid limit %q", l)
+		}
+		if n > maxListLimit {
+			n = maxListLimit
+		}
+		opts.Limit = n
+	}
+	opts.Cursor = req.Query.Get("cursor")
+	return filter, opts, nil
+}
+
+// splitList acepta valores repetidos y separados por coma: status=done,error&status=timeout.
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// parseLabels interpreta etiquetas k=v separadas por coma.
+func parseLabels(values []string) (map[string]string, error) {
+	parts := splitList(values)
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(parts))
+	for _, p := range parts {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", p)
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+// parseTimeParam acepta RFC3339 o milisegundos desde epoch; vacío es tiempo cero.
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// resultBody devuelve el cuerpo guardado inline como JSON; si no era JSON se
+// devuelve como string. Los resultados en blob sólo se referencian.
+func resultBody(meta *jobs.JobMeta) json.RawMessage {
+	if meta.Result == "" {
+		return nil
+	}
+	var res types.Response
+	if err := json.Unmarshal([]byte(meta.Result), &res); err != nil {
+		return nil
+	}
+	if json.Valid(res.Body) {
+		return res.Body
+	}
+	b, _ := json.Marshal(string(res.Body))
+	return b
+}
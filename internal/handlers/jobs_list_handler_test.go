@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+// Un cursor que no salió de /jobs/list es un error del cliente.
+func TestJobsListInvalidCursor(t *testing.T) {
+	useJobManager(t)
+	res := JobsListHandler(get("/jobs/list", url.Values{"cursor": {"no-es-un-cursor"}}))
+	if res.StatusCode != 400 {
+		t.Fatalf("status = %d, want 400: %s", res.StatusCode, res.Body)
+	}
+	if res := JobsListHandler(get("/jobs/list", url.Values{})); res.StatusCode != 200 {
+		t.Fatalf("status = %d: %s", res.StatusCode, res.Body)
+	}
+}
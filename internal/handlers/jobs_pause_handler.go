@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// ------------------------------------------------------------
+// /jobs/pause?id=JOBID  y  /jobs/resume?id=JOBID
+// ------------------------------------------------------------
+func JobsPauseHandler(req *types.Request) *types.Response {
+	return jobAction(req, globalJobMgr.PauseJob)
+}
+
+func JobsResumeHandler(req *types.Request) *types.Response {
+	return jobAction(req, globalJobMgr.ResumeJob)
+}
+
+// ------------------------------------------------------------
+// /jobs/reprioritize?id=JOBID&priority=high|normal|low
+// ------------------------------------------------------------
+func JobsReprioritizeHandler(req *types.Request) *types.Response {
+	p := jobs.Priority(req.Query.Get("priority"))
+	switch p {
+	case jobs.PriorityHigh, jobs.PriorityNormal, jobs.PriorityLow:
+	default:
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid priority, expected high, normal or low"}`))
+	}
+	return jobAction(req, func(id string) (*jobs.JobMeta, error) {
+		return globalJobMgr.SetPriority(id, p)
+	})
+}
+
+// jobAction valida el id, aplica fn y devuelve el estado resultante del job.
+func jobAction(req *types.Request, fn func(id string) (*jobs.JobMeta, error)) *types.Response {
+	id := req.Query.Get("id")
+	if id == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing id parameter"}`))
+	}
+	meta, err := fn(id)
+	switch {
+	case err == nil:
+	case errors.Is(err, jobs.ErrJobNotQueued), errors.Is(err, jobs.ErrJobNotPaused):
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return withJobID(server.NewResponse(409, "Conflict", "application/json", msg), id)
+	case errors.Is(err, jobs.ErrJobQueueFull):
+		return server.NewResponse(503, "Service Unavailable", "application/json",
+			[]byte(`{"error":"queue full","retry_after_ms":1000}`))
+	default:
+		return jobLookupError(id, err)
+	}
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"id":       meta.ID,
+		"command":  meta.Command,
+		"status":   meta.Status,
+		"priority": meta.Priority,
+	}, "", "  ")
+	return withJobID(server.NewResponse(200, "OK", "application/json", b), id)
+}
+
+// ------------------------------------------------------------
+// /queues/pause?command=CMD  y  /queues/resume?command=CMD
+// Una cola pausada sigue aceptando jobs pero no los despacha.
+// ------------------------------------------------------------
+func QueuesPauseHandler(req *types.Request) *types.Response {
+	return queueAction(req, globalJobMgr.PauseQueue)
+}
+
+func QueuesResumeHandler(req *types.Request) *types.Response {
+	return queueAction(req, globalJobMgr.ResumeQueue)
+}
+
+func queueAction(req *types.Request, fn func(command string)) *types.Response {
+	command := req.Query.Get("command")
+	if command == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing command parameter"}`))
+	}
+	fn(command)
+	return QueuesHandler(req)
+}
+
+// ------------------------------------------------------------
+// /queues
+// Backlog por comando y lo que está pausado.
+// ------------------------------------------------------------
+func QueuesHandler(req *types.Request) *types.Response {
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"backlogs": globalJobMgr.CommandBacklogs(),
+		"paused":   globalJobMgr.PauseStats(),
+	}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
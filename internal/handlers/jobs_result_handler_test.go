@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+)
+
+// Un resultado guardado como blob se envía por streaming con su checksum, y
+// un blob alterado responde 500 en lugar de datos corruptos.
+func TestJobsResultStreamsBlob(t *testing.T) {
+	dir := t.TempDir()
+	blobs, err := jobs.OpenBlobStore(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"digits":"3.14159"}`)
+	sum, err := blobs.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := jobs.NewMemoryStore()
+	now := time.Now()
+	store.Put(&jobs.JobMeta{ID: "big", Command: "pi", Status: jobs.StatusDone, CreatedAt: now, UpdatedAt: now,
+		ResultRef: &jobs.ResultRef{SHA256: sum, Size: int64(len(data)), StatusCode: 200, StatusText: "OK", ContentType: "application/json"}})
+	useJobStore(t, store).ConfigureResultBlobs(blobs)
+
+	res := JobsResultHandler(get("/jobs/result", url.Values{"id": {"big"}}))
+	if res.StatusCode != 200 || res.Stream == nil {
+		t.Fatalf("status = %d stream = %v: %s", res.StatusCode, res.Stream != nil, res.Body)
+	}
+	if res.Headers["Content-Length"] != "20" || res.Headers["X-Content-Sha256"] != sum || res.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("headers = %v", res.Headers)
+	}
+	var buf bytes.Buffer
+	if err := res.Stream(&buf); err != nil || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("cuerpo = %q %v", buf.Bytes(), err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, sum[:2], sum), []byte(`{"digits":"2.71828"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if res := JobsResultHandler(get("/jobs/result", url.Values{"id": {"big"}})); res.StatusCode != 500 {
+		t.Fatalf("blob alterado: status = %d", res.StatusCode)
+	}
+}
@@ -27,8 +27,18 @@ func MandelbrotHandler(req *types.Request) *types.Response {
 	}
 
 	jobFn := func(cancelCh <-chan struct{}) *types.Response {
-		return algorithms.Mandelbrot(width, height, maxIter, saveFile, cancelCh)
+		return algorithms.Mandelbrot(width, height, maxIter, saveFile, cancelCh, nil)
 	}
 
-	return workers.HandlePoolSubmit("mandelbrot", jobFn, workers.PriorityNormal)
+	// con save=true escribe un archivo en cada llamada, así que no se cachea
+	var params map[string]string
+	if !saveFile {
+		params = map[string]string{
+			"width":    strconv.Itoa(width),
+			"height":   strconv.Itoa(height),
+			"max_iter": strconv.Itoa(maxIter),
+		}
+	}
+
+	return cachedPoolSubmit(req, "mandelbrot", params, jobFn, workers.PriorityNormal)
 }
@@ -27,15 +27,17 @@ func MatrixHandler(req *types.Request) *types.Response {
 	}
 
 	var seed int64 = time.Now().UnixNano()
+	var params map[string]string // sin semilla el resultado es aleatorio y no se cachea
 	if seedStr != "" {
 		if s, err := strconv.ParseInt(seedStr, 10, 64); err == nil {
 			seed = s
+			params = map[string]string{"size": strconv.Itoa(size), "seed": strconv.FormatInt(s, 10)}
 		}
 	}
 
 	jobFn := func(cancelCh <-chan struct{}) *types.Response {
-		return algorithms.MatrixMultiply(size, seed, cancelCh)
+		return algorithms.MatrixMultiply(size, seed, cancelCh, nil)
 	}
 
-	return workers.HandlePoolSubmit("matrixmul", jobFn, workers.PriorityNormal)
+	return cachedPoolSubmit(req, "matrixmul", params, jobFn, workers.PriorityNormal)
 }
@@ -2,8 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/cache"
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/metrics"
 	"github.com/EngSteven/pso-http-server/internal/server"
 	"github.com/EngSteven/pso-http-server/internal/types"
 	"github.com/EngSteven/pso-http-server/internal/workers"
@@ -20,14 +27,30 @@ type CommandMetrics struct {
 	P95Ms          int64   `json:"p95_ms"`
 }
 
+// HTTPMetrics agrupa las métricas por ruta y los rechazos del parser
+type HTTPMetrics struct {
+	Routes        []metrics.RouteMetrics `json:"routes"`
+	ParseFailures map[string]int64       `json:"parse_failures"`
+}
+
 // Metrics estructura JSON del endpoint /metrics
 type Metrics struct {
 	Timestamp string                       `json:"timestamp"`
 	Commands  map[string]CommandMetrics    `json:"commands"`
+	HTTP      HTTPMetrics                  `json:"http"`
+	Journal   *jobs.CompactionStats        `json:"journal,omitempty"`
+	Retention *jobs.RetentionStats         `json:"retention,omitempty"`
+	Cache     *cache.Stats                 `json:"cache,omitempty"`
+	Paused    *jobs.PauseStats             `json:"paused,omitempty"`
 }
 
-// MetricsHandler devuelve métricas agregadas por tipo de comando
+// MetricsHandler devuelve métricas agregadas por tipo de comando.
+// Con ?format=prometheus o un Accept de Prometheus responde en formato de texto.
 func MetricsHandler(req *types.Request) *types.Response {
+	if wantsPrometheus(req) {
+		return server.NewResponse(200, "OK", metrics.PromContentType, prometheusMetrics())
+	}
+
 	pools := workers.GetAllPools()
 	metricsData := make(map[string]CommandMetrics)
 
@@ -49,8 +72,217 @@ func MetricsHandler(req *types.Request) *types.Response {
 	data := Metrics{
 		Timestamp: time.Now().Format(time.RFC3339Nano),
 		Commands:  metricsData,
+		HTTP: HTTPMetrics{
+			Routes:        metrics.HTTPRoutes(),
+			ParseFailures: metrics.ParseFailures(),
+		},
+	}
+	if globalJobMgr != nil {
+		st := globalJobMgr.CompactionStats()
+		data.Journal = &st
+		rs := globalJobMgr.RetentionStats()
+		data.Retention = &rs
+		ps := globalJobMgr.PauseStats()
+		data.Paused = &ps
+	}
+	if resultCache != nil {
+		cs := resultCache.Stats()
+		data.Cache = &cs
 	}
 
 	body, _ := json.MarshalIndent(data, "", "  ")
 	return server.NewResponse(200, "OK", "application/json", body)
 }
+
+// wantsPrometheus decide el formato de salida: ?format tiene prioridad sobre Accept.
+func wantsPrometheus(req *types.Request) bool {
+	switch req.Query.Get("format") {
+	case "prometheus", "prom", "text":
+		return true
+	case "json":
+		return false
+	}
+	accept := req.Headers["accept"]
+	return strings.Contains(accept, "text/plain") || strings.Contains(accept, "application/openmetrics-text")
+}
+
+// prometheusMetrics arma la exposición de texto con pools, HTTP, jobs, conexiones y runtime.
+func prometheusMetrics() []byte {
+	w := metrics.NewPromWriter()
+
+	pools := workers.GetAllPools()
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Family("pso_pool_workers", "gauge", "Number of workers in the pool.")
+	for _, name := range names {
+		w.Sample("pso_pool_workers", float64(pools[name].Info().Workers), "pool", name)
+	}
+	w.Family("pso_pool_busy_workers", "gauge", "Number of workers currently executing a job.")
+	for _, name := range names {
+		w.Sample("pso_pool_busy_workers", float64(pools[name].Info().BusyWorkers), "pool", name)
+	}
+	w.Family("pso_pool_queue_length", "gauge", "Number of jobs waiting in the pool queue.")
+	for _, name := range names {
+		w.Sample("pso_pool_queue_length", float64(pools[name].Info().QueueLength), "pool", name)
+	}
+	w.Family("pso_pool_queue_capacity", "gauge", "Maximum number of jobs the pool queue can hold.")
+	for _, name := range names {
+		w.Sample("pso_pool_queue_capacity", float64(pools[name].QueueCapacity()), "pool", name)
+	}
+	w.Family("pso_pool_job_duration_seconds", "histogram", "Job execution latency per pool.")
+	for _, name := range names {
+		w.Histogram("pso_pool_job_duration_seconds", pools[name].LatencyHistogram(), "pool", name)
+	}
+
+	routes := metrics.HTTPRoutes()
+	w.Family("pso_http_requests_total", "counter", "HTTP responses sent, by route, method and status code.")
+	for _, rm := range routes {
+		for _, code := range sortedCodes(rm.ByCode) {
+			w.Sample("pso_http_requests_total", float64(rm.ByCode[code]),
+				"route", rm.Route, "method", rm.Method, "code", strconv.Itoa(code))
+		}
+	}
+	w.Family("pso_http_responses_by_class_total", "counter", "HTTP responses sent, by route, method and status class.")
+	for _, rm := range routes {
+		for _, class := range sortedKeys(rm.ByClass) {
+			w.Sample("pso_http_responses_by_class_total", float64(rm.ByClass[class]),
+				"route", rm.Route, "method", rm.Method, "class", class)
+		}
+	}
+	w.Family("pso_http_request_duration_seconds", "histogram", "Time from accept to response written, by route and method.")
+	for _, rm := range routes {
+		w.Histogram("pso_http_request_duration_seconds", rm.Latency, "route", rm.Route, "method", rm.Method)
+	}
+	w.Family("pso_http_request_size_bytes", "histogram", "Size of the request line and headers, by route and method.")
+	for _, rm := range routes {
+		w.Histogram("pso_http_request_size_bytes", rm.RequestSize, "route", rm.Route, "method", rm.Method)
+	}
+	w.Family("pso_http_response_size_bytes", "histogram", "Size of the serialized response, by route and method.")
+	for _, rm := range routes {
+		w.Histogram("pso_http_response_size_bytes", rm.ResponseSize, "route", rm.Route, "method", rm.Method)
+	}
+	failures := metrics.ParseFailures()
+	w.Family("pso_http_parse_failures_total", "counter", "Requests rejected by the parser, by reason.")
+	for _, reason := range sortedKeys(failures) {
+		w.Sample("pso_http_parse_failures_total", float64(failures[reason]), "reason", reason)
+	}
+
+	if globalJobMgr != nil {
+		counts := globalJobMgr.CountsByStatus()
+		statuses := make([]string, 0, len(counts))
+		for st := range counts {
+			statuses = append(statuses, st)
+		}
+		sort.Strings(statuses)
+		w.Family("pso_jobs", "gauge", "Jobs known to the job manager, by status and priority.")
+		for _, st := range statuses {
+			for _, pr := range []jobs.Priority{jobs.PriorityHigh, jobs.PriorityNormal, jobs.PriorityLow} {
+				if n, ok := counts[st][pr]; ok {
+					w.Sample("pso_jobs", float64(n), "status", st, "priority", string(pr))
+				}
+			}
+		}
+		w.Family("pso_jobs_queue_length", "gauge", "Jobs waiting in the job manager queues, by priority.")
+		js := globalJobMgr.CompactionStats()
+		w.Family("pso_journal_bytes", "gauge", "Size of the active journal segment.")
+		w.Sample("pso_journal_bytes", float64(js.JournalBytes))
+		w.Family("pso_journal_snapshot_bytes", "gauge", "Size of the last journal snapshot.")
+		w.Sample("pso_journal_snapshot_bytes", float64(js.SnapshotBytes))
+		w.Family("pso_journal_compactions_total", "counter", "Successful journal compactions.")
+		w.Sample("pso_journal_compactions_total", float64(js.Count))
+		w.Family("pso_journal_last_compaction_duration_seconds", "gauge", "Duration of the last journal compaction.")
+		w.Sample("pso_journal_last_compaction_duration_seconds", float64(js.LastDurationMs)/1000)
+
+		rs := globalJobMgr.RetentionStats()
+		w.Family("pso_jobs_expired_total", "counter", "Finished jobs evicted by the retention policy.")
+		w.Sample("pso_jobs_expired_total", float64(rs.Expired))
+		w.Family("pso_jobs_tombstones", "gauge", "Evicted job IDs still answered with 410 Gone.")
+		w.Sample("pso_jobs_tombstones", float64(rs.Tombstones))
+		w.Family("pso_jobs_result_bytes", "gauge", "Size of the results kept after the last retention sweep.")
+		w.Sample("pso_jobs_result_bytes", float64(rs.ResultBytes))
+
+		queued := globalJobMgr.QueueLengths()
+		for _, pr := range []jobs.Priority{jobs.PriorityHigh, jobs.PriorityNormal, jobs.PriorityLow} {
+			w.Sample("pso_jobs_queue_length", float64(queued[pr]), "priority", string(pr))
+		}
+		backlogs := globalJobMgr.CommandBacklogs()
+		w.Family("pso_jobs_backlog", "gauge", "Jobs waiting in the job manager queues, by command.")
+		for _, cmd := range sortedKeys(backlogs) {
+			w.Sample("pso_jobs_backlog", float64(backlogs[cmd]), "command", cmd)
+		}
+		ps := globalJobMgr.PauseStats()
+		w.Family("pso_jobs_paused", "gauge", "Jobs paused one by one.")
+		w.Sample("pso_jobs_paused", float64(ps.Jobs))
+		w.Family("pso_queue_paused_backlog", "gauge", "Jobs held in the backlog of a paused command queue.")
+		for _, cmd := range sortedKeys(ps.Queues) {
+			w.Sample("pso_queue_paused_backlog", float64(ps.Queues[cmd]), "command", cmd)
+		}
+	}
+
+	if resultCache != nil {
+		cs := resultCache.Stats()
+		w.Family("pso_cache_hits_total", "counter", "Direct command responses served from the result cache.")
+		w.Sample("pso_cache_hits_total", float64(cs.Hits))
+		w.Family("pso_cache_misses_total", "counter", "Direct command responses computed because they were not cached.")
+		w.Sample("pso_cache_misses_total", float64(cs.Misses))
+		w.Family("pso_cache_collapsed_total", "counter", "Requests that waited for an identical computation already in progress.")
+		w.Sample("pso_cache_collapsed_total", float64(cs.Collapsed))
+		w.Family("pso_cache_evictions_total", "counter", "Cache entries evicted by the size limits.")
+		w.Sample("pso_cache_evictions_total", float64(cs.Evictions))
+		w.Family("pso_cache_expired_total", "counter", "Cache entries dropped because their TTL passed.")
+		w.Sample("pso_cache_expired_total", float64(cs.Expired))
+		w.Family("pso_cache_invalidations_total", "counter", "Cache entries dropped by an invalidation.")
+		w.Sample("pso_cache_invalidations_total", float64(cs.Invalidations))
+		w.Family("pso_cache_entries", "gauge", "Responses currently held in the result cache.")
+		w.Sample("pso_cache_entries", float64(cs.Entries))
+		w.Family("pso_cache_bytes", "gauge", "Approximate size of the responses held in the result cache.")
+		w.Sample("pso_cache_bytes", float64(cs.Bytes))
+	}
+
+	w.Family("pso_connections_total", "counter", "TCP connections accepted since start.")
+	w.Sample("pso_connections_total", float64(metrics.GetTotalConnections()))
+	w.Family("pso_connections_active", "gauge", "TCP connections currently open.")
+	w.Sample("pso_connections_active", float64(metrics.GetActiveConnections()))
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	w.Family("go_goroutines", "gauge", "Number of goroutines that currently exist.")
+	w.Sample("go_goroutines", float64(runtime.NumGoroutine()))
+	w.Family("go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.")
+	w.Sample("go_memstats_alloc_bytes", float64(ms.Alloc))
+	w.Family("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from the system.")
+	w.Sample("go_memstats_sys_bytes", float64(ms.Sys))
+	w.Family("go_memstats_heap_objects", "gauge", "Number of allocated heap objects.")
+	w.Sample("go_memstats_heap_objects", float64(ms.HeapObjects))
+	w.Family("go_gc_cycles_total", "counter", "Number of completed GC cycles.")
+	w.Sample("go_gc_cycles_total", float64(ms.NumGC))
+	w.Family("go_gc_pause_seconds_total", "counter", "Total time spent in GC stop-the-world pauses.")
+	w.Sample("go_gc_pause_seconds_total", float64(ms.PauseTotalNs)/1e9)
+	w.Family("process_uptime_seconds", "gauge", "Seconds since the server started.")
+	w.Sample("process_uptime_seconds", time.Since(startTime).Seconds())
+
+	return w.Bytes()
+}
+
+
+func sortedCodes(m map[int]int64) []int {
+	out := make([]int, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
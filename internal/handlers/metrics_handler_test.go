@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/metrics"
+)
+
+// La exposición Prometheus incluye las métricas HTTP por ruta, por clase y
+// los rechazos del parser.
+func TestMetricsPrometheusHTTP(t *testing.T) {
+	useJobManager(t)
+	// nombres únicos: los contadores son globales y sobreviven a -count
+	route := "/test/metrics/" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	reason := "test_bad_header_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	metrics.ObserveRequest(route, "GET", 200, 3*time.Millisecond, 80, 600)
+	metrics.ObserveRequest(route, "GET", 429, time.Millisecond, 80, 40)
+	metrics.RecordParseFailure(reason)
+
+	res := MetricsHandler(get("/metrics", url.Values{"format": {"prometheus"}}))
+	if res.StatusCode != 200 || !strings.HasPrefix(res.Headers["Content-Type"], "text/plain") {
+		t.Fatalf("status = %d headers = %v", res.StatusCode, res.Headers)
+	}
+	out := string(res.Body)
+	sel := `route="` + route + `",method="GET"`
+	for _, line := range []string{
+		"# TYPE pso_http_requests_total counter",
+		"pso_http_requests_total{" + sel + `,code="200"} 1`,
+		"pso_http_requests_total{" + sel + `,code="429"} 1`,
+		"pso_http_responses_by_class_total{" + sel + `,class="2xx"} 1`,
+		"pso_http_responses_by_class_total{" + sel + `,class="4xx"} 1`,
+		"pso_http_request_duration_seconds_count{" + sel + "} 2",
+		"pso_http_response_size_bytes_bucket{" + sel + `,le="128"} 1`,
+		"pso_http_response_size_bytes_sum{" + sel + "} 640",
+		`pso_http_parse_failures_total{reason="` + reason + `"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("falta la línea %q", line)
+		}
+	}
+}
@@ -25,8 +25,9 @@ func PiHandler(req *types.Request) *types.Response {
 	}
 
 	jobFn := func(cancelCh <-chan struct{}) *types.Response {
-		return algorithms.CalculatePi(digits, cancelCh)
+		return algorithms.CalculatePi(digits, cancelCh, nil)
 	}
 
-	return workers.HandlePoolSubmit("pi", jobFn, workers.PriorityNormal)
+	params := map[string]string{"digits": strconv.Itoa(digits)}
+	return cachedPoolSubmit(req, "pi", params, jobFn, workers.PriorityNormal)
 }
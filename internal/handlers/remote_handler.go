@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// workerToken debe venir en el header X-Worker-Token de cada request de un
+// worker remoto. Sin token los endpoints de workers se rechazan, salvo que
+// allowNoToken lo permita explícitamente (redes de confianza, desarrollo).
+var (
+	workerToken  string
+	allowNoToken bool
+)
+
+// ConfigureRemoteWorkers fija el token compartido con los workers remotos y
+// si se aceptan workers sin token cuando no hay uno configurado.
+func ConfigureRemoteWorkers(token string, allowWithoutToken bool) {
+	workerToken = token
+	allowNoToken = allowWithoutToken
+}
+
+func checkWorkerToken(req *types.Request) *types.Response {
+	if workerToken == "" {
+		if allowNoToken {
+			return nil
+		}
+		return server.NewResponse(403, "Forbidden", "application/json",
+			[]byte(`{"error":"remote workers disabled: set WORKER_TOKEN"}`))
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Headers["x-worker-token"]), []byte(workerToken)) != 1 {
+		return server.NewResponse(401, "Unauthorized", "application/json",
+			[]byte(`{"error":"invalid worker token"}`))
+	}
+	return nil
+}
+
+// workerRequest valida el token y el id del worker.
+func workerRequest(req *types.Request) (string, *types.Response) {
+	if bad := checkWorkerToken(req); bad != nil {
+		return "", bad
+	}
+	id := req.Query.Get("worker")
+	if id == "" {
+		return "", server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing worker parameter"}`))
+	}
+	return id, nil
+}
+
+// remoteError traduce los errores del protocolo: 404 si el worker expiró (debe
+// registrarse de nuevo) y 409 si perdió el lease del job.
+func remoteError(err error) *types.Response {
+	msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+	switch {
+	case errors.Is(err, jobs.ErrUnknownWorker):
+		return server.NewResponse(404, "Not Found", "application/json", msg)
+	case errors.Is(err, jobs.ErrLeaseLost):
+		return server.NewResponse(409, "Conflict", "application/json", msg)
+	default:
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+}
+
+// ------------------------------------------------------------
+// /workers/register?name=NAME&commands=mandelbrot,pi&capacity=N
+// ------------------------------------------------------------
+func WorkersRegisterHandler(req *types.Request) *types.Response {
+	if bad := checkWorkerToken(req); bad != nil {
+		return bad
+	}
+	capacity := 1
+	if v := req.Query.Get("capacity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return server.NewResponse(400, "Bad Request", "application/json",
+				[]byte(`{"error":"invalid capacity"}`))
+		}
+		capacity = n
+	}
+	w, err := globalJobMgr.RegisterWorker(req.Query.Get("name"), splitList(req.Query["commands"]), capacity)
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+	ttl := globalJobMgr.LeaseTTL()
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"worker_id":    w.ID,
+		"commands":     w.Commands,
+		"lease_ttl_ms": ttl.Milliseconds(),
+		"heartbeat_ms": (ttl / 3).Milliseconds(),
+	}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// ------------------------------------------------------------
+// /workers/poll?worker=ID[&timeout_ms=N]
+// Long poll: 200 con el lease de un job o 204 si no hubo trabajo a tiempo.
+// ------------------------------------------------------------
+func WorkersPollHandler(req *types.Request) *types.Response {
+	id, bad := workerRequest(req)
+	if bad != nil {
+		return bad
+	}
+	timeout, ok := waitTimeout(req)
+	if !ok {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid timeout_ms"}`))
+	}
+	lease, err := globalJobMgr.PollJob(id, timeout)
+	if err != nil {
+		return remoteError(err)
+	}
+	if lease == nil {
+		return server.NewResponse(204, "No Content", "application/json", nil)
+	}
+	b, _ := json.MarshalIndent(lease, "", "  ")
+	return withJobID(server.NewResponse(200, "OK", "application/json", b), lease.JobID)
+}
+
+// ------------------------------------------------------------
+// /workers/heartbeat?worker=ID&jobs=JOBID,...
+// Renueva los leases de los jobs informados; stop lista los que el worker
+// debe abandonar (cancelados, vencidos o reasignados).
+// ------------------------------------------------------------
+func WorkersHeartbeatHandler(req *types.Request) *types.Response {
+	id, bad := workerRequest(req)
+	if bad != nil {
+		return bad
+	}
+	stop, err := globalJobMgr.Heartbeat(id, splitList(req.Query["jobs"]))
+	if err != nil {
+		return remoteError(err)
+	}
+	b, _ := json.MarshalIndent(map[string]interface{}{"stop": stop}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// ------------------------------------------------------------
+// /workers/progress?worker=ID&job=JOBID&fraction=0.5[&phase=]
+// ------------------------------------------------------------
+func WorkersProgressHandler(req *types.Request) *types.Response {
+	id, bad := workerRequest(req)
+	if bad != nil {
+		return bad
+	}
+	fraction, err := strconv.ParseFloat(req.Query.Get("fraction"), 64)
+	if err != nil {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid fraction"}`))
+	}
+	if err := globalJobMgr.ReportProgress(id, req.Query.Get("job"), fraction, req.Query.Get("phase")); err != nil {
+		return remoteError(err)
+	}
+	return server.NewResponse(200, "OK", "application/json", []byte(`{"status":"ok"}`))
+}
+
+// ------------------------------------------------------------
+// POST /workers/complete?worker=ID&job=JOBID&status_code=200[&content_type=]
+// El cuerpo es el resultado del comando tal como lo devolvió el algoritmo.
+// ------------------------------------------------------------
+func WorkersCompleteHandler(req *types.Request) *types.Response {
+	id, bad := workerRequest(req)
+	if bad != nil {
+		return bad
+	}
+	if req.Method != "POST" {
+		return server.NewResponse(405, "Method Not Allowed", "application/json",
+			[]byte(`{"error":"use POST with the result as body"}`))
+	}
+	code, err := strconv.Atoi(req.Query.Get("status_code"))
+	if err != nil || code < 100 || code > 599 {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"invalid status_code"}`))
+	}
+	ctype := req.Query.Get("content_type")
+	if ctype == "" {
+		ctype = "application/json"
+	}
+	res := server.NewResponse(code, http.StatusText(code), ctype, req.Body)
+	if err := globalJobMgr.CompleteJob(id, req.Query.Get("job"), res); err != nil {
+		return remoteError(err)
+	}
+	return server.NewResponse(200, "OK", "application/json", []byte(`{"status":"ok"}`))
+}
+
+// ------------------------------------------------------------
+// /workers
+// Workers registrados y leases vigentes.
+// ------------------------------------------------------------
+func WorkersHandler(req *types.Request) *types.Response {
+	workers, leases := globalJobMgr.RemoteWorkers()
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"workers": workers,
+		"leases":  leases,
+	}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+// Sin WORKER_TOKEN nadie puede registrarse como worker salvo que se lo
+// permita explícitamente; con token, solo quien lo envía.
+func TestWorkerTokenRequired(t *testing.T) {
+	useJobManager(t)
+	defer ConfigureRemoteWorkers("", false)
+	register := func(token string) int {
+		req := get("/workers/register", url.Values{"name": {"w1"}, "commands": {"reverse"}})
+		if token != "" {
+			req.Headers["x-worker-token"] = token
+		}
+		return WorkersRegisterHandler(req).StatusCode
+	}
+
+	ConfigureRemoteWorkers("", false)
+	if code := register(""); code != 403 {
+		t.Fatalf("sin token configurado: status = %d, want 403", code)
+	}
+	ConfigureRemoteWorkers("", true)
+	if code := register(""); code != 200 {
+		t.Fatalf("con WORKER_ALLOW_NO_TOKEN: status = %d, want 200", code)
+	}
+	ConfigureRemoteWorkers("s3cret", false)
+	if code := register(""); code != 401 {
+		t.Fatalf("token faltante: status = %d, want 401", code)
+	}
+	if code := register("otro"); code != 401 {
+		t.Fatalf("token incorrecto: status = %d, want 401", code)
+	}
+	if code := register("s3cret"); code != 200 {
+		t.Fatalf("token correcto: status = %d, want 200", code)
+	}
+}
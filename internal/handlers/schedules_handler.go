@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// ------------------------------------------------------------
+// /schedules/create?cron=EXPR&task=TASK&<params>[&name=&timezone=&priority=&labels=k=v,...]
+// ------------------------------------------------------------
+func SchedulesCreateHandler(req *types.Request) *types.Response {
+	labels, err := parseLabels(req.Query["labels"])
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+
+	params := queryToMap(req.Query)
+	for _, k := range []string{"cron", "task", "name", "timezone", "priority", "labels"} {
+		delete(params, k)
+	}
+
+	s, err := globalJobMgr.CreateSchedule(jobs.ScheduleSpec{
+		Name:     req.Query.Get("name"),
+		Cron:     req.Query.Get("cron"),
+		Timezone: req.Query.Get("timezone"),
+		Command:  req.Query.Get("task"),
+		Params:   params,
+		Priority: jobs.Priority(req.Query.Get("priority")),
+		Labels:   labels,
+	})
+	if err != nil {
+		return scheduleError(err)
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// ------------------------------------------------------------
+// /schedules/list
+// ------------------------------------------------------------
+func SchedulesListHandler(req *types.Request) *types.Response {
+	list := globalJobMgr.ListSchedules()
+	b, _ := json.MarshalIndent(map[string]interface{}{
+		"schedules": list,
+		"count":     len(list),
+	}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// ------------------------------------------------------------
+// /schedules/pause?id=ID  /schedules/resume?id=ID  /schedules/delete?id=ID
+// ------------------------------------------------------------
+func SchedulesPauseHandler(req *types.Request) *types.Response {
+	return scheduleAction(req, globalJobMgr.PauseSchedule)
+}
+
+func SchedulesResumeHandler(req *types.Request) *types.Response {
+	return scheduleAction(req, globalJobMgr.ResumeSchedule)
+}
+
+func SchedulesDeleteHandler(req *types.Request) *types.Response {
+	return scheduleAction(req, func(id string) (*jobs.Schedule, error) {
+		return nil, globalJobMgr.DeleteSchedule(id)
+	})
+}
+
+// scheduleAction valida el id, aplica fn y devuelve el schedule resultante.
+func scheduleAction(req *types.Request, fn func(id string) (*jobs.Schedule, error)) *types.Response {
+	id := req.Query.Get("id")
+	if id == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing id parameter"}`))
+	}
+	s, err := fn(id)
+	if err != nil {
+		return scheduleError(err)
+	}
+	if s == nil {
+		b, _ := json.MarshalIndent(map[string]string{"id": id, "status": "deleted"}, "", "  ")
+		return server.NewResponse(200, "OK", "application/json", b)
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+func scheduleError(err error) *types.Response {
+	msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+	switch {
+	case errors.Is(err, jobs.ErrScheduleNotFound):
+		return server.NewResponse(404, "Not Found", "application/json", msg)
+	case errors.Is(err, jobs.ErrInvalidSchedule):
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	default:
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+}
@@ -18,7 +18,7 @@ func SortFileHandler(req *types.Request) *types.Response {
 	}
 
 	jobFn := func(cancelCh <-chan struct{}) *types.Response {
-		return algorithms.SortFile(name, algo, cancelCh)
+		return algorithms.SortFile(name, algo, cancelCh, nil)
 	}
 
 	return workers.HandlePoolSubmit("sortfile", jobFn, workers.PriorityNormal)
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/tracing"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// TracesHandler maneja /debug/traces?limit=N (listado de trazas recientes)
+func TracesHandler(req *types.Request) *types.Response {
+	limit := 50
+	if v, err := strconv.Atoi(req.Query.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	body, _ := json.MarshalIndent(map[string]interface{}{
+		"traces": tracing.Recent(limit),
+	}, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", body)
+}
+
+// TraceHandler maneja /debug/traces/{id}[?format=otlp][&export=true]
+func TraceHandler(req *types.Request) *types.Response {
+	id := strings.TrimPrefix(req.Path, "/debug/traces/")
+	if id == "" || strings.Contains(id, "/") {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing or invalid trace id"}`))
+	}
+
+	trace, ok := tracing.Get(id)
+	if !ok {
+		return server.NewResponse(404, "Not Found", "application/json",
+			[]byte(`{"error":"trace not found"}`))
+	}
+
+	if exp := req.Query.Get("export"); exp == "true" || exp == "1" {
+		if !tracing.HasExporters() {
+			return server.NewResponse(409, "Conflict", "application/json",
+				[]byte(`{"error":"no trace exporter configured (TRACE_EXPORT_FILE / TRACE_EXPORT_URL)"}`))
+		}
+		if err := tracing.ExportNow(trace.Spans); err != nil {
+			msg := fmt.Sprintf(`{"error":"export failed: %s"}`, err.Error())
+			return server.NewResponse(502, "Bad Gateway", "application/json", []byte(msg))
+		}
+	}
+
+	if req.Query.Get("format") == "otlp" {
+		return server.NewResponse(200, "OK", "application/json", tracing.ToOTLP(trace.Spans))
+	}
+
+	body, _ := json.MarshalIndent(trace, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", body)
+}
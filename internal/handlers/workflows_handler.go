@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/server"
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// ------------------------------------------------------------
+// POST /workflows/submit   cuerpo: {"steps":[{"id","command","params","depends_on"}]}
+// ------------------------------------------------------------
+func WorkflowsSubmitHandler(req *types.Request) *types.Response {
+	if req.Method != "POST" {
+		resp := server.NewResponse(405, "Method Not Allowed", "application/json",
+			[]byte(`{"error":"use POST with a JSON body"}`))
+		resp.Headers["Allow"] = "POST"
+		return resp
+	}
+
+	var spec jobs.WorkflowSpec
+	if err := json.Unmarshal(req.Body, &spec); err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": "invalid JSON body: " + err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+
+	wf, err := globalJobMgr.SubmitWorkflow(spec, jobs.SubmitOptions{
+		TraceID:      req.TraceID,
+		ParentSpanID: req.SpanID,
+	})
+	if errors.Is(err, jobs.ErrInvalidWorkflow) {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(400, "Bad Request", "application/json", msg)
+	}
+	if errors.Is(err, jobs.ErrDraining) {
+		return drainingResponse(err)
+	}
+	if err != nil {
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+
+	b, _ := json.MarshalIndent(wf, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// ------------------------------------------------------------
+// /workflows/status?id=WORKFLOWID
+// ------------------------------------------------------------
+func WorkflowsStatusHandler(req *types.Request) *types.Response {
+	id := req.Query.Get("id")
+	if id == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing id parameter"}`))
+	}
+
+	wf, err := globalJobMgr.GetWorkflow(id)
+	if err != nil {
+		return server.NewResponse(404, "Not Found", "application/json",
+			[]byte(`{"error":"workflow not found"}`))
+	}
+	b, _ := json.MarshalIndent(wf, "", "  ")
+	return server.NewResponse(200, "OK", "application/json", b)
+}
+
+// ------------------------------------------------------------
+// /workflows/cancel?id=WORKFLOWID
+// ------------------------------------------------------------
+func WorkflowsCancelHandler(req *types.Request) *types.Response {
+	id := req.Query.Get("id")
+	if id == "" {
+		return server.NewResponse(400, "Bad Request", "application/json",
+			[]byte(`{"error":"missing id parameter"}`))
+	}
+
+	switch err := globalJobMgr.CancelWorkflow(id); {
+	case err == nil:
+		wf, _ := globalJobMgr.GetWorkflow(id)
+		b, _ := json.MarshalIndent(wf, "", "  ")
+		return server.NewResponse(200, "OK", "application/json", b)
+	case errors.Is(err, jobs.ErrWorkflowNotFound):
+		return server.NewResponse(404, "Not Found", "application/json",
+			[]byte(`{"error":"workflow not found"}`))
+	case errors.Is(err, jobs.ErrJobCancelled):
+		return server.NewResponse(409, "Conflict", "application/json",
+			[]byte(`{"error":"not cancelable"}`))
+	default:
+		msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return server.NewResponse(500, "Internal Server Error", "application/json", msg)
+	}
+}
@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ErrResultCorrupted is returned when a result blob does not match its checksum.
+var ErrResultCorrupted = errors.New("result blob checksum mismatch")
+
+// ResultRef points at a result body stored as a blob file. The rest of the
+// response is kept here so the blob holds only the raw body.
+type ResultRef struct {
+	SHA256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+	StatusCode  int    `json:"status_code"`
+	StatusText  string `json:"status_text"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// BlobStore keeps result bodies as content-addressed files: the name of each
+// file is the SHA-256 of its content, so identical results share one file.
+type BlobStore struct {
+	dir       string
+	threshold int
+}
+
+// OpenBlobStore creates dir if needed. Bodies of at least threshold bytes go
+// to blobs; smaller ones stay inline in JobMeta.Result.
+func OpenBlobStore(dir string, threshold int) (*BlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create results dir: %w", err)
+	}
+	return &BlobStore{dir: dir, threshold: threshold}, nil
+}
+
+func (b *BlobStore) path(sum string) string {
+	return filepath.Join(b.dir, sum[:2], sum)
+}
+
+// Put writes data unless a blob with the same content already exists.
+func (b *BlobStore) Put(data []byte) (string, error) {
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+	path := b.path(sum)
+	if info, err := os.Stat(path); err == nil && info.Size() == int64(len(data)) {
+		return sum, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("create blob dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), sum+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("create blob: %w", err)
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("write blob: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", fmt.Errorf("fsync blob: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("install blob: %w", err)
+	}
+	return sum, syncDir(filepath.Dir(path))
+}
+
+// Open returns the blob positioned at its start after verifying its size and
+// checksum. The caller closes the file.
+func (b *BlobStore) Open(ref *ResultRef) (*os.File, error) {
+	if len(ref.SHA256) != sha256.Size*2 {
+		return nil, ErrResultCorrupted
+	}
+	f, err := os.Open(b.path(ref.SHA256))
+	if err != nil {
+		return nil, fmt.Errorf("open blob: %w", err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read blob: %w", err)
+	}
+	if n != ref.Size || hex.EncodeToString(h.Sum(nil)) != ref.SHA256 {
+		f.Close()
+		return nil, ErrResultCorrupted
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// has reports whether the blob of ref is on disk with the expected size.
+func (b *BlobStore) has(ref *ResultRef) bool {
+	info, err := os.Stat(b.path(ref.SHA256))
+	return err == nil && info.Size() == ref.Size
+}
+
+// Remove deletes a blob. Missing blobs are not an error.
+func (b *BlobStore) Remove(sum string) error {
+	if len(sum) < 2 {
+		return nil
+	}
+	err := os.Remove(b.path(sum))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ConfigureResultBlobs makes the manager store result bodies of at least
+// threshold bytes in blobs instead of inline in the job record.
+func (j *JobManager) ConfigureResultBlobs(blobs *BlobStore) {
+	j.mu.Lock()
+	j.blobs = blobs
+	j.mu.Unlock()
+}
+
+// writeResultBlob writes res.Body to a blob when it is large enough, without
+// holding j.mu, so a large result does not stall the scheduler while it is
+// written and synced. Returns nil when the result stays inline, including on
+// blob errors.
+func (j *JobManager) writeResultBlob(meta *JobMeta, res *types.Response) *ResultRef {
+	j.mu.Lock()
+	blobs := j.blobs
+	j.mu.Unlock()
+	if blobs == nil || len(res.Body) < blobs.threshold {
+		return nil
+	}
+	sum, err := blobs.Put(res.Body)
+	if err != nil {
+		util.Warn("unable to store result blob, keeping it inline", util.Fields{"job_id": meta.ID, "error": err.Error()})
+		return nil
+	}
+	return &ResultRef{
+		SHA256:      sum,
+		Size:        int64(len(res.Body)),
+		StatusCode:  res.StatusCode,
+		StatusText:  res.StatusText,
+		ContentType: res.Headers["Content-Type"],
+	}
+}
+
+// storeResultLocked fills meta.ResultRef with the blob written by
+// writeResultBlob, or meta.Result when ref is nil.
+// Must be called with j.mu held.
+func (j *JobManager) storeResultLocked(meta *JobMeta, res *types.Response, ref *ResultRef) {
+	// a sweep may have removed a blob with the same content since it was
+	// written, as no job referenced it yet: write it again
+	if ref != nil && j.blobs != nil && !j.blobs.has(ref) {
+		if _, err := j.blobs.Put(res.Body); err != nil {
+			util.Warn("unable to store result blob, keeping it inline", util.Fields{"job_id": meta.ID, "error": err.Error()})
+			ref = nil
+		}
+	}
+	if ref != nil {
+		meta.ResultRef = ref
+		meta.Result = ""
+		return
+	}
+	b, _ := json.Marshal(res)
+	meta.Result = string(b)
+}
+
+// OpenResult returns the verified blob holding the result of a job that
+// stored its result by reference.
+func (j *JobManager) OpenResult(meta *JobMeta) (*os.File, error) {
+	j.mu.Lock()
+	blobs := j.blobs
+	j.mu.Unlock()
+	if blobs == nil || meta.ResultRef == nil {
+		return nil, errors.New("result not stored as blob")
+	}
+	return blobs.Open(meta.ResultRef)
+}
+
+// resultSize is the number of bytes a job's result takes, inline or in a blob.
+func resultSize(meta *JobMeta) int64 {
+	if meta.ResultRef != nil {
+		return meta.ResultRef.Size
+	}
+	return int64(len(meta.Result))
+}
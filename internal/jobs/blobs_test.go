@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+// Identical bodies share one blob, and Open refuses a blob whose content no
+// longer matches its checksum.
+func TestBlobStorePutOpen(t *testing.T) {
+	b, err := OpenBlobStore(t.TempDir(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("a large result body")
+	sum, err := b.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := b.Put(data); err != nil || again != sum {
+		t.Fatalf("second put = %s %v, want %s", again, err, sum)
+	}
+
+	ref := &ResultRef{SHA256: sum, Size: int64(len(data))}
+	f, err := b.Open(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(f)
+	f.Close()
+	if string(got) != string(data) {
+		t.Fatalf("blob = %q", got)
+	}
+
+	if err := os.WriteFile(b.path(sum), []byte("A large result body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Open(ref); !errors.Is(err, ErrResultCorrupted) {
+		t.Fatalf("tampered blob: %v", err)
+	}
+	if _, err := b.Open(&ResultRef{SHA256: "abc", Size: 3}); !errors.Is(err, ErrResultCorrupted) {
+		t.Fatalf("malformed checksum: %v", err)
+	}
+}
+
+// The sweeper drops a blob only once no remaining job points to it.
+func TestSweepRemovesUnreferencedBlobs(t *testing.T) {
+	b, err := OpenBlobStore(t.TempDir(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("shared result")
+	sum, err := b.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	now := time.Now()
+	for _, job := range []struct {
+		id  string
+		age time.Duration
+	}{{"old", 3 * time.Hour}, {"recent", 90 * time.Minute}, {"fresh", 0}} {
+		at := now.Add(-job.age)
+		store.Put(&JobMeta{ID: job.id, Status: StatusDone, CreatedAt: at, UpdatedAt: at,
+			ResultRef: &ResultRef{SHA256: sum, Size: int64(len(data)), StatusCode: 200}})
+	}
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureResultBlobs(b)
+
+	j.ConfigureRetention(RetentionPolicy{MaxAge: time.Hour})
+	if n := j.Sweep(); n != 2 {
+		t.Fatalf("expected 2 evictions, got %d", n)
+	}
+	if _, err := os.Stat(b.path(sum)); err != nil {
+		t.Fatalf("blob still referenced by fresh was removed: %v", err)
+	}
+
+	j.ConfigureRetention(RetentionPolicy{MaxCount: map[string]int{StatusDone: 1}})
+	store.Put(&JobMeta{ID: "newest", Status: StatusDone, CreatedAt: now, UpdatedAt: now.Add(time.Second), Result: "x"})
+	if n := j.Sweep(); n != 1 {
+		t.Fatalf("expected 1 eviction, got %d", n)
+	}
+	if _, err := os.Stat(b.path(sum)); !os.IsNotExist(err) {
+		t.Fatalf("unreferenced blob kept: %v", err)
+	}
+}
+
+// A large result is written to its blob before the manager lock is taken; if
+// a sweep removed that blob meanwhile it is written again when the job stores
+// its reference.
+func TestResultBlobWrittenBeforeLock(t *testing.T) {
+	b, err := OpenBlobStore(t.TempDir(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureResultBlobs(b)
+
+	meta := &JobMeta{ID: "big"}
+	res := &types.Response{StatusCode: 200, StatusText: "OK", Headers: map[string]string{"Content-Type": "text/plain"}, Body: []byte("large body")}
+	if ref := j.writeResultBlob(meta, &types.Response{Body: []byte("abc")}); ref != nil {
+		t.Fatalf("small body went to a blob: %+v", ref)
+	}
+	ref := j.writeResultBlob(meta, res)
+	if ref == nil || !b.has(ref) || ref.ContentType != "text/plain" {
+		t.Fatalf("blob not written: %+v", ref)
+	}
+
+	if err := b.Remove(ref.SHA256); err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Lock()
+	j.storeResultLocked(meta, res, ref)
+	j.mu.Unlock()
+	if meta.ResultRef != ref || meta.Result != "" || !b.has(ref) {
+		t.Fatalf("result = %+v %q, blob on disk: %v", meta.ResultRef, meta.Result, b.has(ref))
+	}
+}
@@ -0,0 +1,265 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ErrEmptySelector is returned by the bulk operations when the filter has no
+// label or owner, so a mistake cannot touch every job at once.
+var ErrEmptySelector = errors.New("selector needs at least one label or an owner")
+
+// Outcomes of a bulk operation on one job.
+const (
+	OutcomeCanceled      = "canceled"
+	OutcomeDeleted       = "deleted"
+	OutcomeReprioritized = "reprioritized"
+	OutcomeSkipped       = "skipped" // the job is not in a status the operation applies to
+	OutcomeFailed        = "failed"
+)
+
+// BulkResult is what a bulk operation did to one job.
+type BulkResult struct {
+	ID      string `json:"id"`
+	Outcome string `json:"outcome"`
+	Status  string `json:"status"`           // status of the job after the operation
+	Reason  string `json:"reason,omitempty"` // why it was skipped or failed
+}
+
+// jobIndex maps label pairs and owners to the jobs carrying them, finished
+// jobs included, so selectors do not scan the whole store. Labels and owner
+// never change after submission, so entries are only added and removed.
+// Must be used with j.mu held.
+type jobIndex struct {
+	labels map[string]map[string]struct{} // "key=value" -> job IDs
+	owners map[string]map[string]struct{}
+}
+
+func newJobIndex() *jobIndex {
+	return &jobIndex{
+		labels: make(map[string]map[string]struct{}),
+		owners: make(map[string]map[string]struct{}),
+	}
+}
+
+func indexAdd(m map[string]map[string]struct{}, key, id string) {
+	set, ok := m[key]
+	if !ok {
+		set = make(map[string]struct{})
+		m[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+func indexRemove(m map[string]map[string]struct{}, key, id string) {
+	if set, ok := m[key]; ok {
+		delete(set, id)
+		if len(set) == 0 {
+			delete(m, key)
+		}
+	}
+}
+
+func (x *jobIndex) add(meta *JobMeta) {
+	for k, v := range meta.Labels {
+		indexAdd(x.labels, k+"="+v, meta.ID)
+	}
+	if meta.Owner != "" {
+		indexAdd(x.owners, meta.Owner, meta.ID)
+	}
+}
+
+func (x *jobIndex) remove(meta *JobMeta) {
+	for k, v := range meta.Labels {
+		indexRemove(x.labels, k+"="+v, meta.ID)
+	}
+	if meta.Owner != "" {
+		indexRemove(x.owners, meta.Owner, meta.ID)
+	}
+}
+
+// lookup returns the IDs of the jobs with every label and the owner of the
+// filter, sorted. ok is false when the filter has neither.
+func (x *jobIndex) lookup(filter ListFilter) (ids []string, ok bool) {
+	var sets []map[string]struct{}
+	for k, v := range filter.Labels {
+		sets = append(sets, x.labels[k+"="+v])
+	}
+	if filter.Owner != "" {
+		sets = append(sets, x.owners[filter.Owner])
+	}
+	if len(sets) == 0 {
+		return nil, false
+	}
+	sort.Slice(sets, func(a, b int) bool { return len(sets[a]) < len(sets[b]) })
+	for id := range sets[0] {
+		in := true
+		for _, s := range sets[1:] {
+			if _, found := s[id]; !found {
+				in = false
+				break
+			}
+		}
+		if in {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, true
+}
+
+// selectLocked returns the jobs matching filter through the index, the
+// active ones as the live *JobMeta. ok is false when the filter cannot use
+// the index. Must be called with j.mu held.
+func (j *JobManager) selectLocked(filter ListFilter) (metas []*JobMeta, ok bool, err error) {
+	ids, ok := j.index.lookup(filter)
+	if !ok {
+		return nil, false, nil
+	}
+	for _, id := range ids {
+		meta, active := j.store[id]
+		if !active {
+			if meta, err = j.persist.Get(id); errors.Is(err, ErrJobNotFound) {
+				continue
+			} else if err != nil {
+				return nil, true, err
+			}
+		}
+		if filter.Matches(meta) {
+			metas = append(metas, meta)
+		}
+	}
+	return metas, true, nil
+}
+
+// bulk applies op to every job selected by filter. The whole operation holds
+// j.mu, so the dispatcher cannot pick a job half way through.
+func (j *JobManager) bulk(filter ListFilter, op func(meta *JobMeta) BulkResult) ([]BulkResult, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	metas, ok, err := j.selectLocked(filter)
+	if !ok {
+		return nil, ErrEmptySelector
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]BulkResult, 0, len(metas))
+	for _, meta := range metas {
+		results = append(results, op(meta))
+	}
+	return results, nil
+}
+
+func skipped(meta *JobMeta, reason string) BulkResult {
+	return BulkResult{ID: meta.ID, Outcome: OutcomeSkipped, Status: meta.Status, Reason: reason}
+}
+
+// BulkCancel cancels every queued or running job matching filter, which must
+// select by label or owner.
+func (j *JobManager) BulkCancel(filter ListFilter) ([]BulkResult, error) {
+	return j.bulk(filter, func(meta *JobMeta) BulkResult {
+		if isTerminal(meta.Status) {
+			return skipped(meta, "job already "+meta.Status)
+		}
+		if err := j.cancelLocked(meta.ID, ActorClient); err != nil {
+			return BulkResult{ID: meta.ID, Outcome: OutcomeFailed, Status: meta.Status, Reason: err.Error()}
+		}
+		return BulkResult{ID: meta.ID, Outcome: OutcomeCanceled, Status: meta.Status}
+	})
+}
+
+// BulkDelete removes every finished job matching filter from the store, along
+// with result blobs no other job uses. Deleted jobs answer 404 afterwards,
+// unlike expired ones. Queued and running jobs must be canceled first.
+func (j *JobManager) BulkDelete(filter ListFilter) ([]BulkResult, error) {
+	var blobs []string
+	results, err := j.bulk(filter, func(meta *JobMeta) BulkResult {
+		if !isTerminal(meta.Status) {
+			return skipped(meta, "job is "+meta.Status+", cancel it first")
+		}
+		if err := j.persist.Delete(meta.ID); err != nil {
+			return BulkResult{ID: meta.ID, Outcome: OutcomeFailed, Status: meta.Status, Reason: err.Error()}
+		}
+		j.index.remove(meta)
+		j.uncountFinished(meta)
+		if e, ok := j.idempotency[meta.IdempotencyKey]; ok && e.jobID == meta.ID {
+			delete(j.idempotency, meta.IdempotencyKey)
+		}
+		if meta.ResultRef != nil {
+			blobs = append(blobs, meta.ResultRef.SHA256)
+		}
+		return BulkResult{ID: meta.ID, Outcome: OutcomeDeleted, Status: meta.Status}
+	})
+	if err == nil && len(blobs) > 0 {
+		j.mu.Lock()
+		j.removeUnreferencedBlobsLocked(blobs)
+		j.mu.Unlock()
+	}
+	return results, err
+}
+
+// removeUnreferencedBlobsLocked drops the blobs in sums that no stored job
+// points to. Holding j.mu keeps finishing jobs from storing one meanwhile.
+func (j *JobManager) removeUnreferencedBlobsLocked(sums []string) {
+	if j.blobs == nil {
+		return
+	}
+	list, err := j.persist.List()
+	if err != nil {
+		util.Warn("unable to check result blob references", util.Fields{"error": err.Error()})
+		return
+	}
+	referenced := make(map[string]bool)
+	for _, meta := range list {
+		if meta.ResultRef != nil {
+			referenced[meta.ResultRef.SHA256] = true
+		}
+	}
+	for _, sum := range sums {
+		if referenced[sum] {
+			continue
+		}
+		referenced[sum] = true // shared by several deleted jobs
+		if err := j.blobs.Remove(sum); err != nil {
+			util.Warn("unable to remove result blob", util.Fields{"sha256": sum, "error": err.Error()})
+		}
+	}
+}
+
+// BulkReprioritize moves every queued or paused job matching filter to
+// priority. Jobs keep their place in line among the jobs of the new priority
+// by the time they were queued.
+func (j *JobManager) BulkReprioritize(filter ListFilter, priority Priority) ([]BulkResult, error) {
+	switch priority {
+	case PriorityHigh, PriorityNormal, PriorityLow:
+	default:
+		return nil, fmt.Errorf("invalid priority %q", priority)
+	}
+	return j.bulk(filter, func(meta *JobMeta) BulkResult {
+		if meta.Status != StatusQueued && meta.Status != StatusPaused {
+			return skipped(meta, "job is "+meta.Status)
+		}
+		if meta.Priority == priority {
+			return skipped(meta, "job is already "+string(priority))
+		}
+		j.setPriorityLocked(meta, priority)
+		return BulkResult{ID: meta.ID, Outcome: OutcomeReprioritized, Status: meta.Status}
+	})
+}
+
+// setPriorityLocked changes the priority of a queued or paused job, moving it
+// to the matching scheduler level if it is waiting there. Delayed and paused
+// jobs pick up the new priority when they enter the scheduler. Must be called with j.mu held.
+func (j *JobManager) setPriorityLocked(meta *JobMeta, priority Priority) {
+	queued := j.sched.remove(meta)
+	meta.Priority = priority
+	if queued {
+		j.sched.insert(meta, levelOf(priority))
+		j.sched.signal()
+	}
+	j.appendToJournal(meta)
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+func TestJobIndexLookup(t *testing.T) {
+	x := newJobIndex()
+	x.add(&JobMeta{ID: "a", Labels: map[string]string{"team": "x", "env": "prod"}, Owner: "ana"})
+	x.add(&JobMeta{ID: "b", Labels: map[string]string{"team": "x", "env": "dev"}, Owner: "ana"})
+	c := &JobMeta{ID: "c", Labels: map[string]string{"team": "x", "env": "prod"}, Owner: "bob"}
+	x.add(c)
+
+	ids, ok := x.lookup(ListFilter{Labels: map[string]string{"team": "x", "env": "prod"}})
+	if !ok || len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("team=x,env=prod -> %v %v", ids, ok)
+	}
+	if ids, _ := x.lookup(ListFilter{Labels: map[string]string{"team": "x"}, Owner: "ana"}); len(ids) != 2 {
+		t.Fatalf("team=x owner=ana -> %v", ids)
+	}
+	x.remove(c)
+	if ids, _ := x.lookup(ListFilter{Owner: "bob"}); len(ids) != 0 {
+		t.Fatalf("removed job still indexed: %v", ids)
+	}
+	if _, ok := x.lookup(ListFilter{Commands: []string{"reverse"}}); ok {
+		t.Fatal("a filter without labels or owner must not use the index")
+	}
+}
+
+// Bulk operations act on the jobs they apply to and report the others.
+func TestBulkOperations(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	team := map[string]string{"team": "a"}
+	done, err := j.SubmitWithOptions("reverse", map[string]string{"text": "abc"}, PriorityNormal, SubmitOptions{Labels: team, Owner: "ana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, finished, err := j.Wait(done, 5*time.Second); err != nil || !finished {
+		t.Fatalf("job not finished: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	delayed, err := j.SubmitWithOptions("reverse", map[string]string{"text": "def"}, PriorityLow, SubmitOptions{Labels: team, RunAt: &later})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := j.SubmitWithOptions("reverse", map[string]string{"text": "ghi"}, PriorityLow, SubmitOptions{Labels: map[string]string{"team": "b"}, RunAt: &later})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := j.BulkCancel(ListFilter{Commands: []string{"reverse"}}); !errors.Is(err, ErrEmptySelector) {
+		t.Fatalf("expected ErrEmptySelector, got %v", err)
+	}
+	if page, err := j.List(ListFilter{Owner: "ana"}, ListOptions{Limit: 10}); err != nil || len(page.Jobs) != 1 || page.Jobs[0].ID != done {
+		t.Fatalf("owner=ana -> %+v %v", page.Jobs, err)
+	}
+
+	outcomes := func(results []BulkResult) map[string]string {
+		out := make(map[string]string)
+		for _, r := range results {
+			out[r.ID] = r.Outcome
+		}
+		return out
+	}
+	check := func(op string, results []BulkResult, err error, want map[string]string) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+		got := outcomes(results)
+		if len(got) != len(want) {
+			t.Fatalf("%s: outcomes = %v, want %v", op, got, want)
+		}
+		for id, o := range want {
+			if got[id] != o {
+				t.Fatalf("%s: outcomes = %v, want %v", op, got, want)
+			}
+		}
+	}
+
+	results, err := j.BulkReprioritize(ListFilter{Labels: team}, PriorityHigh)
+	check("reprioritize", results, err, map[string]string{done: OutcomeSkipped, delayed: OutcomeReprioritized})
+	if meta, _ := j.GetMeta(delayed); meta.Priority != PriorityHigh {
+		t.Fatalf("priority = %s, want high", meta.Priority)
+	}
+
+	results, err = j.BulkCancel(ListFilter{Labels: team})
+	check("cancel", results, err, map[string]string{done: OutcomeSkipped, delayed: OutcomeCanceled})
+
+	results, err = j.BulkDelete(ListFilter{Labels: team})
+	check("delete", results, err, map[string]string{done: OutcomeDeleted, delayed: OutcomeDeleted})
+	for _, id := range []string{done, delayed} {
+		if _, err := j.GetMeta(id); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("deleted job %s: %v", id, err)
+		}
+	}
+	if meta, err := j.GetMeta(other); err != nil || meta.Status != StatusQueued || meta.Priority != PriorityLow {
+		t.Fatalf("job outside the selector changed: %+v %v", meta, err)
+	}
+}
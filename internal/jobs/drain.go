@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrDraining is returned for submissions refused because the manager, or
+// the command, is being drained.
+var ErrDraining = errors.New("not accepting jobs while draining")
+
+// DrainStatus reports the progress of a drain.
+type DrainStatus struct {
+	Draining bool     `json:"draining"`           // the whole manager refuses submissions
+	Commands []string `json:"commands,omitempty"` // commands drained on their own
+	// Remaining counts the queued and running jobs the drain waits for: every
+	// job when the whole manager drains, otherwise those of the drained
+	// commands. Paused jobs are not waited for.
+	Remaining int            `json:"remaining"`
+	Queued    int            `json:"queued"`
+	Running   int            `json:"running"`
+	Paused    int            `json:"paused"`
+	ByCommand map[string]int `json:"by_command,omitempty"`
+	Drained   bool           `json:"drained"` // draining and nothing remaining
+}
+
+// Drain stops accepting new jobs for command, or for every command when it is
+// empty. Queued and running jobs still run, and so do the later steps of
+// workflows already started.
+func (j *JobManager) Drain(command string) DrainStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if command == "" {
+		j.draining = true
+	} else {
+		j.drainingCmds[command] = true
+	}
+	return j.drainStatusLocked()
+}
+
+// Undrain accepts jobs for command again, or lifts the drain of the whole
+// manager when it is empty. Commands drained on their own stay drained.
+func (j *JobManager) Undrain(command string) DrainStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if command == "" {
+		j.draining = false
+	} else {
+		delete(j.drainingCmds, command)
+	}
+	return j.drainStatusLocked()
+}
+
+// DrainStatus returns the current drain and the jobs it is waiting for.
+func (j *JobManager) DrainStatus() DrainStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.drainStatusLocked()
+}
+
+// WaitDrained blocks until the drain completes, nothing is draining anymore or
+// timeout passes, and returns the last status.
+func (j *JobManager) WaitDrained(timeout time.Duration) DrainStatus {
+	sub := j.Subscribe(EventFilter{}, 64)
+	defer sub.Close()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		st := j.DrainStatus()
+		if st.Drained || (!st.Draining && len(st.Commands) == 0) {
+			return st
+		}
+		select {
+		case <-sub.C:
+		case <-timer.C:
+			return j.DrainStatus()
+		}
+	}
+}
+
+func (j *JobManager) drainStatusLocked() DrainStatus {
+	st := DrainStatus{Draining: j.draining}
+	for command := range j.drainingCmds {
+		st.Commands = append(st.Commands, command)
+	}
+	sort.Strings(st.Commands)
+	for _, meta := range j.store {
+		if !j.draining && !j.drainingCmds[meta.Command] {
+			continue
+		}
+		switch meta.Status {
+		case StatusQueued:
+			st.Queued++
+		case StatusRunning:
+			st.Running++
+		case StatusPaused:
+			st.Paused++
+			continue
+		default:
+			continue
+		}
+		if st.ByCommand == nil {
+			st.ByCommand = make(map[string]int)
+		}
+		st.ByCommand[meta.Command]++
+	}
+	st.Remaining = st.Queued + st.Running
+	st.Drained = (st.Draining || len(st.Commands) > 0) && st.Remaining == 0
+	return st
+}
+
+// checkDrainLocked refuses a submission for command during a drain, except
+// the step jobs of workflows already started. Must be called with j.mu held.
+func (j *JobManager) checkDrainLocked(command, actor string) error {
+	if actor == ActorWorkflow {
+		return nil
+	}
+	if j.draining {
+		return ErrDraining
+	}
+	if j.drainingCmds[command] {
+		return fmt.Errorf("%w: command %s", ErrDraining, command)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+func TestDrain(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	// hold the job in the queue so the drain has something to wait for
+	j.PauseQueue("reverse")
+	if _, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+
+	j.Drain("reverse")
+	if _, err := j.Submit("reverse", map[string]string{"text": "def"}, PriorityNormal); !errors.Is(err, ErrDraining) {
+		t.Fatalf("submit to a drained command: %v", err)
+	}
+	if _, err := j.Submit("toupper", map[string]string{"text": "def"}, PriorityNormal); err != nil {
+		t.Fatalf("submit to another command: %v", err)
+	}
+	st := j.WaitDrained(50 * time.Millisecond)
+	if st.Drained || st.Remaining != 1 || st.ByCommand["reverse"] != 1 {
+		t.Fatalf("drain status = %+v", st)
+	}
+
+	j.Drain("")
+	if _, err := j.Submit("toupper", map[string]string{"text": "def"}, PriorityNormal); !errors.Is(err, ErrDraining) {
+		t.Fatalf("submit while draining everything: %v", err)
+	}
+	j.ResumeQueue("reverse")
+	if st := j.WaitDrained(5 * time.Second); !st.Drained {
+		t.Fatalf("drain did not complete: %+v", st)
+	}
+
+	j.Undrain("")
+	if st := j.Undrain("reverse"); st.Draining || len(st.Commands) != 0 {
+		t.Fatalf("still draining: %+v", st)
+	}
+	if _, err := j.Submit("reverse", map[string]string{"text": "ghi"}, PriorityNormal); err != nil {
+		t.Fatalf("submit after undrain: %v", err)
+	}
+}
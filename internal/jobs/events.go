@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/progress"
+)
+
+// Event types published by the JobManager.
+const (
+	EventStatus   = "status"
+	EventProgress = "progress"
+)
+
+// Event describes a change of a job: a status transition or a progress
+// update reported by its algorithm.
+type Event struct {
+	Seq      uint64            `json:"seq"`
+	Type     string            `json:"type"`
+	JobID    string            `json:"job_id"`
+	Command  string            `json:"command"`
+	Priority Priority          `json:"priority"`
+	Status   string            `json:"status"`
+	Error    string            `json:"error,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Progress float64           `json:"progress,omitempty"` // fraction in [0,1]
+	Phase    string            `json:"phase,omitempty"`
+	EtaMs    int64             `json:"eta_ms,omitempty"`
+	At       time.Time         `json:"at"`
+}
+
+// EventFilter selects the events a subscription receives. An empty filter
+// matches every job.
+type EventFilter struct {
+	JobID  string
+	Labels map[string]string
+}
+
+func (f EventFilter) matches(e *Event) bool {
+	if f.JobID != "" && f.JobID != e.JobID {
+		return false
+	}
+	for k, v := range f.Labels {
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscription receives the events matching its filter on C until Close is
+// called. Events are dropped, and counted, when the subscriber falls behind
+// by more than the buffer size.
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	filter  EventFilter
+	hub     *eventHub
+	dropped int64
+	once    sync.Once
+}
+
+// Dropped returns how many events did not fit in the buffer.
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close stops the subscription. C is not closed, so pending receives keep
+// blocking; callers select on their own stop condition.
+func (s *Subscription) Close() {
+	s.once.Do(func() { s.hub.remove(s) })
+}
+
+// eventHistory is how many recent events are kept so a subscriber that
+// reconnects can resume from the last one it saw.
+const eventHistory = 1024
+
+// eventHub fans job events out to subscribers without blocking publishers.
+type eventHub struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	seq     uint64
+	history []Event // the last eventHistory events, oldest first
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[*Subscription]struct{})}
+}
+
+func (h *eventHub) add(filter EventFilter, buffer int) *Subscription {
+	s, _, _ := h.addSince(filter, buffer, 0, false)
+	return s
+}
+
+// addSince registers a subscriber and, when resume is set, returns the kept
+// events after seq that match its filter. complete is false when some of
+// those events are no longer kept, or seq was not issued by this hub.
+func (h *eventHub) addSince(filter EventFilter, buffer int, seq uint64, resume bool) (s *Subscription, missed []Event, complete bool) {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	ch := make(chan Event, buffer)
+	s = &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[s] = struct{}{}
+	if !resume {
+		return s, nil, true
+	}
+	first := h.seq - uint64(len(h.history)) + 1
+	if seq > h.seq || seq+1 < first {
+		return s, nil, false
+	}
+	for _, e := range h.history[seq+1-first:] {
+		if filter.matches(&e) {
+			missed = append(missed, e)
+		}
+	}
+	return s, missed, true
+}
+
+func (h *eventHub) remove(s *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+}
+
+func (h *eventHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e.Seq = h.seq
+	if len(h.history) == eventHistory {
+		h.history = h.history[1:] // append reallocates now and then, dropping the old array
+	}
+	h.history = append(h.history, e)
+	for s := range h.subs {
+		if !s.filter.matches(&e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the job events matching filter.
+// buffer is the number of events kept for a slow subscriber (64 if <= 0).
+func (j *JobManager) Subscribe(filter EventFilter, buffer int) *Subscription {
+	return j.events.add(filter, buffer)
+}
+
+// SubscribeSince is Subscribe for a subscriber resuming after the event with
+// sequence seq: missed holds the recent events after it that match filter.
+// complete is false when events after seq are no longer available, for
+// instance after a restart, and the subscriber must re-read the job state.
+func (j *JobManager) SubscribeSince(filter EventFilter, buffer int, seq uint64) (sub *Subscription, missed []Event, complete bool) {
+	return j.events.addSince(filter, buffer, seq, true)
+}
+
+// Subscribers returns the number of open subscriptions.
+func (j *JobManager) Subscribers() int {
+	return j.events.count()
+}
+
+// publishStatus announces the current status of meta. Must be called with j.mu held.
+func (j *JobManager) publishStatus(meta *JobMeta) {
+	j.events.publish(Event{
+		Type:     EventStatus,
+		JobID:    meta.ID,
+		Command:  meta.Command,
+		Priority: meta.Priority,
+		Status:   meta.Status,
+		Error:    meta.Error,
+		Labels:   meta.Labels,
+		At:       meta.UpdatedAt,
+	})
+}
+
+// progressNotifier returns the callback that turns progress updates of a
+// running job into events.
+func (j *JobManager) progressNotifier(meta *JobMeta) func(progress.Snapshot) {
+	return func(s progress.Snapshot) {
+		e := Event{
+			Type:     EventProgress,
+			JobID:    meta.ID,
+			Command:  meta.Command,
+			Priority: meta.Priority,
+			Status:   StatusRunning,
+			Labels:   meta.Labels,
+			Progress: s.Fraction,
+			Phase:    s.Phase,
+			At:       s.UpdatedAt,
+		}
+		if s.HasETA {
+			e.EtaMs = s.ETA.Milliseconds()
+		}
+		j.events.publish(e)
+	}
+}
+
+// Wait blocks until the job reaches a terminal state or timeout expires and
+// returns its latest state. done is false when the timeout expired first.
+func (j *JobManager) Wait(id string, timeout time.Duration) (meta *JobMeta, done bool, err error) {
+	// subscribe before reading the state so no transition is missed
+	sub := j.Subscribe(EventFilter{JobID: id}, 16)
+	defer sub.Close()
+
+	meta, err = j.GetMeta(id)
+	if err != nil || isTerminal(meta.Status) {
+		return meta, err == nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case e := <-sub.C:
+			if e.Type == EventStatus && isTerminal(e.Status) {
+				meta, err = j.GetMeta(id)
+				return meta, err == nil, err
+			}
+		case <-timer.C:
+			meta, err = j.GetMeta(id)
+			if err != nil {
+				return nil, false, err
+			}
+			return meta, isTerminal(meta.Status), nil
+		}
+	}
+}
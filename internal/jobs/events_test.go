@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// Wait returns as soon as the job finishes, well before its timeout.
+func TestWaitReturnsOnStatusChange(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.PauseQueue("reverse")
+	id, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, func() { j.ResumeQueue("reverse") })
+
+	start := time.Now()
+	meta, done, err := j.Wait(id, 10*time.Second)
+	if err != nil || !done || meta.Status != StatusDone {
+		t.Fatalf("wait = %+v done=%v %v", meta, done, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("wait took %v", elapsed)
+	}
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.PauseQueue("reverse")
+	id, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, done, err := j.Wait(id, 50*time.Millisecond)
+	if err != nil || done || meta.Status != StatusQueued {
+		t.Fatalf("wait = %+v done=%v %v", meta, done, err)
+	}
+	if n := j.Subscribers(); n != 0 {
+		t.Fatalf("wait left %d subscriptions open", n)
+	}
+}
+
+// A resuming subscriber gets the kept events after the one it saw, and is
+// told when some of them are gone.
+func TestSubscribeSinceReplaysHistory(t *testing.T) {
+	h := newEventHub()
+	for i := 0; i < eventHistory+10; i++ {
+		job := "a"
+		if i%2 == 1 {
+			job = "b"
+		}
+		h.publish(Event{Type: EventStatus, JobID: job})
+	}
+	last := h.seq
+
+	s, missed, complete := h.addSince(EventFilter{JobID: "a"}, 0, last-4, true)
+	defer s.Close()
+	if !complete || len(missed) != 2 || missed[0].Seq != last-3 || missed[1].Seq != last-1 {
+		t.Fatalf("missed = %+v complete=%v", missed, complete)
+	}
+	if _, missed, complete := h.addSince(EventFilter{}, 0, last, true); !complete || len(missed) != 0 {
+		t.Fatalf("up to date: missed = %+v complete=%v", missed, complete)
+	}
+	if _, missed, complete := h.addSince(EventFilter{}, 0, 5, true); complete || missed != nil {
+		t.Fatalf("evicted events: missed = %d complete=%v", len(missed), complete)
+	}
+	if _, _, complete := h.addSince(EventFilter{}, 0, last+1, true); complete {
+		t.Fatal("sequence from another process reported as complete")
+	}
+}
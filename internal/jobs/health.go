@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DispatcherHealth describes the liveness of the dispatcher goroutine.
+type DispatcherHealth struct {
+	Alive    bool      `json:"alive"`
+	LastBeat time.Time `json:"last_beat"`
+	StallMs  int64     `json:"stall_ms"`
+}
+
+// DispatcherHealth reports whether the dispatcher goroutine is running and
+// when it last went through its loop.
+func (j *JobManager) DispatcherHealth() DispatcherHealth {
+	beat := time.Unix(0, atomic.LoadInt64(&j.dispatcherBeat))
+	return DispatcherHealth{
+		Alive:    atomic.LoadInt32(&j.dispatcherAlive) == 1,
+		LastBeat: beat,
+		StallMs:  time.Since(beat).Milliseconds(),
+	}
+}
+
+// JournalHealth describes the state of the job store.
+type JournalHealth struct {
+	Path        string    `json:"path"`
+	Writable    bool      `json:"writable"`
+	LastSync    time.Time `json:"last_sync"`
+	SyncAgeMs   int64     `json:"sync_age_ms"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// recordJournalError remembers the last store failure. Must be called with j.mu held.
+func (j *JobManager) recordJournalError(err error) {
+	j.lastJournalErr = err
+	j.lastJournalErrAt = time.Now()
+}
+
+// CheckJournal verifies the job store is still writable. When the last fsync
+// is older than maxSyncAge, or the last write failed, it syncs the store again
+// so an idle but healthy store keeps reporting a recent sync and recovers from
+// transient errors. Stores that are not durable are always reported as
+// writable.
+func (j *JobManager) CheckJournal(maxSyncAge time.Duration) JournalHealth {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ds, durable := j.persist.(DurableStore)
+	if !durable {
+		return JournalHealth{Path: "memory", Writable: true, LastSync: time.Now()}
+	}
+
+	h := JournalHealth{Path: ds.Location()}
+	if err := ds.Probe(); err != nil {
+		j.recordJournalError(err)
+	} else if j.lastJournalErr != nil || time.Since(j.lastJournalSync) > maxSyncAge {
+		if err := ds.Sync(); err != nil {
+			j.recordJournalError(err)
+		} else {
+			j.lastJournalSync = time.Now()
+			j.lastJournalErr = nil
+		}
+	}
+
+	h.LastSync = j.lastJournalSync
+	h.SyncAgeMs = time.Since(j.lastJournalSync).Milliseconds()
+	if j.lastJournalErr != nil {
+		h.LastError = j.lastJournalErr.Error()
+		h.LastErrorAt = j.lastJournalErrAt
+	}
+	h.Writable = j.lastJournalErr == nil
+	return h
+}
+
+// QueueUsage returns the number of queued jobs and the configured maximum.
+func (j *JobManager) QueueUsage() (queued, max int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sched.size, j.maxQueueTotal
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused for a
+// submission with a different command or params.
+var ErrIdempotencyConflict = errors.New("idempotency key reused with different parameters")
+
+// DefaultIdempotencyTTL is how long a key is remembered unless configured.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyEntry is what the manager remembers of a key. Entries are
+// rebuilt on start from the IdempotencyKey and RequestHash of stored jobs,
+// tombstones included.
+type idempotencyEntry struct {
+	jobID     string
+	hash      string
+	createdAt time.Time
+}
+
+// ConfigureIdempotency sets how long idempotency keys are remembered. The
+// keys loaded on start were filtered with the previous window, so expired
+// ones are dropped and, when the window grows, older ones are reloaded.
+func (j *JobManager) ConfigureIdempotency(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	grown := ttl > j.idempotencyTTL
+	j.idempotencyTTL = ttl
+	j.pruneIdempotencyKeys(time.Now())
+	if !grown {
+		return
+	}
+	err := j.persist.Iterate(func(meta *JobMeta) bool {
+		j.rememberKey(meta)
+		return true
+	})
+	if err != nil {
+		util.Warn("unable to reload idempotency keys", util.Fields{"error": err.Error()})
+	}
+}
+
+// requestHash fingerprints what makes two submissions the same job.
+func requestHash(command string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	h.Write([]byte(command))
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		h.Write([]byte{'='})
+		h.Write([]byte(params[k]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// rememberKey indexes a stored job that was submitted with an idempotency
+// key. The key expires a window after the job was created, not after it was
+// loaded, and keys already past it are skipped. Must be called with j.mu held.
+func (j *JobManager) rememberKey(meta *JobMeta) {
+	if meta.IdempotencyKey == "" {
+		return
+	}
+	if time.Since(meta.CreatedAt) > j.idempotencyTTL {
+		return
+	}
+	if e, ok := j.idempotency[meta.IdempotencyKey]; ok && e.createdAt.After(meta.CreatedAt) {
+		return // reused after it expired: the newest job owns the key
+	}
+	j.idempotency[meta.IdempotencyKey] = idempotencyEntry{
+		jobID:     meta.ID,
+		hash:      meta.RequestHash,
+		createdAt: meta.CreatedAt,
+	}
+}
+
+// SubmitIdempotent is SubmitWithOptions honoring opts.IdempotencyKey: a key
+// seen within the retention window returns the job it created, with
+// replayed set, when command and params match, and ErrIdempotencyConflict
+// otherwise. Without a key it always submits.
+func (j *JobManager) SubmitIdempotent(command string, params map[string]string, priority Priority, opts SubmitOptions) (id string, replayed bool, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if opts.IdempotencyKey == "" {
+		id, err = j.submitLocked(command, params, priority, opts)
+		return id, false, err
+	}
+
+	hash := requestHash(command, params)
+	if e, ok := j.idempotency[opts.IdempotencyKey]; ok {
+		if time.Since(e.createdAt) <= j.idempotencyTTL {
+			if e.hash != hash {
+				return e.jobID, false, ErrIdempotencyConflict
+			}
+			return e.jobID, true, nil
+		}
+		delete(j.idempotency, opts.IdempotencyKey)
+	}
+
+	opts.requestHash = hash
+	id, err = j.submitLocked(command, params, priority, opts)
+	if err != nil {
+		return "", false, err
+	}
+	j.idempotency[opts.IdempotencyKey] = idempotencyEntry{jobID: id, hash: hash, createdAt: time.Now()}
+	return id, false, nil
+}
+
+// pruneIdempotencyKeys forgets keys older than the window. Must be called with j.mu held.
+func (j *JobManager) pruneIdempotencyKeys(now time.Time) {
+	for key, e := range j.idempotency {
+		if now.Sub(e.createdAt) > j.idempotencyTTL {
+			delete(j.idempotency, key)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// A repeated key returns the original job, a different payload under the same
+// key is a conflict, and keys are rebuilt from the store after a restart.
+func TestIdempotencyKeys(t *testing.T) {
+	store := NewMemoryStore()
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]string{"name": "a.txt", "content": "x"}
+	opts := SubmitOptions{IdempotencyKey: "k1"}
+
+	id, replayed, err := j.SubmitIdempotent("createfile", params, PriorityNormal, opts)
+	if err != nil || replayed {
+		t.Fatalf("first submit: %v replayed=%v", err, replayed)
+	}
+	again, replayed, err := j.SubmitIdempotent("createfile", map[string]string{"content": "x", "name": "a.txt"}, PriorityNormal, opts)
+	if err != nil || !replayed || again != id {
+		t.Fatalf("repeat submit: %v replayed=%v id=%s want %s", err, replayed, again, id)
+	}
+	if _, _, err := j.SubmitIdempotent("createfile", map[string]string{"name": "b.txt", "content": "x"}, PriorityNormal, opts); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	other, _, err := j.SubmitIdempotent("createfile", params, PriorityNormal, SubmitOptions{})
+	if err != nil || other == id {
+		t.Fatalf("submit without key must create a new job: %v", err)
+	}
+	j.Close()
+
+	j2, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j2.Close()
+	again, replayed, err = j2.SubmitIdempotent("createfile", params, PriorityNormal, opts)
+	if err != nil || !replayed || again != id {
+		t.Fatalf("key lost on restart: %v replayed=%v", err, replayed)
+	}
+}
+
+// After a restart a key expires a window after its job was created, and the
+// configured window, not the default one, decides which stored keys apply.
+func TestIdempotencyKeysAfterRestartKeepTheirAge(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	put := func(id, key string, age time.Duration) {
+		at := now.Add(-age)
+		store.Put(&JobMeta{ID: id, Command: "reverse", Params: map[string]string{"text": "a"}, Status: StatusDone,
+			CreatedAt: at, UpdatedAt: at, IdempotencyKey: key, RequestHash: requestHash("reverse", map[string]string{"text": "a"})})
+	}
+	put("recent", "k-recent", 10*time.Minute)
+	put("old", "k-old", 2*time.Hour)
+	put("ancient", "k-ancient", 30*time.Hour)
+	put("reused-before", "k-reused", 3*time.Hour)
+	put("reused-after", "k-reused", time.Minute)
+
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureIdempotency(time.Hour)
+	j.mu.Lock()
+	e, ok := j.idempotency["k-recent"]
+	_, oldKept := j.idempotency["k-old"]
+	reused := j.idempotency["k-reused"]
+	j.mu.Unlock()
+	if !ok || !e.createdAt.Equal(now.Add(-10*time.Minute)) {
+		t.Fatalf("k-recent = %+v %v, want the stored creation time", e, ok)
+	}
+	if oldKept {
+		t.Fatal("k-old is past the configured window but was kept")
+	}
+	if reused.jobID != "reused-after" {
+		t.Fatalf("k-reused points to %s, want the newest job", reused.jobID)
+	}
+
+	params := map[string]string{"text": "a"}
+	if id, replayed, err := j.SubmitIdempotent("reverse", params, PriorityNormal, SubmitOptions{IdempotencyKey: "k-old"}); err != nil || replayed || id == "old" {
+		t.Fatalf("expired key: id=%s replayed=%v %v", id, replayed, err)
+	}
+
+	j.ConfigureIdempotency(48 * time.Hour)
+	if id, replayed, err := j.SubmitIdempotent("reverse", params, PriorityNormal, SubmitOptions{IdempotencyKey: "k-ancient"}); err != nil || !replayed || id != "ancient" {
+		t.Fatalf("key within the larger window: id=%s replayed=%v %v", id, replayed, err)
+	}
+}
@@ -0,0 +1,508 @@
+package jobs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// maxJournalLine bounds a single journal or snapshot record when replaying.
+const maxJournalLine = 64 * 1024 * 1024
+
+// compactionRetryDelay keeps a failing size-triggered compaction from being
+// retried on every single append.
+const compactionRetryDelay = time.Minute
+
+// CompactionConfig controls when a store is compacted. Zero values disable
+// the corresponding trigger.
+type CompactionConfig struct {
+	MaxBytes int64         // compact once the store grows past this size
+	Interval time.Duration // compact periodically
+}
+
+// CompactionStats describes the compactions performed so far.
+type CompactionStats struct {
+	Count           int64     `json:"count"`
+	LastAt          time.Time `json:"last_at,omitempty"`
+	LastTrigger     string    `json:"last_trigger,omitempty"`
+	LastDurationMs  int64     `json:"last_duration_ms"`
+	LastJobs        int       `json:"last_jobs"`
+	LastBytesBefore int64     `json:"last_bytes_before"`
+	LastBytesAfter  int64     `json:"last_bytes_after"`
+	LastError       string    `json:"last_error,omitempty"`
+	JournalBytes    int64     `json:"journal_bytes"`
+	SnapshotBytes   int64     `json:"snapshot_bytes"`
+	Seq             uint64    `json:"seq"`
+
+	// segmented store only
+	Segments         int   `json:"segments,omitempty"`
+	CorruptedRecords int64 `json:"corrupted_records,omitempty"`
+}
+
+// snapshotHeader is the first line of the snapshot file.
+type snapshotHeader struct {
+	SnapshotSeq uint64    `json:"snapshot_seq"`
+	CreatedAt   time.Time `json:"created_at"`
+	Jobs        int       `json:"jobs"`
+}
+
+// journalRecord is one line of the journal: the full job state, or a
+// tombstone when Deleted is set.
+type journalRecord struct {
+	JobMeta
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// JournalStore persists jobs as an append-only JSONL journal. Every Put
+// appends the full job state; compaction folds the journal into a snapshot
+// holding the latest state per job and starts a fresh journal segment.
+//
+// The latest state of every job, inline Result included, is kept in memory,
+// so memory is bounded only by the retention policy (see retention.go) and by
+// moving large results to the BlobStore. With retention off it grows with
+// every job; SegmentedStore keeps only record offsets in memory instead.
+type JournalStore struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	bytes      int64
+	seq        uint64              // sequence number of the last record
+	latest     map[string]*JobMeta // full state of every stored job, see the type comment
+	compaction CompactionConfig
+	stats      CompactionStats
+	stop       chan struct{}
+	loopStop   chan struct{} // stops the running compaction loop, if any
+	wg         sync.WaitGroup
+}
+
+// OpenJournalStore opens (or creates) the journal at path and replays the
+// snapshot and journal into memory.
+func OpenJournalStore(path string) (*JournalStore, error) {
+	s := &JournalStore{
+		path:   path,
+		latest: make(map[string]*JobMeta),
+		stop:   make(chan struct{}),
+	}
+	s.cleanupCompactionLeftovers()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	s.file = f
+	if info, err := f.Stat(); err == nil {
+		s.bytes = info.Size()
+	}
+	if err := s.replay(); err != nil {
+		util.Warn("unable to replay journal", util.Fields{"journal": path, "error": err.Error()})
+	}
+	return s, nil
+}
+
+func (s *JournalStore) snapshotPath() string {
+	return s.path + ".snapshot"
+}
+
+func (s *JournalStore) replay() error {
+	snapSeq, hasSnapshot, err := s.loadSnapshot()
+	if err != nil {
+		util.Warn("unable to load journal snapshot", util.Fields{"snapshot": s.snapshotPath(), "error": err.Error()})
+	}
+	s.seq = snapSeq
+
+	_, err = s.file.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(s.file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJournalLine)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		// entries already folded into the snapshot are left over from an
+		// interrupted compaction and must not override newer state
+		if hasSnapshot && rec.Seq <= snapSeq {
+			continue
+		}
+		if rec.Seq > s.seq {
+			s.seq = rec.Seq
+		}
+		if rec.Deleted {
+			delete(s.latest, rec.ID)
+			continue
+		}
+		meta := rec.JobMeta
+		s.latest[meta.ID] = &meta
+	}
+	return scanner.Err()
+}
+
+func (s *JournalStore) Put(meta *JobMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	meta.Seq = s.seq
+	if err := s.appendLocked(meta); err != nil {
+		return err
+	}
+	s.latest[meta.ID] = meta.clone()
+	if s.shouldCompactForSize() {
+		s.compactLocked("size")
+	}
+	return nil
+}
+
+func (s *JournalStore) appendLocked(v interface{}) error {
+	if s.file == nil {
+		return errors.New("journal not open")
+	}
+	line, _ := json.Marshal(v)
+	n, werr := s.file.Write(append(line, '\n'))
+	s.bytes += int64(n)
+	if werr != nil {
+		return fmt.Errorf("journal write: %w", werr)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("journal fsync: %w", err)
+	}
+	return nil
+}
+
+func (s *JournalStore) Get(id string) (*JobMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, ok := s.latest[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return meta.clone(), nil
+}
+
+func (s *JournalStore) List() ([]*JobMeta, error) {
+	s.mu.Lock()
+	out := make([]*JobMeta, 0, len(s.latest))
+	for _, meta := range s.latest {
+		out = append(out, meta.clone())
+	}
+	s.mu.Unlock()
+	sortByCreation(out)
+	return out, nil
+}
+
+func (s *JournalStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.latest[id]; !ok {
+		return nil
+	}
+	s.seq++
+	if err := s.appendLocked(journalRecord{JobMeta: JobMeta{ID: id, Seq: s.seq}, Deleted: true}); err != nil {
+		return err
+	}
+	delete(s.latest, id)
+	return nil
+}
+
+func (s *JournalStore) Iterate(fn func(meta *JobMeta) bool) error {
+	list, _ := s.List()
+	for _, meta := range list {
+		if !fn(meta) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *JournalStore) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *JournalStore) Location() string {
+	return s.path
+}
+
+// Probe checks the journal is still writable.
+func (s *JournalStore) Probe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("journal not open")
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("stat journal: %w", err)
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return errors.New("journal is read-only")
+	}
+	return nil
+}
+
+func (s *JournalStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("journal not open")
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("fsync journal: %w", err)
+	}
+	return nil
+}
+
+// ConfigureCompaction sets the compaction triggers and starts the periodic
+// compaction loop when an interval is given.
+func (s *JournalStore) ConfigureCompaction(cfg CompactionConfig) {
+	s.mu.Lock()
+	s.compaction = cfg
+	if s.loopStop != nil {
+		close(s.loopStop) // configured again: the new interval replaces the old loop
+		s.loopStop = nil
+	}
+	var loopStop chan struct{}
+	if cfg.Interval > 0 {
+		loopStop = make(chan struct{})
+		s.loopStop = loopStop
+	}
+	s.mu.Unlock()
+	if loopStop != nil {
+		s.wg.Add(1)
+		go s.compactionLoop(cfg.Interval, loopStop)
+	}
+}
+
+func (s *JournalStore) compactionLoop(interval time.Duration, loopStop <-chan struct{}) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-loopStop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.compactLocked("interval")
+			s.mu.Unlock()
+		}
+	}
+}
+
+// shouldCompactForSize reports whether the size trigger fired. Must be called with s.mu held.
+func (s *JournalStore) shouldCompactForSize() bool {
+	if s.compaction.MaxBytes <= 0 || s.bytes < s.compaction.MaxBytes {
+		return false
+	}
+	if s.stats.LastError != "" && time.Since(s.stats.LastAt) < compactionRetryDelay {
+		return false
+	}
+	return true
+}
+
+// Compact forces a compaction of the journal.
+func (s *JournalStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked("manual")
+}
+
+// CompactionStats returns the compaction statistics and current file sizes.
+func (s *JournalStore) CompactionStats() CompactionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.JournalBytes = s.bytes
+	st.Seq = s.seq
+	if info, err := os.Stat(s.snapshotPath()); err == nil {
+		st.SnapshotBytes = info.Size()
+	}
+	return st
+}
+
+// compactLocked writes the latest state of every job to a snapshot and swaps
+// the journal for an empty segment. Must be called with s.mu held.
+//
+// The sequence is crash-safe: the snapshot is written to a temp file, fsynced
+// and renamed into place before the journal is replaced. Replay skips journal
+// records whose seq is covered by the snapshot, so a crash between the two
+// renames only leaves redundant records behind.
+func (s *JournalStore) compactLocked(trigger string) error {
+	if s.file == nil {
+		return errors.New("journal not open")
+	}
+	start := time.Now()
+	before := s.bytes
+
+	err := s.writeSnapshot()
+	if err == nil {
+		err = s.swapJournal()
+	}
+
+	s.stats.LastAt = start
+	s.stats.LastTrigger = trigger
+	s.stats.LastDurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		s.stats.LastError = err.Error()
+		util.Error("journal compaction failed", util.Fields{"trigger": trigger, "error": err.Error()})
+		return err
+	}
+	s.stats.Count++
+	s.stats.LastError = ""
+	s.stats.LastJobs = len(s.latest)
+	s.stats.LastBytesBefore = before
+	if info, err := os.Stat(s.snapshotPath()); err == nil {
+		s.stats.LastBytesAfter = info.Size()
+	}
+	util.Info("journal compacted", util.Fields{
+		"trigger":      trigger,
+		"jobs":         len(s.latest),
+		"bytes_before": before,
+		"bytes_after":  s.stats.LastBytesAfter,
+		"duration_ms":  s.stats.LastDurationMs,
+	})
+	return nil
+}
+
+func (s *JournalStore) writeSnapshot() error {
+	tmp := s.snapshotPath() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+
+	ids := make([]string, 0, len(s.latest))
+	for id := range s.latest {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.Encode(snapshotHeader{SnapshotSeq: s.seq, CreatedAt: time.Now(), Jobs: len(ids)})
+	for _, id := range ids {
+		if err := enc.Encode(s.latest[id]); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("write snapshot: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("fsync snapshot: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, s.snapshotPath()); err != nil {
+		return fmt.Errorf("install snapshot: %w", err)
+	}
+	return syncDir(filepath.Dir(s.path))
+}
+
+func (s *JournalStore) swapJournal() error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("create journal segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("fsync journal segment: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("install journal segment: %w", err)
+	}
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	nf, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("reopen journal: %w", err)
+	}
+	s.file.Close()
+	s.file = nf
+	s.bytes = 0
+	return nil
+}
+
+// loadSnapshot loads the snapshot and returns the sequence number it covers.
+func (s *JournalStore) loadSnapshot() (uint64, bool, error) {
+	f, err := os.Open(s.snapshotPath())
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJournalLine)
+	if !scanner.Scan() {
+		return 0, false, fmt.Errorf("empty snapshot")
+	}
+	var hdr snapshotHeader
+	if err := json.Unmarshal(scanner.Bytes(), &hdr); err != nil {
+		return 0, false, fmt.Errorf("invalid snapshot header: %w", err)
+	}
+	for scanner.Scan() {
+		var meta JobMeta
+		if err := json.Unmarshal(scanner.Bytes(), &meta); err == nil {
+			s.latest[meta.ID] = &meta
+		}
+	}
+	return hdr.SnapshotSeq, true, scanner.Err()
+}
+
+// cleanupCompactionLeftovers removes temp files of a compaction interrupted
+// by a crash. The snapshot temp file was never renamed, so it is not trusted.
+func (s *JournalStore) cleanupCompactionLeftovers() {
+	os.Remove(s.snapshotPath() + ".tmp")
+	os.Remove(s.path + ".tmp")
+}
+
+// syncDir fsyncs a directory so that renames inside it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("fsync dir: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// checkReconfigureStopsLoop configures a short compaction interval, then
+// disables it, and checks the first loop does not keep compacting.
+func checkReconfigureStopsLoop(t *testing.T, cs CompactingStore) {
+	t.Helper()
+	cs.ConfigureCompaction(CompactionConfig{Interval: 5 * time.Millisecond})
+	cs.ConfigureCompaction(CompactionConfig{Interval: 5 * time.Millisecond})
+	deadline := time.Now().Add(5 * time.Second)
+	for cs.CompactionStats().Count < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the compaction loop never ran")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cs.ConfigureCompaction(CompactionConfig{})
+	time.Sleep(20 * time.Millisecond) // a tick racing the reconfiguration may still run
+	count := cs.CompactionStats().Count
+	time.Sleep(50 * time.Millisecond)
+	if now := cs.CompactionStats().Count; now != count {
+		t.Fatalf("compactions went on after the interval was disabled: %d -> %d", count, now)
+	}
+}
+
+func TestJournalStoreReconfigureCompaction(t *testing.T) {
+	s, err := OpenJournalStore(filepath.Join(t.TempDir(), "jobs.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Put(&JobMeta{ID: "a", Status: StatusDone, CreatedAt: time.Now()})
+	checkReconfigureStopsLoop(t, s)
+}
@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned by List for a cursor it did not produce.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListFilter selects jobs. Empty fields match everything.
+type ListFilter struct {
+	Statuses      []string
+	Commands      []string
+	Priorities    []Priority
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Labels        map[string]string // every label must match
+	Owner         string
+}
+
+// ListOptions controls sorting and pagination.
+type ListOptions struct {
+	SortBy string // "created_at" (default) or "updated_at"
+	Desc   bool
+	Limit  int
+	Cursor string // NextCursor of the previous page
+}
+
+// ListPage is one page of List results.
+type ListPage struct {
+	Jobs       []*JobMeta
+	NextCursor string         // empty on the last page
+	Total      int            // jobs matching the filter across all pages
+	Summary    map[string]int // matching jobs per status across all pages
+}
+
+// Matches reports whether meta passes the filter.
+func (f ListFilter) Matches(meta *JobMeta) bool {
+	if len(f.Statuses) > 0 && !containsString(f.Statuses, meta.Status) {
+		return false
+	}
+	if len(f.Commands) > 0 && !containsString(f.Commands, meta.Command) {
+		return false
+	}
+	if len(f.Priorities) > 0 {
+		found := false
+		for _, p := range f.Priorities {
+			if p == meta.Priority {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.CreatedAfter.IsZero() && !meta.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !meta.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.Owner != "" && meta.Owner != f.Owner {
+		return false
+	}
+	for k, v := range f.Labels {
+		if meta.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the jobs matching filter, ordered and paginated by opts.
+// Expired tombstones are only listed when the filter asks for that status.
+// Pagination is keyset based, so jobs submitted between two calls do not
+// shift the following pages.
+func (j *JobManager) List(filter ListFilter, opts ListOptions) (ListPage, error) {
+	var page ListPage
+	after, hasCursor, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return page, err
+	}
+
+	all, err := j.selection(filter)
+	if err != nil {
+		return page, err
+	}
+
+	key := func(m *JobMeta) time.Time { return m.CreatedAt }
+	if opts.SortBy == "updated_at" {
+		key = func(m *JobMeta) time.Time { return m.UpdatedAt }
+	}
+	less := func(a, b *JobMeta) bool {
+		ka, kb := key(a), key(b)
+		if !ka.Equal(kb) {
+			if opts.Desc {
+				return ka.After(kb)
+			}
+			return ka.Before(kb)
+		}
+		if opts.Desc {
+			return a.ID > b.ID
+		}
+		return a.ID < b.ID
+	}
+
+	showExpired := containsString(filter.Statuses, StatusExpired)
+	page.Summary = make(map[string]int)
+	matched := make([]*JobMeta, 0)
+	for _, meta := range all {
+		if meta.Status == StatusExpired && !showExpired {
+			continue
+		}
+		if !filter.Matches(meta) {
+			continue
+		}
+		page.Summary[meta.Status]++
+		matched = append(matched, meta)
+	}
+	page.Total = len(matched)
+	sort.Slice(matched, func(a, b int) bool { return less(matched[a], matched[b]) })
+
+	start := 0
+	if hasCursor {
+		start = sort.Search(len(matched), func(i int) bool {
+			return less(&after, matched[i])
+		})
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = len(matched)
+	}
+	end := start + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	page.Jobs = matched[start:end]
+	if end < len(matched) {
+		page.NextCursor = encodeCursor(key(page.Jobs[len(page.Jobs)-1]), page.Jobs[len(page.Jobs)-1].ID)
+	}
+	return page, nil
+}
+
+// selection returns copies of the jobs the filter may match: only the indexed
+// ones when it selects by label or owner, every known job otherwise.
+func (j *JobManager) selection(filter ListFilter) ([]*JobMeta, error) {
+	j.mu.Lock()
+	metas, ok, err := j.selectLocked(filter)
+	for i, meta := range metas {
+		metas[i] = meta.clone()
+	}
+	j.mu.Unlock()
+	if !ok {
+		return j.snapshot()
+	}
+	return metas, err
+}
+
+// snapshot returns copies of every known job, with active jobs taken from
+// memory since they may be newer than the stored record.
+func (j *JobManager) snapshot() ([]*JobMeta, error) {
+	stored, err := j.persist.List()
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	seen := make(map[string]bool, len(j.store))
+	for i, meta := range stored {
+		if active, ok := j.store[meta.ID]; ok {
+			stored[i] = active.clone()
+			seen[meta.ID] = true
+		}
+	}
+	for id, meta := range j.store {
+		if !seen[id] {
+			stored = append(stored, meta.clone())
+		}
+	}
+	return stored, nil
+}
+
+// the cursor is the sort key and ID of the last job of the page
+func encodeCursor(t time.Time, id string) string {
+	raw := strconv.FormatInt(t.UnixNano(), 10) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(c string) (JobMeta, bool, error) {
+	var m JobMeta
+	if c == "" {
+		return m, false, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return m, false, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return m, false, ErrInvalidCursor
+	}
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return m, false, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	t := time.Unix(0, ns)
+	m.ID = parts[1]
+	m.CreatedAt, m.UpdatedAt = t, t
+	return m, true, nil
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listAll walks every page of List, calling between after each page.
+func listAll(t *testing.T, j *JobManager, opts ListOptions, between func(page int)) []string {
+	t.Helper()
+	var ids []string
+	for page := 0; ; page++ {
+		res, err := j.List(ListFilter{}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, meta := range res.Jobs {
+			ids = append(ids, meta.ID)
+		}
+		if res.NextCursor == "" {
+			return ids
+		}
+		if page > 20 {
+			t.Fatal("pagination does not end")
+		}
+		between(page)
+		opts.Cursor = res.NextCursor
+	}
+}
+
+// Pages are cut by keyset, so jobs added between two calls neither repeat nor
+// shift the jobs already on the way; only those past the cursor show up.
+func TestListKeysetPagination(t *testing.T) {
+	store := NewMemoryStore()
+	base := time.Now().Add(-time.Hour)
+	put := func(id string, minute int) {
+		at := base.Add(time.Duration(minute) * time.Minute)
+		store.Put(&JobMeta{ID: id, Command: "reverse", Status: StatusDone, CreatedAt: at, UpdatedAt: at})
+	}
+	for i, id := range []string{"d0", "d1", "d2", "d3", "d4", "d5"} {
+		put(id, 10+i)
+	}
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	asc := listAll(t, j, ListOptions{Limit: 2}, func(page int) {
+		if page == 0 {
+			put("early", 0) // before the cursor: must not appear
+			put("late", 30) // after the cursor: must appear last
+		}
+	})
+	if got := strings.Join(asc, ","); got != "d0,d1,d2,d3,d4,d5,late" {
+		t.Fatalf("ascending pages = %s", got)
+	}
+
+	desc := listAll(t, j, ListOptions{Limit: 2, Desc: true}, func(page int) {
+		if page == 0 {
+			put("newest", 40) // before the cursor in descending order
+		}
+	})
+	if got := strings.Join(desc, ","); got != "late,d5,d4,d3,d2,d1,d0,early" {
+		t.Fatalf("descending pages = %s", got)
+	}
+}
+
+// Expired tombstones are hidden unless the filter asks for them.
+func TestListHidesExpired(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.Put(&JobMeta{ID: "fresh", Status: StatusDone, CreatedAt: now, UpdatedAt: now})
+	store.Put(&JobMeta{ID: "stale", Status: StatusDone, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-2 * time.Hour)})
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureRetention(RetentionPolicy{MaxAge: time.Hour})
+	if n := j.Sweep(); n != 1 {
+		t.Fatalf("expected 1 eviction, got %d", n)
+	}
+
+	page, err := j.List(ListFilter{}, ListOptions{})
+	if err != nil || page.Total != 1 || page.Jobs[0].ID != "fresh" {
+		t.Fatalf("default listing = %+v %v", page, err)
+	}
+	page, err = j.List(ListFilter{Statuses: []string{StatusExpired}}, ListOptions{})
+	if err != nil || page.Total != 1 || page.Jobs[0].ID != "stale" || page.Summary[StatusExpired] != 1 {
+		t.Fatalf("expired listing = %+v %v", page, err)
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	for _, c := range []string{"%%%", base64.RawURLEncoding.EncodeToString([]byte("no-separator")), base64.RawURLEncoding.EncodeToString([]byte("x|id"))} {
+		if _, err := j.List(ListFilter{}, ListOptions{Cursor: c}); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("cursor %q: expected ErrInvalidCursor, got %v", c, err)
+		}
+	}
+}
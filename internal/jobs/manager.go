@@ -1,16 +1,16 @@
 package jobs
 
 import (
-	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
-	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/EngSteven/pso-http-server/internal/progress"
+	"github.com/EngSteven/pso-http-server/internal/tracing"
 	"github.com/EngSteven/pso-http-server/internal/types"
 	"github.com/EngSteven/pso-http-server/internal/util"
 	"github.com/EngSteven/pso-http-server/internal/workers"
@@ -45,81 +45,225 @@ func timeoutForCommand(cmd string) int {
 type JobManager struct {
 	mu sync.Mutex
 
-	// queues per priority
-	highQ, normalQ, lowQ chan *JobMeta
+	// queued jobs, see scheduler.go
+	sched *scheduler
+	index *jobIndex // labels and owners, see bulk.go
 
 	// metadata
-	store         map[string]*JobMeta
+	store         map[string]*JobMeta // active jobs; finished ones live only in persist
+	finished      map[string]map[Priority]int
+	tombstones    map[string]time.Time // evicted job ID -> expiry time, see retention.go
+	retention     RetentionPolicy
+	retentionStats RetentionStats
+	sweepStop     chan struct{} // stops the running sweeper, if any
+	blobs         *BlobStore // large results, see blobs.go
+
+	// completion callbacks, see webhooks.go
+	webhooks          WebhookConfig
+	webhooksOn        bool
+	pendingDeliveries []*JobMeta // waiting for ConfigureWebhooks
+
+	delayed       map[string]*time.Timer // jobs waiting for run_at or their next attempt, see schedule.go
+	workflows     map[string]*Workflow   // see workflow.go
+	wfRetry       map[string]*time.Timer // workflows waiting to submit a step refused by full queues
+	workflowsPath string
+	schedules     map[string]*Schedule // recurring jobs, see schedule.go
+	schedulesPath string
+	schedulesOn   bool
+	idempotency   map[string]idempotencyEntry // key -> job, see idempotency.go
+	idempotencyTTL time.Duration
+	draining      bool            // refuse every submission, see drain.go
+	drainingCmds  map[string]bool // commands refusing submissions
+	remoteCfg     RemoteConfig
+	remoteOn      bool
+	remoteWorkers map[string]*RemoteWorker // see remote.go
+	leases        map[string]*Lease        // job ID -> lease held by a remote worker
+	persist       JobStore
 	resChMap      map[string]chan *types.Response
 	cancelChMap   map[string]chan struct{}
-	journalPath   string
-	journalFile   *os.File
+	jobSpans      map[string]*tracing.Span
+	progress      map[string]*progress.Reporter // running jobs only
+	events        *eventHub                     // change notifications, see events.go
 	stop          chan struct{}
 	wg            sync.WaitGroup
 	maxQueueTotal int
+
+	// health state, see health.go
+	dispatcherAlive  int32
+	dispatcherBeat   int64 // unix nanos of the last dispatcher loop iteration
+	lastJournalSync  time.Time
+	lastJournalErr   error
+	lastJournalErrAt time.Time
 }
 
-// NewJobManager creates and starts dispatcher
+// NewJobManager opens the JSONL journal at journalPath and starts the dispatcher.
 func NewJobManager(journalPath string, qDepthPerPriority int, maxQueueTotal int) (*JobManager, error) {
+	store, err := OpenJournalStore(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewJobManagerWithStore(store, qDepthPerPriority, maxQueueTotal)
+}
+
+// NewJobManagerWithStore creates a manager on top of store, recovers the jobs
+// it holds and starts the dispatcher.
+func NewJobManagerWithStore(store JobStore, qDepthPerPriority int, maxQueueTotal int) (*JobManager, error) {
 	j := &JobManager{
-		highQ:         make(chan *JobMeta, qDepthPerPriority),
-		normalQ:       make(chan *JobMeta, qDepthPerPriority),
-		lowQ:          make(chan *JobMeta, qDepthPerPriority),
+		sched:         newScheduler(DefaultSchedulerConfig, 3*qDepthPerPriority),
+		index:         newJobIndex(),
 		store:         make(map[string]*JobMeta),
+		finished:      make(map[string]map[Priority]int),
+		tombstones:    make(map[string]time.Time),
+		persist:       store,
 		resChMap:      make(map[string]chan *types.Response),
 		cancelChMap:   make(map[string]chan struct{}),
-		journalPath:   journalPath,
+		jobSpans:      make(map[string]*tracing.Span),
+		progress:      make(map[string]*progress.Reporter),
+		delayed:       make(map[string]*time.Timer),
+		workflows:     make(map[string]*Workflow),
+		wfRetry:       make(map[string]*time.Timer),
+		schedules:     make(map[string]*Schedule),
+		idempotency:   make(map[string]idempotencyEntry),
+		idempotencyTTL: DefaultIdempotencyTTL,
+		drainingCmds:  make(map[string]bool),
+		remoteCfg:     DefaultRemoteConfig,
+		remoteWorkers: make(map[string]*RemoteWorker),
+		leases:        make(map[string]*Lease),
+		events:        newEventHub(),
 		stop:          make(chan struct{}),
 		maxQueueTotal: maxQueueTotal,
 	}
-
-	f, err := os.OpenFile(journalPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("open journal: %w", err)
-	}
-	j.journalFile = f
+	j.lastJournalSync = time.Now()
 
 	if err := j.rehydrate(); err != nil {
-		fmt.Printf("warning: unable to rehydrate jobs: %v\n", err)
+		util.Warn("unable to rehydrate jobs", util.Fields{"error": err.Error()})
 	}
+	j.recover()
 
 	j.wg.Add(1)
 	go j.dispatcher()
 	return j, nil
 }
 
+// rehydrate loads the active jobs from the store and counts the finished ones.
 func (j *JobManager) rehydrate() error {
-	f := j.journalFile
-	_, err := f.Seek(0, 0)
-	if err != nil {
-		return err
-	}
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		var meta JobMeta
-		if err := json.Unmarshal(scanner.Bytes(), &meta); err == nil {
-			j.store[meta.ID] = &meta
+	return j.persist.Iterate(func(meta *JobMeta) bool {
+		j.rememberKey(meta)
+		if meta.Status != StatusExpired {
+			j.index.add(meta)
 		}
+		switch {
+		case meta.Status == StatusExpired:
+			if meta.ExpiredAt != nil {
+				j.tombstones[meta.ID] = *meta.ExpiredAt
+			}
+		case isTerminal(meta.Status):
+			j.countFinished(meta)
+			if meta.DeliveryStatus == DeliveryPending {
+				j.pendingDeliveries = append(j.pendingDeliveries, meta)
+			}
+		default:
+			j.store[meta.ID] = meta
+		}
+		return true
+	})
+}
+
+// isTerminal reports whether a job in this status will not change anymore.
+func isTerminal(status string) bool {
+	switch status {
+	case StatusDone, StatusError, StatusCanceled, StatusTimeout:
+		return true
+	}
+	return false
+}
+
+func (j *JobManager) countFinished(meta *JobMeta) {
+	byPrio, ok := j.finished[meta.Status]
+	if !ok {
+		byPrio = make(map[Priority]int)
+		j.finished[meta.Status] = byPrio
 	}
-	return nil
+	byPrio[meta.Priority]++
 }
 
+// appendToJournal persists the current state of the job and notifies
+// subscribers. Jobs that reached a terminal state are dropped from memory and
+// served from the store.
+// Must be called with j.mu held.
 func (j *JobManager) appendToJournal(meta *JobMeta) {
-	if j.journalFile == nil {
-		return
+	start := time.Now()
+	if isTerminal(meta.Status) {
+		j.scheduleDelivery(meta)
+	}
+	if err := j.persist.Put(meta); err != nil {
+		j.recordJournalError(err)
+		util.Error("job store write failed", util.Fields{"job_id": meta.ID, "error": err.Error()})
+	} else {
+		j.lastJournalSync = time.Now()
+		j.lastJournalErr = nil
+	}
+	tracing.RecordSpan(meta.TraceID, meta.SpanID, "journal.fsync", start, time.Now(),
+		map[string]string{"job.status": meta.Status})
+	j.publishStatus(meta)
+	if meta.WorkflowID != "" {
+		j.onWorkflowJobLocked(meta)
+	}
+
+	if isTerminal(meta.Status) {
+		if _, ok := j.store[meta.ID]; ok {
+			delete(j.store, meta.ID)
+			j.countFinished(meta)
+		}
+		delete(j.progress, meta.ID)
+	}
+}
+
+// endJobSpan logs the outcome of a job and closes its lifetime span once it
+// reaches a terminal state.
+// Must be called with j.mu held.
+func (j *JobManager) endJobSpan(meta *JobMeta) {
+	fields := util.Fields{
+		"job_id":      meta.ID,
+		"command":     meta.Command,
+		"priority":    meta.Priority,
+		"status":      meta.Status,
+		"duration_ms": time.Since(meta.CreatedAt).Milliseconds(),
+		"trace_id":    meta.TraceID,
+	}
+	if meta.Error != "" {
+		fields["error"] = meta.Error
+		util.Warn("job finished", fields)
+	} else {
+		util.Info("job finished", fields)
+	}
+	if span, ok := j.jobSpans[meta.ID]; ok {
+		span.SetAttr("job.status", meta.Status)
+		if meta.Error != "" {
+			span.SetAttr("job.error", meta.Error)
+		}
+		span.End()
+		delete(j.jobSpans, meta.ID)
 	}
-	line, _ := json.Marshal(meta)
-	j.journalFile.Write(append(line, '\n'))
-	j.journalFile.Sync()
 }
 
 // Submit creates a job meta and enqueues it respecting priority
 func (j *JobManager) Submit(command string, params map[string]string, priority Priority) (string, error) {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	return j.SubmitWithOptions(command, params, priority, SubmitOptions{})
+}
+
+// SubmitWithOptions is Submit with optional settings such as the trace context.
+func (j *JobManager) SubmitWithOptions(command string, params map[string]string, priority Priority, opts SubmitOptions) (string, error) {
+	id, _, err := j.SubmitIdempotent(command, params, priority, opts)
+	return id, err
+}
 
-	total := len(j.highQ) + len(j.normalQ) + len(j.lowQ)
-	if total >= j.maxQueueTotal {
+// submitLocked creates and enqueues a job. Must be called with j.mu held.
+func (j *JobManager) submitLocked(command string, params map[string]string, priority Priority, opts SubmitOptions) (string, error) {
+	if err := j.checkDrainLocked(command, opts.Actor); err != nil {
+		return "", err
+	}
+	if j.sched.size >= j.maxQueueTotal {
 		// backpressure → reject and ask client to retry
 		retryAfter := workers.DefaultTimeoutFor(command)
 		return "", fmt.Errorf("queue full: retry_after_ms=%d", retryAfter)
@@ -130,305 +274,369 @@ func (j *JobManager) Submit(command string, params map[string]string, priority P
 		ID:         id,
 		Command:    command,
 		Params:     params,
+		Labels:     opts.Labels,
+		Owner:      opts.Owner,
+		CallbackURL: opts.CallbackURL,
+		Retry:      opts.Retry,
+		WorkflowID: opts.WorkflowID,
+		RunAt:      opts.RunAt,
+		IdempotencyKey: opts.IdempotencyKey,
+		RequestHash: opts.requestHash,
+		StepID:     opts.StepID,
 		Priority:   priority,
 		Status:     StatusQueued,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 		TimeoutMs:  timeoutForCommand(command),
+		enqueuedAt: time.Now(),
+	}
+	actor := opts.Actor
+	if actor == "" {
+		actor = ActorClient
 	}
+	meta.History = []Transition{{To: StatusQueued, At: meta.CreatedAt, Actor: actor, Reason: "submitted"}}
+
+	var span *tracing.Span
+	if opts.TraceID != "" {
+		span = tracing.StartSpan(opts.TraceID, opts.ParentSpanID, "job "+command)
+	} else {
+		span = tracing.StartTrace("", "job "+command, meta.CreatedAt)
+	}
+	span.SetAttr("job.id", id)
+	span.SetAttr("job.command", command)
+	span.SetAttr("job.priority", string(priority))
+	meta.TraceID, meta.SpanID = span.TraceID, span.SpanID
+
 	j.store[id] = meta
+	j.index.add(meta)
+	j.jobSpans[id] = span
+
+	if at, ok := meta.delayedUntil(); ok {
+		j.enqueueAfterLocked(meta, time.Until(at))
+	} else if !j.enqueueLocked(meta) { // enqueue respecting priority
+		// never persisted, so recovery cannot resurrect a rejected job
+		j.dropSubmitted(meta)
+		return "", ErrJobQueueFull
+	}
 	j.appendToJournal(meta)
+	util.Debug("job submitted", util.Fields{"job_id": id, "command": command, "priority": priority, "trace_id": meta.TraceID})
+	return id, nil
+}
 
-	// enqueue respecting priority
-	switch priority {
-	case PriorityHigh:
-		select {
-		case j.highQ <- meta:
-		default:
-			select {
-			case j.normalQ <- meta:
-			default:
-				select {
-				case j.lowQ <- meta:
-				default:
-					delete(j.store, id)
-					return "", ErrJobQueueFull
-				}
-			}
-		}
-	case PriorityNormal:
-		select {
-		case j.normalQ <- meta:
-		default:
-			select {
-			case j.lowQ <- meta:
-			default:
-				select {
-				case j.highQ <- meta:
-				default:
-					delete(j.store, id)
-					return "", ErrJobQueueFull
-				}
-			}
-		}
-	default:
-		select {
-		case j.lowQ <- meta:
-		default:
-			select {
-			case j.normalQ <- meta:
-			default:
-				delete(j.store, id)
-				return "", ErrJobQueueFull
-			}
-		}
+// enqueueLocked hands the job to the scheduler at its own priority.
+// Returns false if the scheduler is full. Must be called with j.mu held.
+func (j *JobManager) enqueueLocked(meta *JobMeta) bool {
+	return j.sched.add(meta)
+}
+
+// dropSubmitted forgets a job that could not be enqueued. Must be called with j.mu held.
+func (j *JobManager) dropSubmitted(meta *JobMeta) {
+	delete(j.store, meta.ID)
+	j.index.remove(meta)
+	if span, ok := j.jobSpans[meta.ID]; ok {
+		span.SetAttr("job.status", "rejected")
+		span.End()
+		delete(j.jobSpans, meta.ID)
 	}
-	return id, nil
 }
 
-// dispatcher consumes queues and dispatches to worker pools
+// dispatcher hands queued jobs to the worker pools in the order chosen by the
+// scheduler. It sleeps until a job is submitted, a blocked command may be
+// retried or the heartbeat is due.
 func (j *JobManager) dispatcher() {
 	defer j.wg.Done()
+	atomic.StoreInt32(&j.dispatcherAlive, 1)
+	defer atomic.StoreInt32(&j.dispatcherAlive, 0)
 	for {
+		atomic.StoreInt64(&j.dispatcherBeat, time.Now().UnixNano())
 		select {
 		case <-j.stop:
 			return
 		default:
-			var meta *JobMeta
-			// adaptive priority selection
-			switch {
-			case rand.Intn(100) < 50:
-				select {
-				case meta = <-j.highQ:
-				default:
-					select {
-					case meta = <-j.normalQ:
-					default:
-						select {
-						case meta = <-j.lowQ:
-						default:
-							time.Sleep(50 * time.Millisecond)
-							continue
-						}
-					}
-				}
-			default:
-				select {
-				case meta = <-j.normalQ:
-				default:
-					select {
-					case meta = <-j.highQ:
-					default:
-						select {
-						case meta = <-j.lowQ:
-						default:
-							time.Sleep(50 * time.Millisecond)
-							continue
-						}
-					}
-				}
-			}
-
-			if meta == nil {
-				continue
-			}
+		}
 
-			j.mu.Lock()
-			meta.Status = StatusRunning
-			meta.UpdatedAt = time.Now()
-			j.appendToJournal(meta)
+		j.mu.Lock()
+		meta, wait := j.sched.next(time.Now())
+		if meta == nil {
 			j.mu.Unlock()
-
-			pool := workers.GetPool(meta.Command)
-			if pool == nil {
-				res := j.executeCommandInline(meta)
-				j.updateJobResult(meta, res)
-				continue
+			if wait <= 0 || wait > dispatcherHeartbeat {
+				wait = dispatcherHeartbeat
 			}
-
-			jobFn := j.wrapJob(meta)
-
-			jobID, pResCh, cancelCh, err := pool.Enqueue(jobFn, workers.PriorityNormal)
-			if err != nil {
-				j.mu.Lock()
-				meta.Status = StatusQueued
-				meta.UpdatedAt = time.Now()
-				meta.Error = "pool full"
-				j.appendToJournal(meta)
-				j.mu.Unlock()
-				go func(m *JobMeta) {
-					time.Sleep(200 * time.Millisecond)
-					select { case j.normalQ <- m: default: }
-				}(meta)
-				continue
+			timer := time.NewTimer(wait)
+			select {
+			case <-j.stop:
+				timer.Stop()
+				return
+			case <-j.sched.wake:
+				timer.Stop()
+			case <-timer.C:
 			}
+			continue
+		}
+		j.dispatchLocked(meta)
+	}
+}
 
-			j.mu.Lock()
-			j.resChMap[jobID] = pResCh
-			j.cancelChMap[jobID] = cancelCh
-			j.mu.Unlock()
+// dispatchLocked starts meta on the pool of its command. A job whose pool is
+// full goes back to its backlog with its place and priority, and the command
+// is skipped for a while. Must be called with j.mu held; it is released.
+func (j *JobManager) dispatchLocked(meta *JobMeta) {
+	if !CanTransition(meta.Status, StatusRunning) {
+		j.mu.Unlock()
+		return // canceled while waiting in the queue
+	}
+	pickedAt := time.Now()
+	pool := workers.GetPool(meta.Command)
+	if pool == nil {
+		j.markRunningLocked(meta, pickedAt, "no pool, running inline")
+		j.mu.Unlock()
+		res := j.executeCommandInline(meta)
+		j.updateJobResult(meta, res)
+		return
+	}
 
-			go j.waitForResult(meta, jobID, pResCh, cancelCh)
-		}
+	// the pool cannot start the job before j.mu is released, see wrapJob
+	jobFn := j.wrapJob(meta, pickedAt)
+	jobID, pResCh, cancelCh, err := pool.Enqueue(jobFn, workers.PriorityNormal)
+	tracing.RecordSpan(meta.TraceID, meta.SpanID, "jobs.dispatch", pickedAt, time.Now(),
+		map[string]string{"pool": meta.Command, "pool.accepted": strconv.FormatBool(err == nil)})
+	if err != nil {
+		util.Debug("pool full, job kept in backlog", util.Fields{"job_id": meta.ID, "command": meta.Command})
+		j.sched.insert(meta, levelOf(meta.Priority))
+		j.sched.block(meta.Command, pickedAt)
+		j.mu.Unlock()
+		return
 	}
+
+	j.markRunningLocked(meta, pickedAt, "dispatched to pool "+meta.Command)
+	j.resChMap[meta.ID] = pResCh
+	j.cancelChMap[meta.ID] = cancelCh
+	j.mu.Unlock()
+
+	util.Debug("job dispatched", util.Fields{"job_id": meta.ID, "command": meta.Command, "pool_job_id": jobID})
+	go j.waitForResult(meta, pResCh)
 }
 
-func (j *JobManager) waitForResult(meta *JobMeta, id string, pch chan *types.Response, cancelCh chan struct{}) {
+// markRunningLocked records that meta left the queue. Must be called with j.mu held.
+func (j *JobManager) markRunningLocked(meta *JobMeta, pickedAt time.Time, reason string) {
+	tracing.RecordSpan(meta.TraceID, meta.SpanID, "jobs.queue", meta.enqueuedAt, pickedAt,
+		map[string]string{"job.priority": string(meta.Priority)})
+	j.transitionLocked(meta, StatusRunning, ActorDispatcher, reason)
+	meta.Attempt++
+	startedAt := meta.UpdatedAt
+	meta.StartedAt = &startedAt
+	j.appendToJournal(meta)
+}
+
+func (j *JobManager) waitForResult(meta *JobMeta, pch chan *types.Response) {
 	timeout := time.Duration(meta.TimeoutMs) * time.Millisecond
 	select {
 	case res := <-pch:
+		j.mu.Lock()
+		j.sched.unblock(meta.Command) // its worker is free again
+		j.mu.Unlock()
 		j.updateJobResult(meta, res)
 	case <-time.After(timeout):
-		close(cancelCh)
 		j.mu.Lock()
-		meta.Status = StatusTimeout
-		meta.Error = fmt.Sprintf("timed out after %d ms", meta.TimeoutMs)
-		meta.UpdatedAt = time.Now()
-		j.appendToJournal(meta)
-		delete(j.resChMap, id)
-		delete(j.cancelChMap, id)
+		if cancelCh, ok := j.cancelChMap[meta.ID]; ok {
+			close(cancelCh) // not closed yet by Cancel
+		}
+		delete(j.resChMap, meta.ID)
+		delete(j.cancelChMap, meta.ID)
+		if meta.Status != StatusRunning {
+			j.mu.Unlock()
+			return // canceled meanwhile
+		}
+		j.finishAttemptLocked(meta, StatusTimeout, fmt.Sprintf("timed out after %d ms", meta.TimeoutMs), 0, ActorWatchdog)
 		j.mu.Unlock()
 	}
 }
 
 /*Algoritmos de los jobs*/
 
-func (j *JobManager) wrapJob(meta *JobMeta) workers.JobFunc {
+// wrapJob adapts a job to the pool's JobFunc, recording the time spent waiting
+// in the pool queue and executing as trace spans.
+func (j *JobManager) wrapJob(meta *JobMeta, poolEnqueuedAt time.Time) workers.JobFunc {
 	return func(cancelCh <-chan struct{}) *types.Response {
-		switch meta.Command {
-		case "fibonacci":
-			n, _ := strconv.Atoi(meta.Params["num"])
-			return algorithms.CalculateFibonacci(n, cancelCh)
-		
-		case "createfile":
-			name := meta.Params["name"]
-			content := meta.Params["content"]
-			repeat := 1
-			if r, ok := meta.Params["repeat"]; ok {
-				if v, err := strconv.Atoi(r); err == nil && v > 0 {
-					repeat = v
-				}
+		startedAt := time.Now()
+		tracing.RecordSpan(meta.TraceID, meta.SpanID, "pool.queue", poolEnqueuedAt, startedAt,
+			map[string]string{"pool": meta.Command})
+		rep := progress.New()
+		rep.OnChange(j.progressNotifier(meta))
+		j.mu.Lock()
+		// a job canceled or timed out while queued in the pool is already
+		// terminal and its progress entry gone; do not bring it back
+		if meta.Status == StatusRunning {
+			j.progress[meta.ID] = rep
+		}
+		j.mu.Unlock()
+		res := runCommand(meta, cancelCh, rep)
+		j.mu.Lock()
+		if j.progress[meta.ID] == rep {
+			delete(j.progress, meta.ID)
+		}
+		j.mu.Unlock()
+		attrs := map[string]string{"job.command": meta.Command}
+		if res != nil {
+			attrs["result.status_code"] = strconv.Itoa(res.StatusCode)
+		}
+		tracing.RecordSpan(meta.TraceID, meta.SpanID, "job.execute", startedAt, time.Now(), attrs)
+		return res
+	}
+}
+
+// runCommand executes the algorithm registered for meta.Command. Algorithms
+// that know how far along they are report it through rep.
+func runCommand(meta *JobMeta, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
+	switch meta.Command {
+	case "fibonacci":
+		n, _ := strconv.Atoi(meta.Params["num"])
+		return algorithms.CalculateFibonacci(n, cancelCh)
+	
+	case "createfile":
+		name := meta.Params["name"]
+		content := meta.Params["content"]
+		repeat := 1
+		if r, ok := meta.Params["repeat"]; ok {
+			if v, err := strconv.Atoi(r); err == nil && v > 0 {
+				repeat = v
 			}
-			return algorithms.CreateFile(name, content, repeat, cancelCh)
-
-		case "deletefile":
-			name := meta.Params["name"]
-			return algorithms.DeleteFile(name, cancelCh)
-
-		case "reverse":
-			text := meta.Params["text"]
-			return algorithms.ReverseText(text, cancelCh)
-
-		case "toupper":
-			text := meta.Params["text"]
-			return algorithms.ToUpper(text, cancelCh)
-
-		case "random":
-			count, _ := strconv.Atoi(meta.Params["count"])
-			min, _ := strconv.Atoi(meta.Params["min"])
-			max, _ := strconv.Atoi(meta.Params["max"])
-			return algorithms.GenerateRandom(count, min, max, cancelCh)
-
-		case "timestamp":
-			return algorithms.GetTimestamp(cancelCh)
-
-		case "hash":
-			text := meta.Params["text"]
-			return algorithms.HashText(text, cancelCh)
-
-		case "simulate":
-			seconds, _ := strconv.Atoi(meta.Params["seconds"])
-			taskName := meta.Params["task"]
-			return algorithms.SimulateWork(seconds, taskName, cancelCh)
-
-		case "sleep":
-			seconds, _ := strconv.Atoi(meta.Params["seconds"])
-			return algorithms.Sleep(seconds, cancelCh)
-		
-		case "loadtest":
-			taskCount, _ := strconv.Atoi(meta.Params["tasks"])
-			sleepSeconds, _ := strconv.Atoi(meta.Params["sleep"])
-			return algorithms.LoadTest(taskCount, sleepSeconds, cancelCh)
-		
-		
-		//-------------------------------CPU Bound---------------------------
-		
-
-		case "isprime":
-			n, _ := strconv.ParseInt(meta.Params["n"], 10, 64)
-			method := meta.Params["method"]
-			return algorithms.IsPrime(n, method, cancelCh)
-
-		case "factor":
-			n, _ := strconv.ParseInt(meta.Params["n"], 10, 64)
-			return algorithms.Factorize(n, cancelCh)
-
-		case "pi":
-			digits, _ := strconv.Atoi(meta.Params["digits"])
-			return algorithms.CalculatePi(digits, cancelCh)
-
-
-		case "mandelbrot":
-			width, _ := strconv.Atoi(meta.Params["width"])
-			height, _ := strconv.Atoi(meta.Params["height"])
-			maxIter, _ := strconv.Atoi(meta.Params["max_iter"])
-			save := meta.Params["save"] == "true" || meta.Params["save"] == "1"
-			return algorithms.Mandelbrot(width, height, maxIter, save, cancelCh)
-
-		case "matrixmul":
-			size, _ := strconv.Atoi(meta.Params["size"])
-			seed, _ := strconv.ParseInt(meta.Params["seed"], 10, 64)
-			return algorithms.MatrixMultiply(size, seed, cancelCh)
-
-		
-		//-------------------------------IO Bound---------------------------
-		
-		case "sortfile":
-			name := meta.Params["name"]
-			algo := meta.Params["algo"]
-			return algorithms.SortFile(name, algo, cancelCh)
-		
-		case "wordcount":
-			name := meta.Params["name"]
-			return algorithms.WordCount(name, cancelCh)
-
-		case "grep":
-			name := meta.Params["name"]
-			pattern := meta.Params["pattern"]
-			return algorithms.Grep(name, pattern, cancelCh)
-
-		case "hashfile":
-			name := meta.Params["name"]
-			algo := meta.Params["algo"]
-			return algorithms.HashFile(name, algo, cancelCh)
-
-		case "compress":
-			name := meta.Params["name"]
-			codec := meta.Params["codec"]
-			return algorithms.CompressFile(name, codec, cancelCh)
+		}
+		return algorithms.CreateFile(name, content, repeat, cancelCh)
+
+	case "deletefile":
+		name := meta.Params["name"]
+		return algorithms.DeleteFile(name, cancelCh)
+
+	case "reverse":
+		text := meta.Params["text"]
+		return algorithms.ReverseText(text, cancelCh)
+
+	case "toupper":
+		text := meta.Params["text"]
+		return algorithms.ToUpper(text, cancelCh)
+
+	case "random":
+		count, _ := strconv.Atoi(meta.Params["count"])
+		min, _ := strconv.Atoi(meta.Params["min"])
+		max, _ := strconv.Atoi(meta.Params["max"])
+		return algorithms.GenerateRandom(count, min, max, cancelCh)
+
+	case "timestamp":
+		return algorithms.GetTimestamp(cancelCh)
+
+	case "hash":
+		text := meta.Params["text"]
+		return algorithms.HashText(text, cancelCh)
+
+	case "simulate":
+		seconds, _ := strconv.Atoi(meta.Params["seconds"])
+		taskName := meta.Params["task"]
+		return algorithms.SimulateWork(seconds, taskName, cancelCh)
+
+	case "sleep":
+		seconds, _ := strconv.Atoi(meta.Params["seconds"])
+		return algorithms.Sleep(seconds, cancelCh)
+	
+	case "loadtest":
+		taskCount, _ := strconv.Atoi(meta.Params["tasks"])
+		sleepSeconds, _ := strconv.Atoi(meta.Params["sleep"])
+		return algorithms.LoadTest(taskCount, sleepSeconds, cancelCh)
+	
+	
+	//-------------------------------CPU Bound---------------------------
+	
+
+	case "isprime":
+		n, _ := strconv.ParseInt(meta.Params["n"], 10, 64)
+		method := meta.Params["method"]
+		return algorithms.IsPrime(n, method, cancelCh)
+
+	case "factor":
+		n, _ := strconv.ParseInt(meta.Params["n"], 10, 64)
+		return algorithms.Factorize(n, cancelCh)
+
+	case "pi":
+		digits, _ := strconv.Atoi(meta.Params["digits"])
+		return algorithms.CalculatePi(digits, cancelCh, rep)
+
+
+	case "mandelbrot":
+		width, _ := strconv.Atoi(meta.Params["width"])
+		height, _ := strconv.Atoi(meta.Params["height"])
+		maxIter, _ := strconv.Atoi(meta.Params["max_iter"])
+		save := meta.Params["save"] == "true" || meta.Params["save"] == "1"
+		return algorithms.Mandelbrot(width, height, maxIter, save, cancelCh, rep)
+
+	case "matrixmul":
+		size, _ := strconv.Atoi(meta.Params["size"])
+		seed, _ := strconv.ParseInt(meta.Params["seed"], 10, 64)
+		return algorithms.MatrixMultiply(size, seed, cancelCh, rep)
+
+	
+	//-------------------------------IO Bound---------------------------
+	
+	case "sortfile":
+		name := meta.Params["name"]
+		algo := meta.Params["algo"]
+		return algorithms.SortFile(name, algo, cancelCh, rep)
+	
+	case "wordcount":
+		name := meta.Params["name"]
+		return algorithms.WordCount(name, cancelCh)
+
+	case "grep":
+		name := meta.Params["name"]
+		pattern := meta.Params["pattern"]
+		return algorithms.Grep(name, pattern, cancelCh)
+
+	case "hashfile":
+		name := meta.Params["name"]
+		algo := meta.Params["algo"]
+		return algorithms.HashFile(name, algo, cancelCh)
+
+	case "compress":
+		name := meta.Params["name"]
+		codec := meta.Params["codec"]
+		return algorithms.CompressFile(name, codec, cancelCh, rep)
 
 
-		default:
-			return j.newResponse(400, "Bad Request", "application/json", []byte(`{"error":"unknown command"}`))
-		}
+	default:
+		return &types.Response{StatusCode: 400, StatusText: "Bad Request",
+			Headers: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{"error":"unknown command"}`)}
 	}
 }
 
 func (j *JobManager) updateJobResult(meta *JobMeta, res *types.Response) {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	var msg string
+	var failed bool
+	var ref *ResultRef
 	if res != nil {
-		b, _ := json.Marshal(res)
-		meta.Result = string(b)
-		meta.Status = StatusDone
-	} else {
-		meta.Error = "nil response"
-		meta.Status = StatusError
+		if msg, failed = failedResult(res); !failed {
+			// a large body is written and synced before taking j.mu
+			ref = j.writeResultBlob(meta, res)
+		}
 	}
-	meta.UpdatedAt = time.Now()
-	j.appendToJournal(meta)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	delete(j.resChMap, meta.ID)
 	delete(j.cancelChMap, meta.ID)
+	if meta.Status != StatusRunning {
+		// canceled while running, the late result is discarded
+		if ref != nil {
+			j.removeUnreferencedBlobsLocked([]string{ref.SHA256})
+		}
+		return
+	}
+	if res == nil {
+		j.finishAttemptLocked(meta, StatusError, "nil response", 0, ActorWorker)
+	} else if failed {
+		j.finishAttemptLocked(meta, StatusError, msg, res.StatusCode, ActorWorker)
+	} else {
+		j.storeResultLocked(meta, res, ref)
+		j.finishAttemptLocked(meta, StatusDone, "", 0, ActorWorker)
+	}
 }
 
 func (j *JobManager) newResponse(statusCode int, status, ctype string, body []byte) *types.Response {
@@ -465,39 +673,101 @@ func (j *JobManager) GetMeta(id string) (*JobMeta, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	if meta, ok := j.store[id]; ok {
-		c := *meta
-		return &c, nil
+		return meta.clone(), nil
+	}
+	if err := j.expiredError(id); err != nil {
+		return nil, err
+	}
+	return j.persist.Get(id)
+}
+
+// Progress returns the progress reported by a running job. ok is false when
+// the job is not running in a pool.
+func (j *JobManager) Progress(id string) (snap progress.Snapshot, ok bool) {
+	j.mu.Lock()
+	rep, ok := j.progress[id]
+	j.mu.Unlock()
+	if !ok {
+		return snap, false
+	}
+	return rep.Snapshot(), true
+}
+
+// CountsByStatus returns how many jobs are known per status and priority.
+func (j *JobManager) CountsByStatus() map[string]map[Priority]int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make(map[string]map[Priority]int)
+	for status, byPrio := range j.finished {
+		out[status] = make(map[Priority]int, len(byPrio))
+		for p, n := range byPrio {
+			out[status][p] = n
+		}
 	}
-	return nil, ErrJobNotFound
+	for _, meta := range j.store {
+		byPrio, ok := out[meta.Status]
+		if !ok {
+			byPrio = make(map[Priority]int)
+			out[meta.Status] = byPrio
+		}
+		byPrio[meta.Priority]++
+	}
+	return out
+}
+
+// QueueLengths returns the number of queued jobs by priority.
+func (j *JobManager) QueueLengths() map[Priority]int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sched.lengths()
 }
 
 func (j *JobManager) Cancel(id string) error {
 	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cancelLocked(id, ActorClient)
+}
+
+// cancelLocked stops a queued or running job on behalf of actor.
+// Must be called with j.mu held.
+func (j *JobManager) cancelLocked(id, actor string) error {
 	meta, ok := j.store[id]
 	if !ok {
-		j.mu.Unlock()
+		if err := j.expiredError(id); err != nil {
+			return err
+		}
+		if _, err := j.persist.Get(id); err == nil {
+			return ErrJobCancelled
+		}
 		return ErrJobNotFound
 	}
-	if meta.Status == StatusDone || meta.Status == StatusError || meta.Status == StatusCanceled {
-		j.mu.Unlock()
+	if isTerminal(meta.Status) {
 		return ErrJobCancelled
 	}
-	if meta.Status == StatusQueued {
-		meta.Status = StatusCanceled
-		meta.UpdatedAt = time.Now()
+	if meta.Status == StatusQueued || meta.Status == StatusPaused {
+		if err := j.transitionLocked(meta, StatusCanceled, actor, "canceled before dispatch"); err != nil {
+			return err
+		}
+		j.stopDelayedLocked(id)
+		j.sched.remove(meta)
+		meta.RetryAt = nil
 		meta.Error = "canceled before dispatch"
 		j.appendToJournal(meta)
-		j.mu.Unlock()
+		j.endJobSpan(meta)
 		return nil
 	}
 	if cancelCh, ok := j.cancelChMap[id]; ok {
+		if err := j.transitionLocked(meta, StatusCanceled, actor, "canceled while running"); err != nil {
+			return err
+		}
 		close(cancelCh)
-		meta.Status = StatusCanceled
-		meta.UpdatedAt = time.Now()
+		delete(j.cancelChMap, id)
 		j.appendToJournal(meta)
-		j.mu.Unlock()
+		j.endJobSpan(meta)
 		return nil
 	}
-	j.mu.Unlock()
+	if leased, err := j.cancelLeasedLocked(meta, actor); leased {
+		return err
+	}
 	return ErrJobCancelled
 }
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// A job canceled while it waits in the pool queue still has its function run
+// by the pool; that run must not leave a progress reporter behind.
+func TestCanceledInPoolLeavesNoProgress(t *testing.T) {
+	pool := workers.InitPool("sleep", 1, 4)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	processed := pool.Info().TotalProcessed
+	params := map[string]string{"seconds": "5"}
+	busy, err := j.Submit("sleep", params, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := j.Submit("sleep", params, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		meta, _ := j.GetMeta(queued)
+		if meta.Status == StatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("second job never reached the pool: %+v", meta)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := j.Cancel(queued); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Cancel(busy); err != nil {
+		t.Fatal(err)
+	}
+
+	for pool.Info().TotalProcessed < processed+2 {
+		if time.Now().After(deadline) {
+			t.Fatal("pool did not run both jobs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, id := range []string{busy, queued} {
+		if snap, ok := j.Progress(id); ok {
+			t.Errorf("job %s still reports progress %+v", id, snap)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrJobNotQueued = errors.New("job is not queued")
+	ErrJobNotPaused = errors.New("job is not paused")
+)
+
+// PauseStats counts the work held back by pauses.
+type PauseStats struct {
+	Jobs   int            `json:"jobs"`   // jobs paused one by one
+	Queues map[string]int `json:"queues"` // paused command -> queued jobs held in its backlog
+}
+
+// PauseJob takes a queued job out of the queue until ResumeJob. A delayed job
+// keeps its run_at or retry time and waits for it again once resumed.
+func (j *JobManager) PauseJob(id string) (*JobMeta, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	meta, err := j.activeLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Status != StatusQueued {
+		return nil, fmt.Errorf("%w: job is %s", ErrJobNotQueued, meta.Status)
+	}
+	if err := j.transitionLocked(meta, StatusPaused, ActorClient, "paused"); err != nil {
+		return nil, err
+	}
+	j.stopDelayedLocked(id)
+	j.sched.remove(meta)
+	j.appendToJournal(meta)
+	return meta.clone(), nil
+}
+
+// ResumeJob puts a paused job back at the end of its priority queue. It fails
+// with ErrJobQueueFull when the queues have no room, leaving the job paused.
+func (j *JobManager) ResumeJob(id string) (*JobMeta, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	meta, err := j.activeLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Status != StatusPaused {
+		return nil, fmt.Errorf("%w: job is %s", ErrJobNotPaused, meta.Status)
+	}
+	_, delayed := meta.delayedUntil()
+	if !delayed && (j.sched.size >= j.maxQueueTotal || j.sched.size >= j.sched.capacity) {
+		return nil, ErrJobQueueFull
+	}
+	if err := j.transitionLocked(meta, StatusQueued, ActorClient, "resumed"); err != nil {
+		return nil, err
+	}
+	meta.enqueuedAt = time.Now()
+	if at, ok := meta.delayedUntil(); ok {
+		j.enqueueAfterLocked(meta, time.Until(at))
+	} else {
+		j.enqueueLocked(meta)
+	}
+	j.appendToJournal(meta)
+	return meta.clone(), nil
+}
+
+// SetPriority changes the priority of a queued or paused job. A queued job
+// moves to its new level keeping the time it was queued.
+func (j *JobManager) SetPriority(id string, priority Priority) (*JobMeta, error) {
+	switch priority {
+	case PriorityHigh, PriorityNormal, PriorityLow:
+	default:
+		return nil, fmt.Errorf("invalid priority %q", priority)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	meta, err := j.activeLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Status != StatusQueued && meta.Status != StatusPaused {
+		return nil, fmt.Errorf("%w: job is %s", ErrJobNotQueued, meta.Status)
+	}
+	if meta.Priority != priority {
+		j.setPriorityLocked(meta, priority)
+	}
+	return meta.clone(), nil
+}
+
+// activeLocked returns the live meta of a job that has not finished, or the
+// error a lookup of the job would give. Must be called with j.mu held.
+func (j *JobManager) activeLocked(id string) (*JobMeta, error) {
+	if meta, ok := j.store[id]; ok {
+		return meta, nil
+	}
+	if err := j.expiredError(id); err != nil {
+		return nil, err
+	}
+	meta, err := j.persist.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: job is %s", ErrJobNotQueued, meta.Status)
+}
+
+// PauseQueue stops dispatching the jobs of command. Submissions are still
+// accepted and wait in the backlog until ResumeQueue. Paused queues are not
+// persisted: a restart resumes them.
+func (j *JobManager) PauseQueue(command string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.sched.paused[command] = true
+}
+
+// ResumeQueue dispatches the backlog of command again.
+func (j *JobManager) ResumeQueue(command string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.sched.paused, command)
+	j.sched.signal()
+}
+
+// PauseStats returns the paused jobs and the backlog of every paused queue.
+func (j *JobManager) PauseStats() PauseStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stats := PauseStats{Queues: make(map[string]int, len(j.sched.paused))}
+	for _, meta := range j.store {
+		if meta.Status == StatusPaused {
+			stats.Jobs++
+		}
+	}
+	backlogs := j.sched.commandBacklogs()
+	for command := range j.sched.paused {
+		stats.Queues[command] = backlogs[command]
+	}
+	return stats
+}
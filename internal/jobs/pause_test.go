@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// A paused queue accepts jobs without dispatching them, and a paused job
+// stays out of the queue until resumed.
+func TestPauseAndResume(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	j.PauseQueue("reverse")
+	a, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := j.Submit("reverse", map[string]string{"text": "def"}, PriorityLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, done, _ := j.Wait(a, 100*time.Millisecond); done {
+		t.Fatal("job of a paused queue was dispatched")
+	}
+
+	if meta, err := j.PauseJob(b); err != nil || meta.Status != StatusPaused {
+		t.Fatalf("pause: %+v %v", meta, err)
+	}
+	if _, err := j.PauseJob(b); !errors.Is(err, ErrJobNotQueued) {
+		t.Fatalf("pausing twice: %v", err)
+	}
+	if meta, err := j.SetPriority(b, PriorityHigh); err != nil || meta.Priority != PriorityHigh {
+		t.Fatalf("set priority: %+v %v", meta, err)
+	}
+	stats := j.PauseStats()
+	if stats.Jobs != 1 || stats.Queues["reverse"] != 1 {
+		t.Fatalf("pause stats = %+v", stats)
+	}
+
+	j.ResumeQueue("reverse")
+	if meta, done, _ := j.Wait(a, 5*time.Second); !done || meta.Status != StatusDone {
+		t.Fatalf("job not dispatched after resuming the queue: %+v", meta)
+	}
+	if _, done, _ := j.Wait(b, 100*time.Millisecond); done {
+		t.Fatal("paused job was dispatched")
+	}
+	if meta, err := j.ResumeJob(b); err != nil || meta.Status != StatusQueued {
+		t.Fatalf("resume: %+v %v", meta, err)
+	}
+	meta, done, _ := j.Wait(b, 5*time.Second)
+	if !done || meta.Status != StatusDone || meta.Priority != PriorityHigh {
+		t.Fatalf("resumed job = %+v", meta)
+	}
+	if _, err := j.ResumeJob(b); !errors.Is(err, ErrJobNotQueued) {
+		t.Fatalf("resuming a finished job: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/tracing"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ErrInterruptedByRestart is recorded on non-idempotent jobs that were running
+// when the process stopped.
+const ErrInterruptedByRestart = "interrupted by restart"
+
+// idempotentCommands tells recovery whether a job that was running when the
+// process died can safely be run again. Commands missing from the map are
+// treated as non-idempotent.
+var (
+	idempotentMu       sync.RWMutex
+	idempotentCommands = map[string]bool{
+		"fibonacci":  true,
+		"reverse":    true,
+		"toupper":    true,
+		"random":     true,
+		"timestamp":  true,
+		"hash":       true,
+		"simulate":   true,
+		"sleep":      true,
+		"loadtest":   true,
+		"isprime":    true,
+		"factor":     true,
+		"pi":         true,
+		"matrixmul":  true,
+		"mandelbrot": true,
+		"sortfile":   true,
+		"wordcount":  true,
+		"grep":       true,
+		"hashfile":   true,
+		"compress":   true,
+		"createfile": false,
+		"deletefile": false,
+	}
+)
+
+// SetIdempotent marks whether a command can be re-run after a restart.
+func SetIdempotent(command string, idempotent bool) {
+	idempotentMu.Lock()
+	idempotentCommands[command] = idempotent
+	idempotentMu.Unlock()
+}
+
+// IsIdempotent reports whether a command can be re-run after a restart.
+func IsIdempotent(command string) bool {
+	idempotentMu.RLock()
+	defer idempotentMu.RUnlock()
+	return idempotentCommands[command]
+}
+
+// recover puts rehydrated jobs back to work: queued jobs return to their
+// priority queue, running jobs are re-queued when their command is idempotent
+// and otherwise fail with ErrInterruptedByRestart. Every transition is
+// written to the journal.
+func (j *JobManager) recover() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending := make([]*JobMeta, 0)
+	for _, meta := range j.store {
+		if meta.Status == StatusQueued || meta.Status == StatusRunning {
+			pending = append(pending, meta)
+		}
+	}
+	// oldest first, so FIFO order within a priority is preserved
+	sort.Slice(pending, func(a, b int) bool { return pending[a].CreatedAt.Before(pending[b].CreatedAt) })
+
+	var requeued, rerun, interrupted, dropped int
+	for _, meta := range pending {
+		wasRunning := meta.Status == StatusRunning
+		meta.UpdatedAt = time.Now()
+
+		if wasRunning && !IsIdempotent(meta.Command) {
+			j.transitionLocked(meta, StatusError, ActorRecovery, ErrInterruptedByRestart)
+			meta.Error = ErrInterruptedByRestart
+			j.appendToJournal(meta)
+			interrupted++
+			util.Warn("job interrupted by restart", util.Fields{"job_id": meta.ID, "command": meta.Command})
+			continue
+		}
+
+		if wasRunning {
+			j.transitionLocked(meta, StatusQueued, ActorRecovery, "running when the server stopped, run again")
+		}
+		meta.Error = ""
+		meta.enqueuedAt = time.Now()
+		if at, ok := meta.delayedUntil(); ok && !wasRunning {
+			// delayed or waiting for its next attempt: keep the remaining delay
+			j.enqueueAfterLocked(meta, time.Until(at))
+			j.appendToJournal(meta)
+			requeued++
+			continue
+		}
+		var span *tracing.Span
+		if meta.TraceID != "" {
+			span = tracing.StartSpan(meta.TraceID, meta.SpanID, "job.recovered")
+		} else {
+			span = tracing.StartTrace("", "job.recovered", meta.UpdatedAt)
+			meta.TraceID, meta.SpanID = span.TraceID, span.SpanID
+		}
+		span.SetAttr("job.id", meta.ID)
+		span.SetAttr("job.was_running", strconv.FormatBool(wasRunning))
+		if !j.enqueueLocked(meta) {
+			j.transitionLocked(meta, StatusError, ActorRecovery, "queue full during recovery")
+			meta.Error = "queue full during recovery"
+			j.appendToJournal(meta)
+			span.SetAttr("job.status", meta.Status)
+			span.End()
+			dropped++
+			continue
+		}
+		j.jobSpans[meta.ID] = span
+		j.appendToJournal(meta)
+		if wasRunning {
+			rerun++
+		} else {
+			requeued++
+		}
+	}
+
+	if len(pending) > 0 {
+		util.Info("job recovery finished", util.Fields{
+			"requeued":    requeued,
+			"rerun":       rerun,
+			"interrupted": interrupted,
+			"dropped":     dropped,
+		})
+	}
+}
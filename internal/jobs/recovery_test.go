@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// Queued jobs are enqueued again after a restart, running ones are re-run when
+// their command is idempotent and fail with ErrInterruptedByRestart otherwise.
+func TestRecoverAfterRestart(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	store := NewMemoryStore()
+	created := time.Now().Add(-time.Minute)
+	seed := func(id, command string, status string) {
+		meta := &JobMeta{
+			ID:        id,
+			Command:   command,
+			Params:    map[string]string{"text": "abc", "name": "recovered.txt"},
+			Priority:  PriorityNormal,
+			Status:    status,
+			CreatedAt: created,
+			UpdatedAt: created,
+			TimeoutMs: 5000,
+			History:   []Transition{{To: StatusQueued, At: created, Actor: ActorClient, Reason: "submitted"}},
+		}
+		if status == StatusRunning {
+			meta.History = append(meta.History, Transition{From: StatusQueued, To: StatusRunning, At: created, Actor: ActorDispatcher})
+		}
+		if err := store.Put(meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed("queued", "reverse", StatusQueued)
+	seed("running", "reverse", StatusRunning)
+	seed("unsafe", "createfile", StatusRunning)
+
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	for _, id := range []string{"queued", "running"} {
+		meta, done, _ := j.Wait(id, 5*time.Second)
+		if !done || meta.Status != StatusDone {
+			t.Fatalf("%s after recovery = %+v", id, meta)
+		}
+	}
+	meta, _ := j.GetMeta("running")
+	if h := meta.History; len(h) < 3 || h[2].Actor != ActorRecovery || h[2].To != StatusQueued {
+		t.Fatalf("re-run job history = %+v", meta.History)
+	}
+	meta, err = j.GetMeta("unsafe")
+	if err != nil || meta.Status != StatusError || meta.Error != ErrInterruptedByRestart {
+		t.Fatalf("non-idempotent job after recovery = %+v %v", meta, err)
+	}
+}
+
+// A submit rejected because the queue is full leaves nothing in the store, so
+// a restart does not run a job the client was told was refused.
+func TestRejectedSubmitNotRecovered(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	store := NewMemoryStore()
+	j, err := NewJobManagerWithStore(store, 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.PauseQueue("reverse")
+	var accepted int
+	for {
+		_, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal)
+		if errors.Is(err, ErrJobQueueFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		accepted++
+	}
+	j.Close()
+
+	stored, err := store.List()
+	if err != nil || len(stored) != accepted {
+		t.Fatalf("store holds %d jobs, %d were accepted (%v)", len(stored), accepted, err)
+	}
+
+	j2, err := NewJobManagerWithStore(store, 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j2.Close()
+	for _, meta := range stored {
+		if got, done, _ := j2.Wait(meta.ID, 5*time.Second); !done || got.Status != StatusDone {
+			t.Fatalf("accepted job after restart = %+v", got)
+		}
+	}
+	if all, _ := store.List(); len(all) != accepted {
+		t.Fatalf("restart ran %d jobs, want %d", len(all), accepted)
+	}
+}
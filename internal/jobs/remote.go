@@ -0,0 +1,372 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/progress"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+var (
+	ErrUnknownWorker = errors.New("unknown remote worker")
+	ErrLeaseLost     = errors.New("job is not leased to this worker")
+)
+
+// RemoteConfig tunes how jobs are leased to remote workers.
+type RemoteConfig struct {
+	// LeaseTTL is how long a lease, and the registration of a worker, lasts
+	// without a heartbeat. Defaults to 15s.
+	LeaseTTL time.Duration
+}
+
+// DefaultRemoteConfig is used until ConfigureRemote is called.
+var DefaultRemoteConfig = RemoteConfig{LeaseTTL: 15 * time.Second}
+
+// RemoteWorker is a worker process that registered to run jobs of some
+// commands. While at least one is alive for a command, the jobs of that
+// command wait for a worker to lease them instead of going to the local pool.
+type RemoteWorker struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Commands     []string  `json:"commands"`
+	Capacity     int       `json:"capacity"` // jobs it runs at the same time
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	Leases       []string  `json:"leases"` // IDs of the jobs it holds
+	Completed    int64     `json:"completed"`
+	Lost         int64     `json:"lost"` // leases that expired and were re-queued
+
+	commands map[string]bool
+}
+
+// Lease grants a remote worker the right to run a job until ExpiresAt, which
+// heartbeats push forward.
+type Lease struct {
+	JobID     string            `json:"job_id"`
+	WorkerID  string            `json:"worker_id"`
+	Command   string            `json:"command"`
+	Params    map[string]string `json:"params"`
+	TimeoutMs int               `json:"timeout_ms"`
+	LeasedAt  time.Time         `json:"leased_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// RunCommand executes command in this process, as the local pools do.
+// Remote workers use it to run the jobs they lease.
+func RunCommand(command string, params map[string]string, cancelCh <-chan struct{}, rep *progress.Reporter) *types.Response {
+	return runCommand(&JobMeta{Command: command, Params: params}, cancelCh, rep)
+}
+
+// ConfigureRemote sets the lease settings and starts the reaper that
+// re-queues the jobs of expired leases.
+func (j *JobManager) ConfigureRemote(cfg RemoteConfig) {
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = DefaultRemoteConfig.LeaseTTL
+	}
+	j.mu.Lock()
+	j.remoteCfg = cfg
+	started := j.remoteOn
+	j.remoteOn = true
+	j.mu.Unlock()
+	if !started {
+		j.wg.Add(1)
+		go j.leaseLoop()
+	}
+}
+
+// LeaseTTL returns how long a lease lasts without a heartbeat.
+func (j *JobManager) LeaseTTL() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.remoteCfg.LeaseTTL
+}
+
+func (j *JobManager) leaseLoop() {
+	defer j.wg.Done()
+	for {
+		j.mu.Lock()
+		interval := j.remoteCfg.LeaseTTL / 3
+		j.mu.Unlock()
+		timer := time.NewTimer(interval)
+		select {
+		case <-j.stop:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			j.ReapLeases(now)
+		}
+	}
+}
+
+// RegisterWorker adds a remote worker running up to capacity jobs of
+// commands at a time.
+func (j *JobManager) RegisterWorker(name string, commands []string, capacity int) (*RemoteWorker, error) {
+	if len(commands) == 0 {
+		return nil, errors.New("a worker needs at least one command")
+	}
+	if capacity <= 0 {
+		capacity = 1
+	}
+	now := time.Now()
+	w := &RemoteWorker{
+		ID:           util.NewRequestID(),
+		Name:         name,
+		Capacity:     capacity,
+		RegisteredAt: now,
+		LastSeen:     now,
+		commands:     make(map[string]bool, len(commands)),
+	}
+	if w.Name == "" {
+		w.Name = w.ID
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range commands {
+		if c == "" || w.commands[c] {
+			continue
+		}
+		w.commands[c] = true
+		w.Commands = append(w.Commands, c)
+		j.sched.remote[c]++
+	}
+	sort.Strings(w.Commands)
+	j.remoteWorkers[w.ID] = w
+	util.Info("remote worker registered", util.Fields{"worker_id": w.ID, "name": w.Name, "commands": w.Commands, "capacity": capacity})
+	return w.clone(), nil
+}
+
+// PollJob waits up to timeout for a job the worker can run and leases it.
+// Returns nil when none came up in time.
+func (j *JobManager) PollJob(workerID string, timeout time.Duration) (*Lease, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		j.mu.Lock()
+		w, ok := j.remoteWorkers[workerID]
+		if !ok {
+			j.mu.Unlock()
+			return nil, ErrUnknownWorker
+		}
+		now := time.Now()
+		w.LastSeen = now
+		if len(w.Leases) < w.Capacity {
+			if meta := j.sched.nextRemote(now, w.commands); meta != nil {
+				lease := j.leaseLocked(w, meta, now)
+				j.mu.Unlock()
+				return lease, nil
+			}
+		}
+		ready := j.sched.ready
+		j.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-timer.C:
+			return nil, nil
+		case <-j.stop:
+			return nil, nil
+		}
+	}
+}
+
+// leaseLocked hands meta to w. Must be called with j.mu held.
+func (j *JobManager) leaseLocked(w *RemoteWorker, meta *JobMeta, now time.Time) *Lease {
+	lease := &Lease{
+		JobID:     meta.ID,
+		WorkerID:  w.ID,
+		Command:   meta.Command,
+		Params:    meta.Params,
+		TimeoutMs: meta.TimeoutMs,
+		LeasedAt:  now,
+		ExpiresAt: now.Add(j.remoteCfg.LeaseTTL),
+	}
+	j.leases[meta.ID] = lease
+	w.Leases = append(w.Leases, meta.ID)
+	rep := progress.New()
+	rep.OnChange(j.progressNotifier(meta))
+	j.progress[meta.ID] = rep
+	j.markRunningLocked(meta, now, "leased to remote worker "+w.Name)
+	cp := *lease
+	return &cp
+}
+
+// Heartbeat keeps the worker registered and extends the leases of the jobs
+// it reports as running. It returns the reported jobs the worker should stop:
+// canceled, timed out or re-queued after their lease expired.
+func (j *JobManager) Heartbeat(workerID string, running []string) (stop []string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	w, ok := j.remoteWorkers[workerID]
+	if !ok {
+		return nil, ErrUnknownWorker
+	}
+	now := time.Now()
+	w.LastSeen = now
+	stop = []string{}
+	for _, id := range running {
+		if lease, ok := j.leases[id]; ok && lease.WorkerID == workerID {
+			lease.ExpiresAt = now.Add(j.remoteCfg.LeaseTTL)
+		} else {
+			stop = append(stop, id)
+		}
+	}
+	return stop, nil
+}
+
+// ReportProgress records the progress of a leased job.
+func (j *JobManager) ReportProgress(workerID, jobID string, fraction float64, phase string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.ownedLeaseLocked(workerID, jobID); err != nil {
+		return err
+	}
+	j.progress[jobID].Update(fraction, phase)
+	return nil
+}
+
+// CompleteJob records the outcome of a leased job as if a local pool had
+// produced res, retry policy included.
+func (j *JobManager) CompleteJob(workerID, jobID string, res *types.Response) error {
+	j.mu.Lock()
+	lease, err := j.ownedLeaseLocked(workerID, jobID)
+	if err != nil {
+		j.mu.Unlock()
+		return err
+	}
+	w := j.remoteWorkers[workerID]
+	w.Completed++
+	j.dropLeaseLocked(lease)
+	meta := j.store[jobID]
+	j.mu.Unlock()
+	if meta != nil {
+		j.updateJobResult(meta, res)
+	}
+	return nil
+}
+
+func (j *JobManager) ownedLeaseLocked(workerID, jobID string) (*Lease, error) {
+	if _, ok := j.remoteWorkers[workerID]; !ok {
+		return nil, ErrUnknownWorker
+	}
+	lease, ok := j.leases[jobID]
+	if !ok || lease.WorkerID != workerID {
+		return nil, ErrLeaseLost
+	}
+	return lease, nil
+}
+
+// dropLeaseLocked forgets lease and frees its slot in the worker.
+// Must be called with j.mu held.
+func (j *JobManager) dropLeaseLocked(lease *Lease) {
+	delete(j.leases, lease.JobID)
+	if w, ok := j.remoteWorkers[lease.WorkerID]; ok {
+		for i, id := range w.Leases {
+			if id == lease.JobID {
+				w.Leases = append(w.Leases[:i:i], w.Leases[i+1:]...)
+				break
+			}
+		}
+	}
+	j.sched.signal() // the worker may take another job
+}
+
+// ReapLeases drops the workers not seen for a lease TTL, re-queues the jobs
+// of expired leases and times out leased jobs past their timeout.
+func (j *JobManager) ReapLeases(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ttl := j.remoteCfg.LeaseTTL
+	for id, w := range j.remoteWorkers {
+		if now.Sub(w.LastSeen) < ttl {
+			continue
+		}
+		delete(j.remoteWorkers, id)
+		for _, c := range w.Commands {
+			if j.sched.remote[c]--; j.sched.remote[c] <= 0 {
+				delete(j.sched.remote, c)
+			}
+		}
+		j.sched.signal() // its commands may go back to the local pools
+		util.Warn("remote worker expired", util.Fields{"worker_id": id, "name": w.Name, "leases": len(w.Leases)})
+	}
+	for _, lease := range j.leases {
+		meta, ok := j.store[lease.JobID]
+		if !ok || meta.Status != StatusRunning {
+			j.dropLeaseLocked(lease)
+			continue
+		}
+		if _, alive := j.remoteWorkers[lease.WorkerID]; alive && now.Before(lease.ExpiresAt) {
+			if meta.TimeoutMs > 0 && now.Sub(lease.LeasedAt) > time.Duration(meta.TimeoutMs)*time.Millisecond {
+				j.dropLeaseLocked(lease)
+				j.finishAttemptLocked(meta, StatusTimeout, fmt.Sprintf("timed out after %d ms", meta.TimeoutMs), 0, ActorWatchdog)
+			}
+			continue
+		}
+		if w, ok := j.remoteWorkers[lease.WorkerID]; ok {
+			w.Lost++
+		}
+		j.dropLeaseLocked(lease)
+		j.requeueLeasedLocked(meta, lease)
+	}
+}
+
+// requeueLeasedLocked puts a job whose lease expired back in the queue.
+// Must be called with j.mu held.
+func (j *JobManager) requeueLeasedLocked(meta *JobMeta, lease *Lease) {
+	if err := j.transitionLocked(meta, StatusQueued, ActorLease, "lease of remote worker "+lease.WorkerID+" expired"); err != nil {
+		return
+	}
+	delete(j.progress, meta.ID)
+	meta.enqueuedAt = time.Now()
+	// it was already accepted: keep it even if the queue filled up meanwhile
+	j.sched.insert(meta, levelOf(meta.Priority))
+	j.sched.signal()
+	j.appendToJournal(meta)
+	util.Warn("lease expired, job re-queued", util.Fields{"job_id": meta.ID, "worker_id": lease.WorkerID})
+}
+
+// cancelLeasedLocked cancels a job held by a remote worker, which learns it
+// on its next heartbeat. Must be called with j.mu held.
+func (j *JobManager) cancelLeasedLocked(meta *JobMeta, actor string) (bool, error) {
+	lease, ok := j.leases[meta.ID]
+	if !ok {
+		return false, nil
+	}
+	if err := j.transitionLocked(meta, StatusCanceled, actor, "canceled while running on a remote worker"); err != nil {
+		return true, err
+	}
+	j.dropLeaseLocked(lease)
+	j.appendToJournal(meta)
+	j.endJobSpan(meta)
+	return true, nil
+}
+
+// RemoteWorkers returns the registered workers and the current leases.
+func (j *JobManager) RemoteWorkers() ([]*RemoteWorker, []*Lease) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	workers := make([]*RemoteWorker, 0, len(j.remoteWorkers))
+	for _, w := range j.remoteWorkers {
+		workers = append(workers, w.clone())
+	}
+	sort.Slice(workers, func(a, b int) bool { return workers[a].RegisteredAt.Before(workers[b].RegisteredAt) })
+	leases := make([]*Lease, 0, len(j.leases))
+	for _, l := range j.leases {
+		cp := *l
+		leases = append(leases, &cp)
+	}
+	sort.Slice(leases, func(a, b int) bool { return leases[a].LeasedAt.Before(leases[b].LeasedAt) })
+	return workers, leases
+}
+
+func (w *RemoteWorker) clone() *RemoteWorker {
+	cp := *w
+	cp.Commands = append([]string(nil), w.Commands...)
+	cp.Leases = append([]string{}, w.Leases...)
+	cp.commands = nil
+	return &cp
+}
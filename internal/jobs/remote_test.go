@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// While a remote worker serves a command its jobs wait to be leased, and the
+// result it reports completes them.
+func TestRemoteLeaseAndComplete(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	w, err := j.RegisterWorker("w1", []string{"reverse"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, done, _ := j.Wait(id, 100*time.Millisecond); done {
+		t.Fatal("job of a remote command ran in the local pool")
+	}
+
+	lease, err := j.PollJob(w.ID, time.Second)
+	if err != nil || lease == nil || lease.JobID != id || lease.Params["text"] != "abc" {
+		t.Fatalf("poll = %+v %v", lease, err)
+	}
+	if meta, _ := j.GetMeta(id); meta.Status != StatusRunning {
+		t.Fatalf("leased job is %s", meta.Status)
+	}
+	if lease, err := j.PollJob(w.ID, 50*time.Millisecond); lease != nil || err != nil {
+		t.Fatalf("worker at capacity got %+v %v", lease, err)
+	}
+	if err := j.ReportProgress(w.ID, id, 0.5, "half"); err != nil {
+		t.Fatal(err)
+	}
+	if snap, ok := j.Progress(id); !ok || snap.Fraction != 0.5 {
+		t.Fatalf("progress = %+v %v", snap, ok)
+	}
+	if err := j.CompleteJob("other", id, nil); !errors.Is(err, ErrUnknownWorker) {
+		t.Fatalf("complete from an unknown worker: %v", err)
+	}
+	res := &types.Response{StatusCode: 200, Headers: map[string]string{"Content-Type": "application/json"}, Body: []byte(`"cba"`)}
+	if err := j.CompleteJob(w.ID, id, res); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := j.GetMeta(id)
+	if err != nil || meta.Status != StatusDone {
+		t.Fatalf("completed job = %+v %v", meta, err)
+	}
+	var got types.Response
+	if err := json.Unmarshal([]byte(meta.Result), &got); err != nil || string(got.Body) != `"cba"` {
+		t.Fatalf("result = %s %v", meta.Result, err)
+	}
+}
+
+// A job whose lease expires goes back to the queue, and once its worker is
+// gone too the local pool runs it.
+func TestRemoteLeaseExpiry(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.remoteCfg.LeaseTTL = time.Minute
+
+	w, _ := j.RegisterWorker("w1", []string{"reverse"}, 2)
+	id, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease, err := j.PollJob(w.ID, time.Second); err != nil || lease == nil {
+		t.Fatalf("poll = %+v %v", lease, err)
+	}
+	canceled, err := j.Submit("reverse", map[string]string{"text": "def"}, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease, _ := j.PollJob(w.ID, time.Second); lease == nil || lease.JobID != canceled {
+		t.Fatalf("second poll = %+v", lease)
+	}
+	if err := j.Cancel(canceled); err != nil {
+		t.Fatal(err)
+	}
+	if stop, err := j.Heartbeat(w.ID, []string{id, canceled}); err != nil || len(stop) != 1 || stop[0] != canceled {
+		t.Fatalf("heartbeat stop = %v %v", stop, err)
+	}
+
+	j.ReapLeases(time.Now().Add(2 * time.Minute))
+	meta, done, _ := j.Wait(id, 5*time.Second)
+	if !done || meta.Status != StatusDone {
+		t.Fatalf("re-queued job = %+v", meta)
+	}
+	var requeued bool
+	for _, tr := range meta.History {
+		if tr.Actor == ActorLease && tr.To == StatusQueued {
+			requeued = true
+		}
+	}
+	if !requeued {
+		t.Fatalf("history has no lease expiry: %+v", meta.History)
+	}
+	if _, err := j.Heartbeat(w.ID, nil); !errors.Is(err, ErrUnknownWorker) {
+		t.Fatalf("expired worker still registered: %v", err)
+	}
+}
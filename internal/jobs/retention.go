@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ErrJobExpired is matched by the *ExpiredError returned for evicted jobs.
+var ErrJobExpired = errors.New("job expired")
+
+// ExpiredError is returned when a job was evicted by the retention policy.
+type ExpiredError struct {
+	ID        string
+	ExpiredAt time.Time
+}
+
+func (e *ExpiredError) Error() string {
+	return "job " + e.ID + " expired at " + e.ExpiredAt.Format(time.RFC3339)
+}
+
+func (e *ExpiredError) Is(target error) bool { return target == ErrJobExpired }
+
+// RetentionPolicy bounds how long finished jobs are kept. Zero values disable
+// the corresponding limit.
+type RetentionPolicy struct {
+	MaxAge         time.Duration  // evict finished jobs last updated longer ago than this
+	MaxCount       map[string]int // newest jobs kept per terminal status
+	MaxResultBytes int64          // total size of the results kept; oldest jobs go first
+	SweepInterval  time.Duration  // how often the sweeper runs
+	TombstoneTTL   time.Duration  // how long evicted IDs answer as expired instead of not found
+}
+
+// RetentionStats describes the work done by the sweeper.
+type RetentionStats struct {
+	Sweeps      int64     `json:"sweeps"`
+	LastSweepAt time.Time `json:"last_sweep_at,omitempty"`
+	LastExpired int       `json:"last_expired"`
+	Expired     int64     `json:"expired_total"`
+	Tombstones  int       `json:"tombstones"`
+	ResultBytes int64     `json:"result_bytes"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// ConfigureRetention sets the retention policy and starts the sweeper when a
+// sweep interval is given. Configuring again replaces the running sweeper.
+func (j *JobManager) ConfigureRetention(policy RetentionPolicy) {
+	j.mu.Lock()
+	j.retention = policy
+	if j.sweepStop != nil {
+		close(j.sweepStop)
+		j.sweepStop = nil
+	}
+	var sweepStop chan struct{}
+	if policy.SweepInterval > 0 {
+		sweepStop = make(chan struct{})
+		j.sweepStop = sweepStop
+	}
+	j.mu.Unlock()
+	if sweepStop != nil {
+		j.wg.Add(1)
+		go j.sweepLoop(policy.SweepInterval, sweepStop)
+	}
+}
+
+func (j *JobManager) sweepLoop(interval time.Duration, sweepStop <-chan struct{}) {
+	defer j.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-sweepStop:
+			return
+		case <-ticker.C:
+			j.Sweep()
+		}
+	}
+}
+
+// Sweep applies the retention policy once. Evicted jobs are replaced in the
+// store by a tombstone without params or result, which is itself deleted once
+// TombstoneTTL has passed. Returns the number of jobs evicted.
+func (j *JobManager) Sweep() int {
+	j.mu.Lock()
+	policy := j.retention
+	j.mu.Unlock()
+
+	list, err := j.persist.List()
+	if err != nil {
+		j.mu.Lock()
+		j.retentionStats.LastError = err.Error()
+		j.mu.Unlock()
+		util.Error("retention sweep failed", util.Fields{"error": err.Error()})
+		return 0
+	}
+
+	now := time.Now()
+	var finished, tombstones []*JobMeta
+	for _, meta := range list {
+		switch {
+		case meta.Status == StatusExpired:
+			tombstones = append(tombstones, meta)
+		case isTerminal(meta.Status):
+			finished = append(finished, meta)
+		}
+	}
+	// newest first, so the count and size limits keep the most recent jobs
+	sort.Slice(finished, func(a, b int) bool { return finished[a].UpdatedAt.After(finished[b].UpdatedAt) })
+
+	evict := make([]*JobMeta, 0)
+	kept := make(map[string]int)
+	var resultBytes int64
+	for _, meta := range finished {
+		switch {
+		case policy.MaxAge > 0 && now.Sub(meta.UpdatedAt) > policy.MaxAge:
+			evict = append(evict, meta)
+		case policy.MaxCount[meta.Status] > 0 && kept[meta.Status] >= policy.MaxCount[meta.Status]:
+			evict = append(evict, meta)
+		case policy.MaxResultBytes > 0 && resultBytes+resultSize(meta) > policy.MaxResultBytes:
+			evict = append(evict, meta)
+		default:
+			kept[meta.Status]++
+			resultBytes += resultSize(meta)
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var blobs []string
+	expired := 0
+	for _, meta := range evict {
+		if _, active := j.store[meta.ID]; active {
+			continue
+		}
+		tomb := &JobMeta{
+			ID:        meta.ID,
+			Command:   meta.Command,
+			Priority:  meta.Priority,
+			Status:    StatusExpired,
+			CreatedAt: meta.CreatedAt,
+			UpdatedAt: now,
+			TraceID:   meta.TraceID,
+			ExpiredAt: &now,
+			// keeps answering repeated submissions while the key is remembered
+			IdempotencyKey: meta.IdempotencyKey,
+			RequestHash:    meta.RequestHash,
+		}
+		if err := j.persist.Put(tomb); err != nil {
+			j.retentionStats.LastError = err.Error()
+			util.Error("unable to expire job", util.Fields{"job_id": meta.ID, "error": err.Error()})
+			continue
+		}
+		j.uncountFinished(meta)
+		j.index.remove(meta)
+		j.tombstones[meta.ID] = now
+		expired++
+		if meta.ResultRef != nil {
+			blobs = append(blobs, meta.ResultRef.SHA256)
+		}
+	}
+	// blobs are shared by jobs with identical results, and a job finishing
+	// since the listing above may have reused one: check references under j.mu
+	if len(blobs) > 0 {
+		j.removeUnreferencedBlobsLocked(blobs)
+	}
+	if n := j.expireWorkflowsLocked(now, policy.MaxAge); n > 0 {
+		util.Debug("expired finished workflows", util.Fields{"workflows": n})
+	}
+	for _, meta := range tombstones {
+		if policy.TombstoneTTL > 0 && meta.ExpiredAt != nil && now.Sub(*meta.ExpiredAt) > policy.TombstoneTTL {
+			if err := j.persist.Delete(meta.ID); err == nil {
+				delete(j.tombstones, meta.ID)
+			}
+		}
+	}
+
+	j.pruneIdempotencyKeys(now)
+	j.retentionStats.Sweeps++
+	j.retentionStats.LastSweepAt = now
+	j.retentionStats.LastExpired = expired
+	j.retentionStats.Expired += int64(expired)
+	j.retentionStats.ResultBytes = resultBytes
+	if expired > 0 {
+		util.Info("expired finished jobs", util.Fields{"jobs": expired, "result_bytes_kept": resultBytes})
+	}
+	return expired
+}
+
+// RetentionStats returns the sweeper statistics.
+func (j *JobManager) RetentionStats() RetentionStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := j.retentionStats
+	st.Tombstones = len(j.tombstones)
+	return st
+}
+
+// expiredError returns an *ExpiredError if id was evicted. Must be called with j.mu held.
+func (j *JobManager) expiredError(id string) error {
+	if at, ok := j.tombstones[id]; ok {
+		return &ExpiredError{ID: id, ExpiredAt: at}
+	}
+	return nil
+}
+
+func (j *JobManager) uncountFinished(meta *JobMeta) {
+	if byPrio, ok := j.finished[meta.Status]; ok && byPrio[meta.Priority] > 0 {
+		byPrio[meta.Priority]--
+	}
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Finished jobs past the count limit are replaced by tombstones that answer
+// with ErrJobExpired, while the newest jobs stay available.
+func TestSweepKeepsNewestJobs(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	for i, id := range []string{"old", "mid", "new"} {
+		at := now.Add(time.Duration(i-3) * time.Minute)
+		store.Put(&JobMeta{ID: id, Status: StatusDone, Result: "x", CreatedAt: at, UpdatedAt: at})
+	}
+	store.Put(&JobMeta{ID: "stale", Status: StatusError, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-2 * time.Hour)})
+
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureRetention(RetentionPolicy{
+		MaxAge:   time.Hour,
+		MaxCount: map[string]int{StatusDone: 2},
+	})
+
+	if n := j.Sweep(); n != 2 {
+		t.Fatalf("expected 2 evictions, got %d", n)
+	}
+	for _, id := range []string{"old", "stale"} {
+		var expired *ExpiredError
+		if _, err := j.GetMeta(id); !errors.As(err, &expired) || !errors.Is(err, ErrJobExpired) {
+			t.Fatalf("%s: expected ExpiredError, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"mid", "new"} {
+		if _, err := j.GetMeta(id); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+	if got := j.CountsByStatus()[StatusDone][""]; got != 2 {
+		t.Fatalf("expected 2 done jobs counted, got %d", got)
+	}
+}
+
+// Configuring the policy again replaces the sweeper rather than adding one.
+func TestConfigureRetentionReplacesSweeper(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureRetention(RetentionPolicy{SweepInterval: 5 * time.Millisecond})
+	j.ConfigureRetention(RetentionPolicy{SweepInterval: 5 * time.Millisecond})
+	deadline := time.Now().Add(5 * time.Second)
+	for j.RetentionStats().Sweeps < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the sweeper never ran")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	j.ConfigureRetention(RetentionPolicy{})
+	time.Sleep(20 * time.Millisecond) // a tick racing the reconfiguration may still run
+	sweeps := j.RetentionStats().Sweeps
+	time.Sleep(50 * time.Millisecond)
+	if now := j.RetentionStats().Sweeps; now != sweeps {
+		t.Fatalf("sweeps went on after the interval was disabled: %d -> %d", sweeps, now)
+	}
+}
+
+// refusingStore fails to write the tombstone of one job.
+type refusingStore struct {
+	*MemoryStore
+	refuse string
+}
+
+func (s *refusingStore) Put(meta *JobMeta) error {
+	if meta.ID == s.refuse && meta.Status == StatusExpired {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.Put(meta)
+}
+
+// Jobs whose tombstone could not be written are not counted as expired.
+func TestSweepCountsOnlyWrittenTombstones(t *testing.T) {
+	store := &refusingStore{MemoryStore: NewMemoryStore(), refuse: "stuck"}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, id := range []string{"gone", "stuck"} {
+		store.Put(&JobMeta{ID: id, Status: StatusDone, CreatedAt: old, UpdatedAt: old})
+	}
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureRetention(RetentionPolicy{MaxAge: time.Hour})
+
+	if n := j.Sweep(); n != 1 {
+		t.Fatalf("expected 1 eviction, got %d", n)
+	}
+	st := j.RetentionStats()
+	if st.LastExpired != 1 || st.Expired != 1 || st.LastError == "" {
+		t.Fatalf("stats = %+v", st)
+	}
+	if _, err := j.GetMeta("stuck"); err != nil {
+		t.Fatalf("job without tombstone: %v", err)
+	}
+}
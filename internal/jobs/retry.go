@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// Backoff strategies between attempts.
+const (
+	BackoffFixed       = "fixed"       // InitialDelay every time
+	BackoffLinear      = "linear"      // InitialDelay * attempt
+	BackoffExponential = "exponential" // InitialDelay * 2^(attempt-1)
+)
+
+// RetryPolicy decides whether a failed job runs again and when.
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"max_attempts"`      // total attempts including the first; <= 1 disables retries
+	Backoff        string   `json:"backoff,omitempty"` // exponential when empty
+	InitialDelayMs int      `json:"initial_delay_ms,omitempty"`
+	MaxDelayMs     int      `json:"max_delay_ms,omitempty"`
+	RetryOn        []string `json:"retry_on,omitempty"`    // retryable statuses, error and timeout when empty
+	RetryCodes     []int    `json:"retry_codes,omitempty"` // if set, only error results with these status codes are retried
+}
+
+// AttemptError records why an attempt of a job failed.
+type AttemptError struct {
+	Attempt    int       `json:"attempt"`
+	Status     string    `json:"status"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error"`
+	At         time.Time `json:"at"`
+}
+
+// retryPolicies holds the default policy of each command. IO bound commands
+// retry transient failures; the rest fail on the first error unless the
+// submission asks otherwise.
+var (
+	retryMu       sync.RWMutex
+	retryPolicies = map[string]RetryPolicy{
+		"sortfile":  {MaxAttempts: 3, InitialDelayMs: 500, MaxDelayMs: 10000, RetryOn: []string{StatusError}},
+		"wordcount": {MaxAttempts: 3, InitialDelayMs: 500, MaxDelayMs: 10000, RetryOn: []string{StatusError}},
+		"grep":      {MaxAttempts: 3, InitialDelayMs: 500, MaxDelayMs: 10000, RetryOn: []string{StatusError}},
+		"hashfile":  {MaxAttempts: 3, InitialDelayMs: 500, MaxDelayMs: 10000, RetryOn: []string{StatusError}},
+		"compress":  {MaxAttempts: 3, InitialDelayMs: 500, MaxDelayMs: 10000, RetryOn: []string{StatusError}},
+	}
+)
+
+// SetRetryPolicy sets the default retry policy of a command.
+func SetRetryPolicy(command string, p RetryPolicy) {
+	retryMu.Lock()
+	retryPolicies[command] = p
+	retryMu.Unlock()
+}
+
+// RetryPolicyFor returns the default retry policy of a command; the zero
+// policy never retries.
+func RetryPolicyFor(command string) RetryPolicy {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	return retryPolicies[command]
+}
+
+// Validate reports settings that make no sense.
+func (p RetryPolicy) Validate() error {
+	switch p.Backoff {
+	case "", BackoffFixed, BackoffLinear, BackoffExponential:
+	default:
+		return fmt.Errorf("unknown backoff %q", p.Backoff)
+	}
+	if p.MaxAttempts < 0 || p.InitialDelayMs < 0 || p.MaxDelayMs < 0 {
+		return fmt.Errorf("retry settings must not be negative")
+	}
+	for _, s := range p.RetryOn {
+		if s != StatusError && s != StatusTimeout {
+			return fmt.Errorf("status %q is not retryable", s)
+		}
+	}
+	return nil
+}
+
+func (p RetryPolicy) retryable(status string, code int) bool {
+	on := p.RetryOn
+	if len(on) == 0 {
+		on = []string{StatusError, StatusTimeout}
+	}
+	if !containsString(on, status) {
+		return false
+	}
+	if status == StatusError && code > 0 && len(p.RetryCodes) > 0 {
+		for _, c := range p.RetryCodes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// delay returns the wait before the attempt that follows attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	initial := time.Duration(p.InitialDelayMs) * time.Millisecond
+	if initial <= 0 {
+		initial = time.Second
+	}
+	max := time.Duration(p.MaxDelayMs) * time.Millisecond
+	if max <= 0 {
+		max = time.Minute
+	}
+	d := initial
+	switch p.Backoff {
+	case BackoffFixed:
+	case BackoffLinear:
+		d = initial * time.Duration(attempt)
+	default:
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// retryPolicyOf returns the policy that applies to meta: the one given on
+// submission or the default of its command.
+func retryPolicyOf(meta *JobMeta) RetryPolicy {
+	if meta.Retry != nil {
+		return *meta.Retry
+	}
+	return RetryPolicyFor(meta.Command)
+}
+
+// failedResult turns a server error answered by an algorithm into a job
+// error. ok is false for any other response.
+func failedResult(res *types.Response) (msg string, ok bool) {
+	if res.StatusCode < 500 {
+		return "", false
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(res.Body, &body) == nil && body.Error != "" {
+		return body.Error, true
+	}
+	return fmt.Sprintf("command failed with status %d", res.StatusCode), true
+}
+
+// retryLocked schedules another attempt of a running job whose attempt has
+// just ended with status, when its policy allows it. The failure is recorded
+// in AttemptErrors and the job goes back to queued until its delay expires.
+// Returns false when the failure is final.
+// Must be called with j.mu held.
+func (j *JobManager) retryLocked(meta *JobMeta, status, errMsg string, statusCode int) bool {
+	policy := retryPolicyOf(meta)
+	if meta.Attempt >= policy.MaxAttempts || !policy.retryable(status, statusCode) {
+		return false
+	}
+	delay := policy.delay(meta.Attempt)
+	reason := fmt.Sprintf("attempt %d ended with %s: %s; next attempt in %s", meta.Attempt, status, errMsg, delay)
+	if err := j.transitionLocked(meta, StatusQueued, ActorRetry, reason); err != nil {
+		return false
+	}
+	now := meta.UpdatedAt
+	meta.AttemptErrors = append(meta.AttemptErrors, AttemptError{
+		Attempt:    meta.Attempt,
+		Status:     status,
+		StatusCode: statusCode,
+		Error:      errMsg,
+		At:         now,
+	})
+	retryAt := now.Add(delay)
+	util.Warn("job attempt failed, retrying", util.Fields{
+		"job_id":   meta.ID,
+		"command":  meta.Command,
+		"attempt":  meta.Attempt,
+		"status":   status,
+		"error":    errMsg,
+		"delay_ms": delay.Milliseconds(),
+		"trace_id": meta.TraceID,
+	})
+	meta.Error = ""
+	meta.RetryAt = &retryAt
+	j.appendToJournal(meta)
+	j.enqueueAfterLocked(meta, delay)
+	return true
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	cases := []struct {
+		backoff string
+		attempt int
+		want    time.Duration
+	}{
+		{BackoffFixed, 3, 100 * time.Millisecond},
+		{BackoffLinear, 3, 300 * time.Millisecond},
+		{BackoffExponential, 1, 100 * time.Millisecond},
+		{BackoffExponential, 3, 400 * time.Millisecond},
+		{BackoffExponential, 10, time.Second}, // capped
+	}
+	for _, c := range cases {
+		p := RetryPolicy{Backoff: c.backoff, InitialDelayMs: 100, MaxDelayMs: 1000}
+		if got := p.delay(c.attempt); got != c.want {
+			t.Errorf("%s attempt %d: got %v, want %v", c.backoff, c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	p := RetryPolicy{RetryOn: []string{StatusError}, RetryCodes: []int{503}}
+	if !p.retryable(StatusError, 503) || p.retryable(StatusError, 500) || p.retryable(StatusTimeout, 0) {
+		t.Fatal("codes must restrict which errors are retried")
+	}
+	if !p.retryable(StatusError, 0) {
+		t.Fatal("errors without a status code follow RetryOn")
+	}
+	if !(RetryPolicy{}).retryable(StatusTimeout, 0) {
+		t.Fatal("timeouts are retryable by default")
+	}
+}
+
+// runningJob registers a job as if the dispatcher had just started its first attempt.
+func runningJob(j *JobManager, id string, policy *RetryPolicy) *JobMeta {
+	now := time.Now()
+	meta := &JobMeta{ID: id, Command: "fibonacci", Params: map[string]string{"num": "5"},
+		Priority: PriorityNormal, Status: StatusRunning, Attempt: 1, Retry: policy,
+		CreatedAt: now, UpdatedAt: now}
+	j.mu.Lock()
+	j.store[id] = meta
+	j.appendToJournal(meta)
+	j.mu.Unlock()
+	return meta
+}
+
+func serverError() *types.Response {
+	return &types.Response{StatusCode: 500, StatusText: "Internal Server Error",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    []byte(`{"error":"read failed"}`)}
+}
+
+// A failed attempt is recorded and the job goes back through the queue after
+// its delay; the second attempt succeeds.
+func TestFailedJobIsRetried(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	meta := runningJob(j, "r1", &RetryPolicy{MaxAttempts: 2, InitialDelayMs: 50})
+	j.updateJobResult(meta, serverError())
+
+	got, _ := j.GetMeta("r1")
+	if got.Status != StatusQueued || got.RetryAt == nil || len(got.AttemptErrors) != 1 ||
+		got.AttemptErrors[0].Error != "read failed" || got.AttemptErrors[0].StatusCode != 500 {
+		t.Fatalf("unexpected state after failure: %+v", got)
+	}
+
+	got, done, err := j.Wait("r1", 5*time.Second)
+	if err != nil || !done {
+		t.Fatalf("job did not finish: %v", err)
+	}
+	if got.Status != StatusDone || got.Attempt != 2 {
+		t.Fatalf("expected done on attempt 2, got %s on attempt %d", got.Status, got.Attempt)
+	}
+}
+
+// The last allowed attempt fails for good, and canceling a job stops the
+// retry it was waiting for.
+func TestRetryLimitAndCancel(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	meta := runningJob(j, "last", &RetryPolicy{MaxAttempts: 1})
+	j.updateJobResult(meta, serverError())
+	if got, _ := j.GetMeta("last"); got.Status != StatusError {
+		t.Fatalf("expected error after the last attempt, got %s", got.Status)
+	}
+
+	meta = runningJob(j, "canceled", &RetryPolicy{MaxAttempts: 3, InitialDelayMs: 100})
+	j.updateJobResult(meta, serverError())
+	if err := j.Cancel("canceled"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	got, _ := j.GetMeta("canceled")
+	if got.Status != StatusCanceled || got.Attempt != 1 {
+		t.Fatalf("retry ran after cancel: %s on attempt %d", got.Status, got.Attempt)
+	}
+}
@@ -0,0 +1,321 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/cron"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+// scheduleTick is how often the recurring schedules are checked.
+const scheduleTick = time.Second
+
+// ScheduleSpec defines a recurring job.
+type ScheduleSpec struct {
+	Name     string            `json:"name,omitempty"`
+	Cron     string            `json:"cron"`               // five fields or @hourly, @daily...
+	Timezone string            `json:"timezone,omitempty"` // IANA name, server local time when empty
+	Command  string            `json:"command"`
+	Params   map[string]string `json:"params,omitempty"`
+	Priority Priority          `json:"priority,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// Schedule is a recurring job and the record of its runs.
+type Schedule struct {
+	ScheduleSpec
+	ID        string     `json:"id"`
+	Paused    bool       `json:"paused"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"` // nil while paused
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastJobID string     `json:"last_job_id,omitempty"`
+	LastError string     `json:"last_error,omitempty"` // submission error of the last run
+	Runs      int        `json:"runs"`
+
+	expr *cron.Expression
+	loc  *time.Location
+}
+
+func (s *Schedule) clone() *Schedule {
+	c := *s
+	return &c
+}
+
+// compile parses the cron expression and time zone of the schedule.
+func (s *Schedule) compile() error {
+	expr, err := cron.Parse(s.Cron)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	loc := time.Local
+	if s.Timezone != "" {
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, s.Timezone)
+		}
+	}
+	s.expr, s.loc = expr, loc
+	return nil
+}
+
+// planNext sets NextRunAt to the first run after t.
+func (s *Schedule) planNext(t time.Time) {
+	next := s.expr.Next(t.In(s.loc))
+	if next.IsZero() {
+		s.NextRunAt = nil
+		return
+	}
+	s.NextRunAt = &next
+}
+
+// delayedUntil reports until when a queued job must wait before entering its
+// priority queue: its next retry or a run_at still in the future.
+func (m *JobMeta) delayedUntil() (time.Time, bool) {
+	if m.RetryAt != nil {
+		return *m.RetryAt, true
+	}
+	if m.RunAt != nil && m.Attempt == 0 && m.RunAt.After(time.Now()) {
+		return *m.RunAt, true
+	}
+	return time.Time{}, false
+}
+
+// enqueueAfterLocked puts meta in its priority queue after delay.
+// Must be called with j.mu held.
+func (j *JobManager) enqueueAfterLocked(meta *JobMeta, delay time.Duration) {
+	j.delayed[meta.ID] = time.AfterFunc(delay, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		delete(j.delayed, meta.ID)
+		if meta.Status != StatusQueued {
+			return // canceled meanwhile
+		}
+		meta.RetryAt = nil
+		meta.enqueuedAt = time.Now()
+		if !j.enqueueLocked(meta) {
+			// queues full: try again shortly, like the dispatcher does when a pool is full
+			j.enqueueAfterLocked(meta, 200*time.Millisecond)
+		}
+	})
+}
+
+// stopDelayedLocked cancels the pending enqueue of a job, if any.
+// Must be called with j.mu held.
+func (j *JobManager) stopDelayedLocked(id string) {
+	if t, ok := j.delayed[id]; ok {
+		t.Stop()
+		delete(j.delayed, id)
+	}
+}
+
+// ConfigureSchedules loads the schedules saved at path, keeps the file
+// updated from then on and starts running them. A schedule whose run was
+// missed while the server was down runs once right away.
+func (j *JobManager) ConfigureSchedules(path string) error {
+	var saved []*Schedule
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read schedules: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("decode schedules: %w", err)
+		}
+	}
+
+	j.mu.Lock()
+	j.schedulesPath = path
+	for _, s := range saved {
+		if err := s.compile(); err != nil {
+			util.Warn("skipping invalid schedule", util.Fields{"schedule_id": s.ID, "error": err.Error()})
+			continue
+		}
+		if !s.Paused && s.NextRunAt == nil {
+			s.planNext(time.Now())
+		}
+		j.schedules[s.ID] = s
+	}
+	started := j.schedulesOn
+	j.schedulesOn = true
+	j.mu.Unlock()
+
+	if !started {
+		j.wg.Add(1)
+		go j.scheduleLoop()
+	}
+	return nil
+}
+
+func (j *JobManager) scheduleLoop() {
+	defer j.wg.Done()
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+	j.runDueSchedules(time.Now())
+	for {
+		select {
+		case <-j.stop:
+			return
+		case now := <-ticker.C:
+			j.runDueSchedules(now)
+		}
+	}
+}
+
+// runDueSchedules submits a job for every schedule whose next run has come.
+func (j *JobManager) runDueSchedules(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	changed := false
+	for _, s := range j.schedules {
+		if s.Paused || s.NextRunAt == nil || s.NextRunAt.After(now) {
+			continue
+		}
+		labels := make(map[string]string, len(s.Labels)+1)
+		for k, v := range s.Labels {
+			labels[k] = v
+		}
+		labels["schedule"] = s.ID
+		params := make(map[string]string, len(s.Params))
+		for k, v := range s.Params {
+			params[k] = v
+		}
+		id, err := j.submitLocked(s.Command, params, s.Priority, SubmitOptions{Labels: labels, Actor: ActorSchedule})
+		s.LastRunAt = &now
+		s.Runs++
+		s.LastJobID, s.LastError = id, ""
+		if err != nil {
+			s.LastError = err.Error()
+			util.Warn("scheduled job not submitted", util.Fields{"schedule_id": s.ID, "command": s.Command, "error": err.Error()})
+		}
+		// missed runs are not replayed, the next one is planned from now
+		s.planNext(now)
+		s.UpdatedAt = now
+		changed = true
+	}
+	if changed {
+		j.saveSchedulesLocked()
+	}
+}
+
+// CreateSchedule validates spec and adds a recurring job.
+func (j *JobManager) CreateSchedule(spec ScheduleSpec) (*Schedule, error) {
+	if spec.Command == "" {
+		return nil, fmt.Errorf("%w: missing command", ErrInvalidSchedule)
+	}
+	switch spec.Priority {
+	case "":
+		spec.Priority = PriorityNormal
+	case PriorityHigh, PriorityNormal, PriorityLow:
+	default:
+		return nil, fmt.Errorf("%w: invalid priority %q", ErrInvalidSchedule, spec.Priority)
+	}
+	now := time.Now()
+	s := &Schedule{ScheduleSpec: spec, ID: util.NewRequestID(), CreatedAt: now, UpdatedAt: now}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	s.planNext(now)
+	if s.NextRunAt == nil {
+		return nil, fmt.Errorf("%w: %q never runs", ErrInvalidSchedule, spec.Cron)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.schedules[s.ID] = s
+	j.saveSchedulesLocked()
+	util.Info("schedule created", util.Fields{"schedule_id": s.ID, "cron": s.Cron, "command": s.Command, "next_run_at": s.NextRunAt})
+	return s.clone(), nil
+}
+
+// ListSchedules returns every schedule, oldest first.
+func (j *JobManager) ListSchedules() []*Schedule {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]*Schedule, 0, len(j.schedules))
+	for _, s := range j.schedules {
+		out = append(out, s.clone())
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.Before(out[b].CreatedAt) })
+	return out
+}
+
+// GetSchedule returns one schedule.
+func (j *JobManager) GetSchedule(id string) (*Schedule, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s, ok := j.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return s.clone(), nil
+}
+
+// PauseSchedule stops a schedule from running until it is resumed.
+func (j *JobManager) PauseSchedule(id string) (*Schedule, error) {
+	return j.updateSchedule(id, func(s *Schedule, now time.Time) {
+		s.Paused = true
+		s.NextRunAt = nil
+	})
+}
+
+// ResumeSchedule plans the next run of a paused schedule from now; runs
+// missed while paused are skipped.
+func (j *JobManager) ResumeSchedule(id string) (*Schedule, error) {
+	return j.updateSchedule(id, func(s *Schedule, now time.Time) {
+		if s.Paused {
+			s.Paused = false
+			s.planNext(now)
+		}
+	})
+}
+
+// DeleteSchedule removes a schedule. Jobs it already submitted are kept.
+func (j *JobManager) DeleteSchedule(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.schedules[id]; !ok {
+		return ErrScheduleNotFound
+	}
+	delete(j.schedules, id)
+	j.saveSchedulesLocked()
+	return nil
+}
+
+func (j *JobManager) updateSchedule(id string, fn func(s *Schedule, now time.Time)) (*Schedule, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s, ok := j.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	now := time.Now()
+	fn(s, now)
+	s.UpdatedAt = now
+	j.saveSchedulesLocked()
+	return s.clone(), nil
+}
+
+// saveSchedulesLocked rewrites the schedules file. Must be called with j.mu held.
+func (j *JobManager) saveSchedulesLocked() {
+	if j.schedulesPath == "" {
+		return
+	}
+	list := make([]*Schedule, 0, len(j.schedules))
+	for _, s := range j.schedules {
+		list = append(list, s)
+	}
+	if err := writeJSONAtomic(j.schedulesPath, list); err != nil {
+		util.Error("schedule store write failed", util.Fields{"error": err.Error()})
+	}
+}
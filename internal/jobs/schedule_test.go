@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A delayed job stays queued until run_at and then runs normally.
+func TestDelayedJob(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	runAt := time.Now().Add(200 * time.Millisecond)
+	id, err := j.SubmitWithOptions("fibonacci", map[string]string{"num": "5"}, PriorityNormal, SubmitOptions{RunAt: &runAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if meta, _ := j.GetMeta(id); meta.Status != StatusQueued {
+		t.Fatalf("job ran before run_at: %s", meta.Status)
+	}
+	meta, done, err := j.Wait(id, 5*time.Second)
+	if err != nil || !done || meta.Status != StatusDone {
+		t.Fatalf("delayed job did not finish: %v %+v", err, meta)
+	}
+	if meta.StartedAt.Before(runAt) {
+		t.Fatalf("started at %v, before run_at %v", meta.StartedAt, runAt)
+	}
+}
+
+// Due schedules submit a labeled job and plan their next run; paused ones do
+// not run, and the schedules survive a restart.
+func TestSchedulesRunPauseAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ConfigureSchedules(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.CreateSchedule(ScheduleSpec{Cron: "61 * * * *", Command: "fibonacci"}); err == nil {
+		t.Fatal("expected an invalid cron expression to be rejected")
+	}
+	s, err := j.CreateSchedule(ScheduleSpec{Cron: "@hourly", Command: "fibonacci", Params: map[string]string{"num": "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := *s.NextRunAt
+
+	j.runDueSchedules(due.Add(-time.Second))
+	if got, _ := j.GetSchedule(s.ID); got.Runs != 0 {
+		t.Fatal("schedule ran before its time")
+	}
+	j.runDueSchedules(due)
+	got, _ := j.GetSchedule(s.ID)
+	if got.Runs != 1 || got.LastJobID == "" || !got.NextRunAt.Equal(due.Add(time.Hour)) {
+		t.Fatalf("unexpected schedule after run: %+v", got)
+	}
+	meta, err := j.GetMeta(got.LastJobID)
+	if err != nil || meta.Labels["schedule"] != s.ID || meta.Params["num"] != "3" {
+		t.Fatalf("unexpected scheduled job: %v %+v", err, meta)
+	}
+
+	if _, err := j.PauseSchedule(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	j.runDueSchedules(due.Add(2 * time.Hour))
+	if got, _ := j.GetSchedule(s.ID); got.Runs != 1 || got.NextRunAt != nil {
+		t.Fatalf("paused schedule ran: %+v", got)
+	}
+	j.Close()
+
+	j2, err := NewJobManagerWithStore(NewMemoryStore(), 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j2.Close()
+	if err := j2.ConfigureSchedules(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err = j2.GetSchedule(s.ID)
+	if err != nil || !got.Paused || got.Runs != 1 {
+		t.Fatalf("schedule not restored: %v %+v", err, got)
+	}
+	resumed, err := j2.ResumeSchedule(s.ID)
+	if err != nil || resumed.Paused || resumed.NextRunAt == nil {
+		t.Fatalf("resume failed: %v %+v", err, resumed)
+	}
+	if err := j2.DeleteSchedule(s.ID); err != nil || len(j2.ListSchedules()) != 0 {
+		t.Fatalf("delete failed: %v", err)
+	}
+}
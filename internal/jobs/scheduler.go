@@ -0,0 +1,344 @@
+package jobs
+
+import (
+	"sort"
+	"time"
+)
+
+// dispatcherHeartbeat bounds how long the dispatcher sleeps with nothing to
+// do, so its liveness beat stays fresh for /readyz.
+const dispatcherHeartbeat = time.Second
+
+// priority levels of the scheduler, highest first
+const (
+	levelHigh = iota
+	levelNormal
+	levelLow
+	numLevels
+)
+
+func levelOf(p Priority) int {
+	switch p {
+	case PriorityHigh:
+		return levelHigh
+	case PriorityLow:
+		return levelLow
+	default:
+		return levelNormal
+	}
+}
+
+// SchedulerConfig tunes how the dispatcher picks the next queued job.
+type SchedulerConfig struct {
+	// Weights is the share of dispatches each priority gets while several
+	// have work waiting. Missing or non-positive weights default to 1.
+	Weights map[Priority]int
+	// Aging promotes a job one priority level for every Aging it has waited,
+	// so low priority jobs are not starved. Zero disables aging.
+	Aging time.Duration
+	// BlockedBackoff is how long a command whose pool was full is skipped
+	// unless one of its jobs finishes earlier. Defaults to 200ms.
+	BlockedBackoff time.Duration
+}
+
+// DefaultSchedulerConfig is used until ConfigureScheduler is called.
+var DefaultSchedulerConfig = SchedulerConfig{
+	Weights:        map[Priority]int{PriorityHigh: 4, PriorityNormal: 2, PriorityLow: 1},
+	Aging:          30 * time.Second,
+	BlockedBackoff: 200 * time.Millisecond,
+}
+
+// scheduler holds the queued jobs of the manager in per-command backlogs, one
+// FIFO per priority level, and picks the next one with a smooth weighted
+// round robin over the levels. A command whose pool is full is skipped
+// without holding back the others. All methods must be called with j.mu held.
+type scheduler struct {
+	cfg      SchedulerConfig
+	weights  [numLevels]int
+	current  [numLevels]int // smooth weighted round robin state
+	backlogs map[string]*backlog
+	blocked  map[string]time.Time // command -> skipped until
+	paused   map[string]bool      // commands whose backlog is held, see pause.go
+	remote   map[string]int       // command -> live remote workers serving it, see remote.go
+	size     int
+	capacity int
+	wake     chan struct{} // signaled when there may be something to dispatch
+	ready    chan struct{} // closed and replaced on every signal, for remote workers
+}
+
+// backlog is the queued jobs of one command, oldest first within a level.
+type backlog struct {
+	levels [numLevels][]*JobMeta
+}
+
+func newScheduler(cfg SchedulerConfig, capacity int) *scheduler {
+	s := &scheduler{
+		backlogs: make(map[string]*backlog),
+		blocked:  make(map[string]time.Time),
+		paused:   make(map[string]bool),
+		remote:   make(map[string]int),
+		capacity: capacity,
+		wake:     make(chan struct{}, 1),
+		ready:    make(chan struct{}),
+	}
+	s.configure(cfg)
+	return s
+}
+
+func (s *scheduler) configure(cfg SchedulerConfig) {
+	if cfg.BlockedBackoff <= 0 {
+		cfg.BlockedBackoff = DefaultSchedulerConfig.BlockedBackoff
+	}
+	s.cfg = cfg
+	for _, p := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		w := cfg.Weights[p]
+		if w <= 0 {
+			w = 1
+		}
+		s.weights[levelOf(p)] = w
+	}
+	s.current = [numLevels]int{}
+}
+
+func (s *scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	close(s.ready)
+	s.ready = make(chan struct{})
+}
+
+// add queues meta at its own priority level. Returns false when the
+// scheduler is at capacity.
+func (s *scheduler) add(meta *JobMeta) bool {
+	if s.size >= s.capacity {
+		return false
+	}
+	s.insert(meta, levelOf(meta.Priority))
+	s.signal()
+	return true
+}
+
+// insert places meta in level keeping the level ordered by enqueuedAt, so a
+// job put back after its pool was full keeps its turn.
+func (s *scheduler) insert(meta *JobMeta, level int) {
+	b, ok := s.backlogs[meta.Command]
+	if !ok {
+		b = &backlog{}
+		s.backlogs[meta.Command] = b
+	}
+	q := b.levels[level]
+	i := sort.Search(len(q), func(i int) bool { return q[i].enqueuedAt.After(meta.enqueuedAt) })
+	q = append(q, nil)
+	copy(q[i+1:], q[i:])
+	q[i] = meta
+	b.levels[level] = q
+	s.size++
+}
+
+// remove drops meta from its backlog, if it is queued there.
+func (s *scheduler) remove(meta *JobMeta) bool {
+	b, ok := s.backlogs[meta.Command]
+	if !ok {
+		return false
+	}
+	for level, q := range b.levels {
+		for i, m := range q {
+			if m == meta {
+				b.levels[level] = append(q[:i:i], q[i+1:]...)
+				s.size--
+				s.dropEmpty(meta.Command)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *scheduler) dropEmpty(command string) {
+	b := s.backlogs[command]
+	for _, q := range b.levels {
+		if len(q) > 0 {
+			return
+		}
+	}
+	delete(s.backlogs, command)
+}
+
+// block skips command until now plus the configured backoff.
+func (s *scheduler) block(command string, now time.Time) {
+	s.blocked[command] = now.Add(s.cfg.BlockedBackoff)
+}
+
+// unblock makes command eligible again, for example when one of its jobs
+// finished and its pool has room.
+func (s *scheduler) unblock(command string) {
+	if _, ok := s.blocked[command]; ok {
+		delete(s.blocked, command)
+		s.signal()
+	}
+}
+
+// age promotes the jobs that have waited long enough to the level above.
+func (s *scheduler) age(now time.Time) {
+	if s.cfg.Aging <= 0 {
+		return
+	}
+	for _, b := range s.backlogs {
+		for level := levelNormal; level < numLevels; level++ {
+			q := b.levels[level]
+			kept := q[:0]
+			var promoted []*JobMeta
+			for _, m := range q {
+				// levels above the one it was submitted with, once moved
+				steps := levelOf(m.Priority) - level + 1
+				if now.Sub(m.enqueuedAt) >= time.Duration(steps)*s.cfg.Aging {
+					promoted = append(promoted, m)
+				} else {
+					kept = append(kept, m)
+				}
+			}
+			if len(promoted) == 0 {
+				continue
+			}
+			for i := len(kept); i < len(q); i++ {
+				q[i] = nil
+			}
+			b.levels[level] = kept
+			for _, m := range promoted {
+				s.size--
+				s.insert(m, level-1)
+			}
+		}
+	}
+}
+
+// next removes and returns the job to dispatch now. When there is none it
+// returns how long until a blocked command may be retried, or zero if the
+// dispatcher should just wait for a signal. Paused commands and commands
+// served by remote workers are skipped.
+func (s *scheduler) next(now time.Time) (*JobMeta, time.Duration) {
+	return s.pick(now, nil)
+}
+
+// nextRemote removes and returns the job a remote worker serving commands
+// should run now, if any. Blocked commands are not skipped: blocking is about
+// the local pools.
+func (s *scheduler) nextRemote(now time.Time, commands map[string]bool) *JobMeta {
+	meta, _ := s.pick(now, commands)
+	return meta
+}
+
+// pick chooses among the local commands when commands is nil, and among
+// commands otherwise.
+func (s *scheduler) pick(now time.Time, commands map[string]bool) (*JobMeta, time.Duration) {
+	s.age(now)
+
+	var wait time.Duration
+	var heads [numLevels]string // command whose head is the oldest job of each level
+	for command, until := range s.blocked {
+		if !now.Before(until) {
+			delete(s.blocked, command)
+		}
+	}
+	for command, b := range s.backlogs {
+		if s.paused[command] {
+			continue
+		}
+		if commands != nil {
+			if !commands[command] {
+				continue
+			}
+		} else if s.remote[command] > 0 {
+			continue
+		} else if until, ok := s.blocked[command]; ok {
+			if d := until.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		for level, q := range b.levels {
+			if len(q) == 0 {
+				continue
+			}
+			if cur := heads[level]; cur == "" || olderHead(q[0], command, s.backlogs[cur].levels[level][0], cur) {
+				heads[level] = command
+			}
+		}
+	}
+
+	// smooth weighted round robin among the levels with an eligible job
+	pick, total := -1, 0
+	for level := range heads {
+		if heads[level] == "" {
+			continue
+		}
+		s.current[level] += s.weights[level]
+		total += s.weights[level]
+		if pick < 0 || s.current[level] > s.current[pick] {
+			pick = level
+		}
+	}
+	if pick < 0 {
+		return nil, wait
+	}
+	s.current[pick] -= total
+
+	command := heads[pick]
+	b := s.backlogs[command]
+	meta := b.levels[pick][0]
+	b.levels[pick] = b.levels[pick][1:]
+	s.size--
+	s.dropEmpty(command)
+	return meta, 0
+}
+
+// olderHead orders the heads of two backlogs: oldest first, ties by command
+// name so the choice does not depend on map order.
+func olderHead(a *JobMeta, aCmd string, b *JobMeta, bCmd string) bool {
+	if !a.enqueuedAt.Equal(b.enqueuedAt) {
+		return a.enqueuedAt.Before(b.enqueuedAt)
+	}
+	return aCmd < bCmd
+}
+
+// lengths counts the queued jobs by the priority they were submitted with.
+func (s *scheduler) lengths() map[Priority]int {
+	out := map[Priority]int{PriorityHigh: 0, PriorityNormal: 0, PriorityLow: 0}
+	for _, b := range s.backlogs {
+		for _, q := range b.levels {
+			for _, m := range q {
+				out[m.Priority]++
+			}
+		}
+	}
+	return out
+}
+
+// commandBacklogs counts the queued jobs of every command.
+func (s *scheduler) commandBacklogs() map[string]int {
+	out := make(map[string]int, len(s.backlogs))
+	for command, b := range s.backlogs {
+		for _, q := range b.levels {
+			out[command] += len(q)
+		}
+	}
+	return out
+}
+
+// ConfigureScheduler sets the priority weights, aging and pool-full backoff
+// of the dispatcher.
+func (j *JobManager) ConfigureScheduler(cfg SchedulerConfig) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.sched.configure(cfg)
+	j.sched.signal()
+}
+
+// CommandBacklogs returns the number of queued jobs per command.
+func (j *JobManager) CommandBacklogs() map[string]int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.sched.commandBacklogs()
+}
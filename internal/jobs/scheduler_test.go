@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func queuedJob(id, command string, p Priority, at time.Time) *JobMeta {
+	return &JobMeta{ID: id, Command: command, Priority: p, Status: StatusQueued, enqueuedAt: at}
+}
+
+// With weights 2:1:1 the levels are served in a fixed interleaving, each
+// level in FIFO order, and a level that runs dry leaves the rest to the others.
+func TestSchedulerWeightedOrder(t *testing.T) {
+	s := newScheduler(SchedulerConfig{Weights: map[Priority]int{PriorityHigh: 2, PriorityNormal: 1, PriorityLow: 1}}, 100)
+	t0 := time.Unix(1000, 0)
+	add := func(prefix string, p Priority, n int) {
+		for i := 0; i < n; i++ {
+			s.add(queuedJob(prefix+string(rune('0'+i)), "reverse", p, t0.Add(time.Duration(len(prefix)*10+i)*time.Millisecond)))
+		}
+	}
+	add("H", PriorityHigh, 4)
+	add("N", PriorityNormal, 4)
+	add("L", PriorityLow, 2)
+
+	var got []string
+	for {
+		meta, _ := s.next(t0)
+		if meta == nil {
+			break
+		}
+		got = append(got, meta.ID)
+	}
+	want := "H0 N0 L0 H1 H2 N1 L1 H3 N2 N3"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("order = %s, want %s", strings.Join(got, " "), want)
+	}
+	if s.size != 0 || len(s.backlogs) != 0 {
+		t.Fatalf("scheduler not empty: size=%d backlogs=%d", s.size, len(s.backlogs))
+	}
+}
+
+// A command whose pool is full does not hold back the others.
+func TestSchedulerBlockedCommand(t *testing.T) {
+	s := newScheduler(SchedulerConfig{BlockedBackoff: time.Second}, 100)
+	t0 := time.Unix(1000, 0)
+	s.add(queuedJob("a1", "pi", PriorityHigh, t0))
+	s.add(queuedJob("b1", "reverse", PriorityNormal, t0.Add(time.Millisecond)))
+	s.block("pi", t0)
+
+	if meta, _ := s.next(t0); meta == nil || meta.ID != "b1" {
+		t.Fatalf("expected b1 while pi is blocked, got %+v", meta)
+	}
+	meta, wait := s.next(t0.Add(100 * time.Millisecond))
+	if meta != nil || wait != 900*time.Millisecond {
+		t.Fatalf("expected to wait 900ms for pi, got %+v %v", meta, wait)
+	}
+	s.unblock("pi")
+	if meta, _ := s.next(t0.Add(100 * time.Millisecond)); meta == nil || meta.ID != "a1" {
+		t.Fatalf("expected a1 after unblock, got %+v", meta)
+	}
+}
+
+// A low priority job that waited long enough competes as a high one.
+func TestSchedulerAging(t *testing.T) {
+	s := newScheduler(SchedulerConfig{
+		Weights: map[Priority]int{PriorityHigh: 100, PriorityNormal: 10, PriorityLow: 1},
+		Aging:   time.Second,
+	}, 100)
+	t0 := time.Unix(1000, 0)
+	s.add(queuedJob("low", "reverse", PriorityLow, t0))
+	for i := 0; i < 3; i++ {
+		s.add(queuedJob("high"+string(rune('0'+i)), "reverse", PriorityHigh, t0.Add(1500*time.Millisecond)))
+	}
+
+	if meta, _ := s.next(t0.Add(1600 * time.Millisecond)); meta.ID != "high0" {
+		t.Fatalf("low job promoted only to normal must wait, got %s", meta.ID)
+	}
+	if meta, _ := s.next(t0.Add(2 * time.Second)); meta.ID != "low" {
+		t.Fatalf("low job aged to high must go first, got %s", meta.ID)
+	}
+	if l := s.lengths(); l[PriorityHigh] != 2 || l[PriorityLow] != 0 {
+		t.Fatalf("unexpected lengths %v", l)
+	}
+}
+
+func TestSchedulerCapacityAndRemove(t *testing.T) {
+	s := newScheduler(DefaultSchedulerConfig, 2)
+	t0 := time.Unix(1000, 0)
+	a := queuedJob("a", "reverse", PriorityNormal, t0)
+	if !s.add(a) || !s.add(queuedJob("b", "reverse", PriorityNormal, t0)) {
+		t.Fatal("expected room for two jobs")
+	}
+	if s.add(queuedJob("c", "reverse", PriorityNormal, t0)) {
+		t.Fatal("expected the scheduler to be full")
+	}
+	if !s.remove(a) || s.remove(a) {
+		t.Fatal("remove must drop the job exactly once")
+	}
+	if meta, _ := s.next(t0); meta == nil || meta.ID != "b" {
+		t.Fatalf("expected b, got %+v", meta)
+	}
+}
@@ -0,0 +1,634 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// DefaultSegmentBytes is the size at which the active segment is rotated.
+const DefaultSegmentBytes = 16 * 1024 * 1024
+
+const (
+	segmentPrefix = "seg-"
+	segmentSuffix = ".log"
+)
+
+// segmentRecord is the payload of one segment line.
+type segmentRecord struct {
+	Op   string   `json:"op"` // "put" or "del"
+	Seq  uint64   `json:"seq"`
+	ID   string   `json:"id"`
+	Meta *JobMeta `json:"meta,omitempty"`
+}
+
+// recordLoc points at the latest record of a job inside a segment.
+type recordLoc struct {
+	seg int
+	off int64
+	len int
+}
+
+// segment is one log file. Only the last segment is written to.
+type segment struct {
+	id   int
+	path string
+	file *os.File
+	size int64
+}
+
+// SegmentedStore persists jobs in a directory of append-only log segments.
+//
+// Each line is "<crc32 hex> <json record>\n". An in-memory index maps job IDs
+// to the offset of their latest record, so Get is a single read. On open,
+// records with a bad checksum are skipped; a torn or corrupted tail of the
+// last segment is truncated so new records are appended after valid data.
+// Compaction rewrites the live records into a new segment and removes the
+// older ones.
+type SegmentedStore struct {
+	mu           sync.Mutex
+	dir          string
+	segmentBytes int64
+	segments     []*segment
+	index        map[string]recordLoc
+	liveBytes    int64
+	seq          uint64
+	corrupted    int64
+	compaction   CompactionConfig
+	stats        CompactionStats
+	stop         chan struct{}
+	loopStop     chan struct{} // stops the running compaction loop, if any
+	wg           sync.WaitGroup
+}
+
+// OpenSegmentedStore opens (or creates) a segmented store in dir. A
+// segmentBytes of 0 uses DefaultSegmentBytes.
+func OpenSegmentedStore(dir string, segmentBytes int64) (*SegmentedStore, error) {
+	if segmentBytes <= 0 {
+		segmentBytes = DefaultSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+	s := &SegmentedStore{
+		dir:          dir,
+		segmentBytes: segmentBytes,
+		index:        make(map[string]recordLoc),
+		stop:         make(chan struct{}),
+	}
+
+	ids, err := s.segmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		if err := s.loadSegment(id, i == len(ids)-1); err != nil {
+			s.closeSegments()
+			return nil, err
+		}
+	}
+	if len(s.segments) == 0 {
+		if err := s.openSegment(1); err != nil {
+			return nil, err
+		}
+	}
+	if s.corrupted > 0 {
+		util.Warn("skipped corrupted store records", util.Fields{"dir": dir, "records": s.corrupted})
+	}
+	return s, nil
+}
+
+func (s *SegmentedStore) segmentPath(id int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%06d%s", segmentPrefix, id, segmentSuffix))
+}
+
+// segmentIDs lists the segment numbers in the directory in ascending order
+// and removes temp files left by an interrupted compaction.
+func (s *SegmentedStore) segmentIDs() ([]int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read store dir: %w", err)
+	}
+	var ids []int
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, n)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// loadSegment replays one segment into the index. When last is true the
+// segment becomes the active one and any invalid tail is truncated.
+func (s *SegmentedStore) loadSegment(id int, last bool) error {
+	path := s.segmentPath(id)
+	flags := os.O_RDONLY
+	if last {
+		flags = os.O_RDWR
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("open segment: %w", err)
+	}
+
+	var off, validEnd int64
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			break
+		}
+		n := len(line)
+		torn := err != nil // no trailing newline: the write was interrupted
+		if rec, ok := decodeSegmentLine(line); ok && !torn {
+			s.apply(rec, recordLoc{seg: id, off: off, len: n})
+			validEnd = off + int64(n)
+		} else {
+			s.corrupted++
+		}
+		off += int64(n)
+		if err != nil {
+			break
+		}
+	}
+
+	if last && validEnd < off {
+		util.Warn("truncating torn store segment", util.Fields{"segment": path, "from": off, "to": validEnd})
+		if err := f.Truncate(validEnd); err != nil {
+			f.Close()
+			return fmt.Errorf("truncate segment: %w", err)
+		}
+		off = validEnd
+	}
+	s.segments = append(s.segments, &segment{id: id, path: path, file: f, size: off})
+	return nil
+}
+
+// decodeSegmentLine checks the checksum of a line and decodes its record.
+func decodeSegmentLine(line []byte) (segmentRecord, bool) {
+	var rec segmentRecord
+	line = bytes.TrimRight(line, "\n")
+	sp := bytes.IndexByte(line, ' ')
+	if sp <= 0 {
+		return rec, false
+	}
+	sum, err := strconv.ParseUint(string(line[:sp]), 16, 32)
+	if err != nil {
+		return rec, false
+	}
+	payload := line[sp+1:]
+	if crc32.ChecksumIEEE(payload) != uint32(sum) {
+		return rec, false
+	}
+	if err := json.Unmarshal(payload, &rec); err != nil || rec.ID == "" {
+		return rec, false
+	}
+	return rec, true
+}
+
+func encodeSegmentLine(rec segmentRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(payload)+10)
+	line = append(line, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(payload))...)
+	line = append(line, payload...)
+	return append(line, '\n'), nil
+}
+
+// apply updates the index with a replayed or freshly written record.
+func (s *SegmentedStore) apply(rec segmentRecord, loc recordLoc) {
+	if rec.Seq > s.seq {
+		s.seq = rec.Seq
+	}
+	if old, ok := s.index[rec.ID]; ok {
+		s.liveBytes -= int64(old.len)
+	}
+	if rec.Op == "del" {
+		delete(s.index, rec.ID)
+		return
+	}
+	s.index[rec.ID] = loc
+	s.liveBytes += int64(loc.len)
+}
+
+func (s *SegmentedStore) active() *segment {
+	return s.segments[len(s.segments)-1]
+}
+
+func (s *SegmentedStore) openSegment(id int) error {
+	path := s.segmentPath(id)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("create segment: %w", err)
+	}
+	s.segments = append(s.segments, &segment{id: id, path: path, file: f})
+	return syncDir(s.dir)
+}
+
+// appendLocked writes a record to the active segment, rotating it first when
+// it is full. Must be called with s.mu held.
+func (s *SegmentedStore) appendLocked(rec segmentRecord) error {
+	line, err := encodeSegmentLine(rec)
+	if err != nil {
+		return err
+	}
+	if s.active().size > 0 && s.active().size+int64(len(line)) > s.segmentBytes {
+		if err := s.openSegment(s.active().id + 1); err != nil {
+			return err
+		}
+	}
+	seg := s.active()
+	off := seg.size
+	n, err := seg.file.WriteAt(line, off)
+	if err != nil {
+		// a partial write is truncated on the next open
+		return fmt.Errorf("segment write: %w", err)
+	}
+	seg.size += int64(n)
+	if err := seg.file.Sync(); err != nil {
+		return fmt.Errorf("segment fsync: %w", err)
+	}
+	s.apply(rec, recordLoc{seg: seg.id, off: off, len: n})
+	return nil
+}
+
+func (s *SegmentedStore) Put(meta *JobMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	meta.Seq = s.seq
+	if err := s.appendLocked(segmentRecord{Op: "put", Seq: meta.Seq, ID: meta.ID, Meta: meta}); err != nil {
+		return err
+	}
+	if s.shouldCompactForSize() {
+		s.compactLocked("size")
+	}
+	return nil
+}
+
+func (s *SegmentedStore) Get(id string) (*JobMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc, ok := s.index[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return s.readLocked(loc)
+}
+
+// readLocked reads and verifies the record at loc. Must be called with s.mu held.
+func (s *SegmentedStore) readLocked(loc recordLoc) (*JobMeta, error) {
+	var seg *segment
+	for _, sg := range s.segments {
+		if sg.id == loc.seg {
+			seg = sg
+			break
+		}
+	}
+	if seg == nil {
+		return nil, fmt.Errorf("segment %d not open", loc.seg)
+	}
+	buf := make([]byte, loc.len)
+	if _, err := seg.file.ReadAt(buf, loc.off); err != nil {
+		return nil, fmt.Errorf("segment read: %w", err)
+	}
+	rec, ok := decodeSegmentLine(buf)
+	if !ok || rec.Meta == nil {
+		return nil, fmt.Errorf("corrupted record in %s at offset %d", seg.path, loc.off)
+	}
+	return rec.Meta, nil
+}
+
+func (s *SegmentedStore) List() ([]*JobMeta, error) {
+	s.mu.Lock()
+	out := make([]*JobMeta, 0, len(s.index))
+	for _, loc := range s.index {
+		meta, err := s.readLocked(loc)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		out = append(out, meta)
+	}
+	s.mu.Unlock()
+	sortByCreation(out)
+	return out, nil
+}
+
+func (s *SegmentedStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[id]; !ok {
+		return nil
+	}
+	s.seq++
+	return s.appendLocked(segmentRecord{Op: "del", Seq: s.seq, ID: id})
+}
+
+func (s *SegmentedStore) Iterate(fn func(meta *JobMeta) bool) error {
+	list, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, meta := range list {
+		if !fn(meta) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *SegmentedStore) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeSegments()
+}
+
+func (s *SegmentedStore) closeSegments() error {
+	var first error
+	for _, seg := range s.segments {
+		if seg.file == nil {
+			continue
+		}
+		if err := seg.file.Close(); err != nil && first == nil {
+			first = err
+		}
+		seg.file = nil
+	}
+	s.segments = nil
+	return first
+}
+
+func (s *SegmentedStore) Location() string {
+	return s.dir
+}
+
+// Probe checks the active segment is still writable.
+func (s *SegmentedStore) Probe() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) == 0 {
+		return errors.New("store not open")
+	}
+	info, err := os.Stat(s.active().path)
+	if err != nil {
+		return fmt.Errorf("stat segment: %w", err)
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return errors.New("segment is read-only")
+	}
+	return nil
+}
+
+func (s *SegmentedStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) == 0 {
+		return errors.New("store not open")
+	}
+	if err := s.active().file.Sync(); err != nil {
+		return fmt.Errorf("fsync segment: %w", err)
+	}
+	return nil
+}
+
+// ConfigureCompaction sets the compaction triggers and starts the periodic
+// compaction loop when an interval is given.
+func (s *SegmentedStore) ConfigureCompaction(cfg CompactionConfig) {
+	s.mu.Lock()
+	s.compaction = cfg
+	if s.loopStop != nil {
+		close(s.loopStop) // configured again: the new interval replaces the old loop
+		s.loopStop = nil
+	}
+	var loopStop chan struct{}
+	if cfg.Interval > 0 {
+		loopStop = make(chan struct{})
+		s.loopStop = loopStop
+	}
+	s.mu.Unlock()
+	if loopStop != nil {
+		s.wg.Add(1)
+		go s.compactionLoop(cfg.Interval, loopStop)
+	}
+}
+
+func (s *SegmentedStore) compactionLoop(interval time.Duration, loopStop <-chan struct{}) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-loopStop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.compactLocked("interval")
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *SegmentedStore) totalBytesLocked() int64 {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// shouldCompactForSize reports whether the size trigger fired: the store is
+// past MaxBytes and at least half of it is superseded records, so live data
+// larger than MaxBytes does not cause a compaction on every write.
+// Must be called with s.mu held.
+func (s *SegmentedStore) shouldCompactForSize() bool {
+	if s.compaction.MaxBytes <= 0 {
+		return false
+	}
+	total := s.totalBytesLocked()
+	if total < s.compaction.MaxBytes || total-s.liveBytes < total/2 {
+		return false
+	}
+	if s.stats.LastError != "" && time.Since(s.stats.LastAt) < compactionRetryDelay {
+		return false
+	}
+	return true
+}
+
+// Compact forces a compaction of the store.
+func (s *SegmentedStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked("manual")
+}
+
+// CompactionStats returns the compaction statistics and current sizes.
+// JournalBytes is the size of every segment together.
+func (s *SegmentedStore) CompactionStats() CompactionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.JournalBytes = s.totalBytesLocked()
+	st.Seq = s.seq
+	st.Segments = len(s.segments)
+	st.CorruptedRecords = s.corrupted
+	return st
+}
+
+// compactLocked copies the live records into a new segment placed after all
+// existing ones and then removes the old segments in ascending order.
+// Must be called with s.mu held.
+//
+// A crash before the rename leaves only a temp file, removed on open. A crash
+// while deleting old segments is harmless: the compacted segment is replayed
+// last, and deleting oldest first never leaves a put without the delete that
+// superseded it.
+func (s *SegmentedStore) compactLocked(trigger string) error {
+	if len(s.segments) == 0 {
+		return errors.New("store not open")
+	}
+	start := time.Now()
+	before := s.totalBytesLocked()
+
+	err := s.rewriteLive()
+
+	s.stats.LastAt = start
+	s.stats.LastTrigger = trigger
+	s.stats.LastDurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		s.stats.LastError = err.Error()
+		util.Error("store compaction failed", util.Fields{"trigger": trigger, "error": err.Error()})
+		return err
+	}
+	s.stats.Count++
+	s.stats.LastError = ""
+	s.stats.LastJobs = len(s.index)
+	s.stats.LastBytesBefore = before
+	s.stats.LastBytesAfter = s.totalBytesLocked()
+	util.Info("store compacted", util.Fields{
+		"trigger":      trigger,
+		"jobs":         len(s.index),
+		"bytes_before": before,
+		"bytes_after":  s.stats.LastBytesAfter,
+		"duration_ms":  s.stats.LastDurationMs,
+	})
+	return nil
+}
+
+func (s *SegmentedStore) rewriteLive() error {
+	compactedID := s.active().id + 1
+	path := s.segmentPath(compactedID)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("create compacted segment: %w", err)
+	}
+
+	// oldest seq first, so replaying the segment keeps the original order
+	ids := make([]string, 0, len(s.index))
+	for id := range s.index {
+		ids = append(ids, id)
+	}
+	metas := make(map[string]*JobMeta, len(ids))
+	for _, id := range ids {
+		meta, err := s.readLocked(s.index[id])
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+		metas[id] = meta
+	}
+	sort.Slice(ids, func(a, b int) bool { return metas[ids[a]].Seq < metas[ids[b]].Seq })
+
+	index := make(map[string]recordLoc, len(ids))
+	var off, live int64
+	w := bufio.NewWriter(f)
+	for _, id := range ids {
+		meta := metas[id]
+		line, err := encodeSegmentLine(segmentRecord{Op: "put", Seq: meta.Seq, ID: id, Meta: meta})
+		if err == nil {
+			_, err = w.Write(line)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("write compacted segment: %w", err)
+		}
+		index[id] = recordLoc{seg: compactedID, off: off, len: len(line)}
+		off += int64(len(line))
+		live += int64(len(line))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("write compacted segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("fsync compacted segment: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("install compacted segment: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	// the compacted segment becomes the active one
+	nf, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("reopen compacted segment: %w", err)
+	}
+	old := s.segments
+	s.segments = []*segment{{id: compactedID, path: path, file: nf, size: off}}
+	s.index = index
+	s.liveBytes = live
+
+	for _, seg := range old {
+		seg.file.Close()
+		if err := os.Remove(seg.path); err != nil {
+			util.Warn("unable to remove compacted segment", util.Fields{"segment": seg.path, "error": err.Error()})
+		}
+	}
+	return syncDir(s.dir)
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A torn last record must be dropped without losing the records before it,
+// and the store must keep appending after the valid data.
+func TestSegmentedStoreTornTail(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSegmentedStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Put(&JobMeta{ID: id, Status: StatusDone, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Delete("b")
+	s.Close()
+
+	seg := filepath.Join(dir, "seg-000001.log")
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`1234abcd {"op":"put","seq":9,"id":"d","me`)
+	f.Close()
+
+	s, err = OpenSegmentedStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get("a"); err != nil {
+		t.Fatalf("a: %v", err)
+	}
+	if _, err := s.Get("b"); err != ErrJobNotFound {
+		t.Fatalf("b should stay deleted, got %v", err)
+	}
+	if _, err := s.Get("d"); err != ErrJobNotFound {
+		t.Fatalf("torn record d should be dropped, got %v", err)
+	}
+
+	if err := s.Put(&JobMeta{ID: "e", Status: StatusQueued, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].ID != "a" || list[2].ID != "e" {
+		t.Fatalf("unexpected jobs after compaction: %+v", list)
+	}
+}
+
+func TestSegmentedStoreReconfigureCompaction(t *testing.T) {
+	s, err := OpenSegmentedStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Put(&JobMeta{ID: "a", Status: StatusDone, CreatedAt: time.Now()})
+	checkReconfigureStopsLoop(t, s)
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ErrIllegalTransition is returned when a status change is not allowed by
+// the job state machine.
+var ErrIllegalTransition = errors.New("illegal job status transition")
+
+// Actors recorded in the history of a job.
+const (
+	ActorClient     = "client"     // the API caller: submission and cancellation
+	ActorDispatcher = "dispatcher" // handed the job to its worker pool
+	ActorWorker     = "worker"     // the command finished
+	ActorWatchdog   = "watchdog"   // the job ran past its timeout
+	ActorRetry      = "retry"      // a failed attempt was scheduled again
+	ActorRecovery   = "recovery"   // the job was found pending after a restart
+	ActorLease      = "lease"      // the remote worker running the job stopped renewing its lease
+	ActorWorkflow   = "workflow"
+	ActorSchedule   = "schedule"
+)
+
+// transitions lists the statuses each status may move to. A job enters the
+// machine as queued and goes back to queued from running only to be retried
+// or re-run after a restart, and from paused when resumed. The other statuses
+// are final; the retention policy does not move them but replaces the whole
+// job with a tombstone.
+var transitions = map[string][]string{
+	StatusQueued:  {StatusRunning, StatusCanceled, StatusError, StatusPaused},
+	StatusPaused:  {StatusQueued, StatusCanceled},
+	StatusRunning: {StatusDone, StatusError, StatusTimeout, StatusCanceled, StatusQueued},
+}
+
+// Transition is one entry of the status timeline of a job.
+type Transition struct {
+	From   string    `json:"from,omitempty"` // empty for the submission
+	To     string    `json:"to"`
+	At     time.Time `json:"at"`
+	Actor  string    `json:"actor"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// CanTransition reports whether a job may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionLocked moves meta to status to and records it in its history.
+// Illegal moves leave the job untouched. It does not write the journal.
+// Must be called with j.mu held.
+func (j *JobManager) transitionLocked(meta *JobMeta, to, actor, reason string) error {
+	if !CanTransition(meta.Status, to) {
+		util.Warn("illegal job status transition rejected", util.Fields{
+			"job_id": meta.ID, "from": meta.Status, "to": to, "actor": actor, "reason": reason,
+		})
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, meta.Status, to)
+	}
+	now := time.Now()
+	meta.History = append(meta.History, Transition{From: meta.Status, To: to, At: now, Actor: actor, Reason: reason})
+	meta.Status = to
+	meta.UpdatedAt = now
+	return nil
+}
+
+// finishAttemptLocked ends the running attempt of meta with status. Failures
+// the retry policy allows go back to the queue; otherwise the outcome is
+// written to the journal. Must be called with j.mu held.
+func (j *JobManager) finishAttemptLocked(meta *JobMeta, status, errMsg string, statusCode int, actor string) {
+	if status != StatusDone && j.retryLocked(meta, status, errMsg, statusCode) {
+		return
+	}
+	reason := errMsg
+	if status == StatusDone {
+		reason = "completed"
+	}
+	if err := j.transitionLocked(meta, status, actor, reason); err != nil {
+		return
+	}
+	meta.Error = errMsg
+	j.appendToJournal(meta)
+	j.endJobSpan(meta)
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		ok       bool
+	}{
+		{StatusQueued, StatusRunning, true},
+		{StatusQueued, StatusCanceled, true},
+		{StatusQueued, StatusDone, false},
+		{StatusQueued, StatusPaused, true},
+		{StatusPaused, StatusQueued, true},
+		{StatusPaused, StatusRunning, false},
+		{StatusRunning, StatusDone, true},
+		{StatusRunning, StatusQueued, true}, // retry
+		{StatusCanceled, StatusDone, false},
+		{StatusCanceled, StatusTimeout, false},
+		{StatusDone, StatusQueued, false},
+		{StatusTimeout, StatusCanceled, false},
+	}
+	for _, c := range cases {
+		if got := CanTransition(c.from, c.to); got != c.ok {
+			t.Errorf("%s -> %s = %v, want %v", c.from, c.to, got, c.ok)
+		}
+	}
+}
+
+// A completed job has its submission, dispatch and completion in its history.
+func TestHistoryTimeline(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	id, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, done, err := j.Wait(id, 5*time.Second)
+	if err != nil || !done || meta.Status != StatusDone {
+		t.Fatalf("job not done: %v %+v", err, meta)
+	}
+	want := []Transition{
+		{To: StatusQueued, Actor: ActorClient},
+		{From: StatusQueued, To: StatusRunning, Actor: ActorDispatcher},
+		{From: StatusRunning, To: StatusDone, Actor: ActorWorker},
+	}
+	if len(meta.History) != len(want) {
+		t.Fatalf("history = %+v", meta.History)
+	}
+	for i, tr := range meta.History {
+		if tr.From != want[i].From || tr.To != want[i].To || tr.Actor != want[i].Actor || tr.At.IsZero() {
+			t.Fatalf("history[%d] = %+v, want %+v", i, tr, want[i])
+		}
+	}
+}
+
+// Results and timeouts that arrive after a cancellation do not overwrite it.
+func TestCanceledJobIgnoresLateOutcome(t *testing.T) {
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	meta := &JobMeta{ID: "late", Command: "reverse", Priority: PriorityNormal, Status: StatusRunning, CreatedAt: time.Now()}
+	j.mu.Lock()
+	j.store[meta.ID] = meta
+	j.cancelChMap[meta.ID] = make(chan struct{})
+	if err := j.cancelLocked(meta.ID, ActorClient); err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Unlock()
+
+	j.updateJobResult(meta, &types.Response{StatusCode: 200, Body: []byte("cba")})
+	j.mu.Lock()
+	j.finishAttemptLocked(meta, StatusTimeout, "timed out", 0, ActorWatchdog)
+	err = j.transitionLocked(meta, StatusDone, ActorWorker, "")
+	j.mu.Unlock()
+
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("expected ErrIllegalTransition, got %v", err)
+	}
+	got, err := j.GetMeta(meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusCanceled || got.Result != "" {
+		t.Fatalf("status = %s result = %q, want canceled and no result", got.Status, got.Result)
+	}
+	if last := got.History[len(got.History)-1]; last.To != StatusCanceled || last.Actor != ActorClient {
+		t.Fatalf("last transition = %+v", last)
+	}
+}
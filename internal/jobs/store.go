@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var ErrCompactionUnsupported = errors.New("job store does not support compaction")
+
+// JobStore persists job metadata. Implementations must be safe for
+// concurrent use and must not retain the *JobMeta passed to Put.
+type JobStore interface {
+	// Put stores the latest state of a job, replacing any previous one.
+	Put(meta *JobMeta) error
+	// Get returns a copy of the job or ErrJobNotFound.
+	Get(id string) (*JobMeta, error)
+	// List returns copies of every stored job, oldest first.
+	List() ([]*JobMeta, error)
+	// Delete removes a job. Deleting an unknown job is not an error.
+	Delete(id string) error
+	// Iterate calls fn for every stored job until fn returns false.
+	Iterate(fn func(meta *JobMeta) bool) error
+	Close() error
+}
+
+// DurableStore is implemented by stores backed by files on disk.
+type DurableStore interface {
+	JobStore
+	// Location is the file or directory holding the data.
+	Location() string
+	// Probe checks the backing files are still writable.
+	Probe() error
+	// Sync flushes the backing files to disk.
+	Sync() error
+}
+
+// CompactingStore is implemented by stores that can reclaim space taken by
+// superseded records.
+type CompactingStore interface {
+	ConfigureCompaction(cfg CompactionConfig)
+	Compact() error
+	CompactionStats() CompactionStats
+}
+
+// MemoryStore keeps jobs in memory only. It is meant for tests and for
+// deployments that do not need jobs to survive a restart.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	jobs map[string]*JobMeta
+	seq  uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*JobMeta)}
+}
+
+func (s *MemoryStore) Put(meta *JobMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	meta.Seq = s.seq
+	s.jobs[meta.ID] = meta.clone()
+	return nil
+}
+
+func (s *MemoryStore) Get(id string) (*JobMeta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	meta, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return meta.clone(), nil
+}
+
+func (s *MemoryStore) List() ([]*JobMeta, error) {
+	s.mu.RLock()
+	out := make([]*JobMeta, 0, len(s.jobs))
+	for _, meta := range s.jobs {
+		out = append(out, meta.clone())
+	}
+	s.mu.RUnlock()
+	sortByCreation(out)
+	return out, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.jobs, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Iterate(fn func(meta *JobMeta) bool) error {
+	list, _ := s.List()
+	for _, meta := range list {
+		if !fn(meta) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
+
+// clone returns a copy of the job that does not share mutable maps.
+func (m *JobMeta) clone() *JobMeta {
+	c := *m
+	if m.Params != nil {
+		c.Params = make(map[string]string, len(m.Params))
+		for k, v := range m.Params {
+			c.Params[k] = v
+		}
+	}
+	if m.Labels != nil {
+		c.Labels = make(map[string]string, len(m.Labels))
+		for k, v := range m.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if m.Deliveries != nil {
+		c.Deliveries = append([]DeliveryAttempt(nil), m.Deliveries...)
+	}
+	if m.AttemptErrors != nil {
+		c.AttemptErrors = append([]AttemptError(nil), m.AttemptErrors...)
+	}
+	if m.History != nil {
+		c.History = append([]Transition(nil), m.History...)
+	}
+	return &c
+}
+
+func sortByCreation(list []*JobMeta) {
+	sort.Slice(list, func(a, b int) bool {
+		if !list[a].CreatedAt.Equal(list[b].CreatedAt) {
+			return list[a].CreatedAt.Before(list[b].CreatedAt)
+		}
+		return list[a].ID < list[b].ID
+	})
+}
+
+// Store returns the store the manager persists jobs to.
+func (j *JobManager) Store() JobStore {
+	return j.persist
+}
+
+// ConfigureCompaction forwards the compaction settings to the store when it
+// supports compaction.
+func (j *JobManager) ConfigureCompaction(cfg CompactionConfig) {
+	if cs, ok := j.persist.(CompactingStore); ok {
+		cs.ConfigureCompaction(cfg)
+	}
+}
+
+// Compact forces a compaction of the store.
+func (j *JobManager) Compact() error {
+	cs, ok := j.persist.(CompactingStore)
+	if !ok {
+		return ErrCompactionUnsupported
+	}
+	return cs.Compact()
+}
+
+// CompactionStats returns the compaction statistics of the store, or zero
+// stats when it does not support compaction.
+func (j *JobManager) CompactionStats() CompactionStats {
+	if cs, ok := j.persist.(CompactingStore); ok {
+		return cs.CompactionStats()
+	}
+	return CompactionStats{}
+}
+
+// Close stops the dispatcher and closes the store.
+func (j *JobManager) Close() error {
+	select {
+	case <-j.stop:
+	default:
+		close(j.stop)
+	}
+	j.mu.Lock()
+	j.stopWorkflowRetriesLocked()
+	j.mu.Unlock()
+	j.wg.Wait()
+	return j.persist.Close()
+}
+
+// writeJSONAtomic replaces the file at path with v encoded as JSON, through a
+// temporary file and a rename so readers never see a partial write. The
+// temporary file is synced before the rename so a crash cannot leave the
+// path empty or truncated.
+func writeJSONAtomic(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeJSONAtomic replaces the whole file and leaves no temporary file behind.
+func TestWriteJSONAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, v := range [][]string{{"a", "b", "c"}, {"d"}} {
+		if err := writeJSONAtomic(path, v); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		if err := json.Unmarshal(data, &got); err != nil || len(got) != len(v) || got[0] != v[0] {
+			t.Fatalf("file holds %s (%v), want %v", data, err, v)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+}
@@ -5,11 +5,13 @@ import "time"
 // Job status constants
 const (
 	StatusQueued   = "queued"
+	StatusPaused   = "paused" // held out of the queue until resumed
 	StatusRunning  = "running"
 	StatusDone     = "done"
 	StatusError    = "error"
 	StatusCanceled = "canceled"
 	StatusTimeout  = "timeout"
+	StatusExpired  = "expired" // evicted by the retention policy, only the tombstone is left
 )
 
 // Job priority
@@ -27,12 +29,60 @@ type JobMeta struct {
 	ID         string            `json:"id"`
 	Command    string            `json:"command"`
 	Params     map[string]string `json:"params"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Owner      string            `json:"owner,omitempty"` // team or user that submitted the job
 	Priority   Priority          `json:"priority"`
 	Status     string            `json:"status"`
 	Error      string            `json:"error,omitempty"`
 	Result     string            `json:"result,omitempty"`
+	ResultRef  *ResultRef        `json:"result_ref,omitempty"` // set instead of Result when the body is stored as a blob
 	CreatedAt  time.Time         `json:"created_at"`
 	UpdatedAt  time.Time         `json:"updated_at"`
 	TimeoutMs  int               `json:"timeout_ms,omitempty"` // nuevo: timeout individual por job
 	SubmittedAt time.Time        `json:"submitted_at,omitempty"`
+	TraceID    string            `json:"trace_id,omitempty"`
+	SpanID     string            `json:"span_id,omitempty"` // span that covers the whole job lifetime
+	Seq        uint64            `json:"seq,omitempty"`     // journal sequence number of this record
+	ExpiredAt  *time.Time        `json:"expired_at,omitempty"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"` // last time the job started running
+	History    []Transition      `json:"history,omitempty"`    // status timeline, see state.go
+
+	// retries, see retry.go
+	Attempt       int            `json:"attempt,omitempty"` // attempts started so far
+	AttemptErrors []AttemptError `json:"attempt_errors,omitempty"`
+	Retry         *RetryPolicy   `json:"retry,omitempty"`    // policy given on submission, overrides the command default
+	RetryAt       *time.Time     `json:"retry_at,omitempty"` // set while waiting for the next attempt
+	RunAt         *time.Time     `json:"run_at,omitempty"`   // delayed jobs do not run before this time
+
+	// deduplication of retried submissions, see idempotency.go
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	RequestHash    string `json:"request_hash,omitempty"`
+
+	// workflow membership, see workflow.go
+	WorkflowID string `json:"workflow_id,omitempty"`
+	StepID     string `json:"step_id,omitempty"`
+
+	// completion callback, see webhooks.go
+	CallbackURL    string            `json:"callback_url,omitempty"`
+	DeliveryStatus string            `json:"delivery_status,omitempty"`
+	Deliveries     []DeliveryAttempt `json:"deliveries,omitempty"`
+
+	enqueuedAt time.Time // last time the job entered a priority queue (not persisted)
+}
+
+// SubmitOptions carries optional submission settings.
+type SubmitOptions struct {
+	TraceID        string // trace of the submitting request; a new trace is started when empty
+	ParentSpanID   string
+	Labels         map[string]string // free-form key/value tags used to filter jobs
+	Owner          string
+	CallbackURL    string            // POSTed to when the job finishes
+	Retry          *RetryPolicy      // overrides the retry policy of the command
+	WorkflowID     string            // set on the jobs of workflow steps
+	StepID         string
+	RunAt          *time.Time // delays the first attempt
+	IdempotencyKey string     // see SubmitIdempotent
+	Actor          string     // recorded as the submitter in the history; ActorClient when empty
+
+	requestHash string
 }
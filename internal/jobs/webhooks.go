@@ -0,0 +1,301 @@
+package jobs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/tracing"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// Webhook headers sent with every delivery.
+const (
+	WebhookSignatureHeader = "X-PSO-Signature" // "sha256=" + hex HMAC of "<timestamp>.<body>"
+	WebhookTimestampHeader = "X-PSO-Timestamp" // unix seconds, part of the signed content
+	WebhookEventHeader     = "X-PSO-Event"
+	WebhookAttemptHeader   = "X-PSO-Delivery-Attempt"
+)
+
+// Delivery states of a job callback.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// maxPreviewBytes bounds the result preview included in the payload.
+const maxPreviewBytes = 1024
+
+// WebhookConfig controls how completion callbacks are delivered.
+type WebhookConfig struct {
+	Secret         string        // HMAC-SHA256 key; deliveries are unsigned when empty
+	MaxAttempts    int           // total attempts including the first one
+	InitialBackoff time.Duration // delay before the second attempt, doubled after each failure
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // per attempt
+	Client         *http.Client  // optional, mainly for tests
+}
+
+// DeliveryAttempt records one POST of a job callback.
+type DeliveryAttempt struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// WebhookPayload is the JSON body POSTed to the callback URL.
+type WebhookPayload struct {
+	Event      string            `json:"event"`
+	JobID      string            `json:"job_id"`
+	Command    string            `json:"command"`
+	Priority   Priority          `json:"priority"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Result     *ResultSummary    `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt time.Time         `json:"finished_at"`
+	QueueMs    int64             `json:"queue_ms"`
+	RunMs      int64             `json:"run_ms"`
+	TotalMs    int64             `json:"total_ms"`
+	TraceID    string            `json:"trace_id,omitempty"`
+}
+
+// ResultSummary describes the result without embedding large bodies.
+type ResultSummary struct {
+	StatusCode  int             `json:"status_code"`
+	ContentType string          `json:"content_type,omitempty"`
+	Bytes       int64           `json:"bytes"`
+	SHA256      string          `json:"sha256,omitempty"`
+	Preview     json.RawMessage `json:"preview,omitempty"` // the body itself when it is small JSON
+	URL         string          `json:"url"`
+}
+
+// ConfigureWebhooks sets the delivery settings and resumes deliveries that
+// were still pending when the server stopped.
+func (j *JobManager) ConfigureWebhooks(cfg WebhookConfig) {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	j.mu.Lock()
+	j.webhooks = cfg
+	j.webhooksOn = true
+	pending := j.pendingDeliveries
+	j.pendingDeliveries = nil
+	j.mu.Unlock()
+
+	for _, meta := range pending {
+		j.startDelivery(meta)
+	}
+}
+
+// SignWebhook returns the signature header value for body sent at timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// scheduleDelivery starts the callback of a job that just finished.
+// Must be called with j.mu held.
+func (j *JobManager) scheduleDelivery(meta *JobMeta) {
+	if meta.CallbackURL == "" || meta.DeliveryStatus != "" {
+		return
+	}
+	meta.DeliveryStatus = DeliveryPending
+	if !j.webhooksOn {
+		j.pendingDeliveries = append(j.pendingDeliveries, meta.clone())
+		return
+	}
+	j.startDelivery(meta.clone())
+}
+
+func (j *JobManager) startDelivery(meta *JobMeta) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.deliver(meta)
+	}()
+}
+
+// deliver POSTs the payload until it is accepted, a permanent error occurs or
+// the attempts run out. Every attempt is recorded on the stored job.
+func (j *JobManager) deliver(meta *JobMeta) {
+	j.mu.Lock()
+	cfg := j.webhooks
+	j.mu.Unlock()
+
+	body, _ := json.Marshal(j.webhookPayload(meta))
+	backoff := cfg.InitialBackoff
+	for attempt := len(meta.Deliveries) + 1; attempt <= cfg.MaxAttempts; attempt++ {
+		rec, retry := j.postWebhook(cfg, meta, body, attempt)
+		final := ""
+		switch {
+		case rec.Error == "" && rec.StatusCode/100 == 2:
+			final = DeliveryDelivered
+		case !retry || attempt == cfg.MaxAttempts:
+			final = DeliveryFailed
+		}
+		j.recordDelivery(meta.ID, rec, final)
+		if final != "" {
+			fields := util.Fields{"job_id": meta.ID, "url": meta.CallbackURL, "attempts": attempt, "status_code": rec.StatusCode}
+			if final == DeliveryFailed {
+				fields["error"] = rec.Error
+				util.Warn("job callback failed", fields)
+			} else {
+				util.Debug("job callback delivered", fields)
+			}
+			return
+		}
+
+		select {
+		case <-j.stop:
+			return // resumed on next start, the job is still pending
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+// postWebhook makes one attempt. retry is false for client errors that a new
+// attempt will not fix.
+func (j *JobManager) postWebhook(cfg WebhookConfig, meta *JobMeta, body []byte, attempt int) (DeliveryAttempt, bool) {
+	rec := DeliveryAttempt{Attempt: attempt, At: time.Now()}
+	req, err := http.NewRequest("POST", meta.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		rec.Error = err.Error()
+		return rec, false
+	}
+	ts := rec.At.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, "job.finished")
+	req.Header.Set(WebhookAttemptHeader, strconv.Itoa(attempt))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	if cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(cfg.Secret, ts, body))
+	}
+	if meta.TraceID != "" {
+		req.Header.Set("traceparent", tracing.FormatTraceparent(meta.TraceID, meta.SpanID, tracing.TraceFlags(meta.TraceID)))
+	}
+
+	resp, err := cfg.Client.Do(req)
+	rec.DurationMs = time.Since(rec.At).Milliseconds()
+	if err != nil {
+		rec.Error = err.Error()
+		return rec, true
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	rec.StatusCode = resp.StatusCode
+	if resp.StatusCode/100 != 2 {
+		rec.Error = fmt.Sprintf("callback answered %d", resp.StatusCode)
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == 408 || resp.StatusCode == 429
+	return rec, retry
+}
+
+// recordDelivery appends an attempt to the stored job and, when final is not
+// empty, sets its delivery status.
+func (j *JobManager) recordDelivery(id string, rec DeliveryAttempt, final string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	meta, ok := j.store[id]
+	if !ok {
+		stored, err := j.persist.Get(id)
+		if err != nil {
+			return // expired or deleted meanwhile
+		}
+		meta = stored
+	}
+	meta.Deliveries = append(meta.Deliveries, rec)
+	if final != "" {
+		meta.DeliveryStatus = final
+	}
+	if err := j.persist.Put(meta); err != nil {
+		j.recordJournalError(err)
+		util.Error("job store write failed", util.Fields{"job_id": id, "error": err.Error()})
+	}
+}
+
+func (j *JobManager) webhookPayload(meta *JobMeta) WebhookPayload {
+	p := WebhookPayload{
+		Event:      "job.finished",
+		JobID:      meta.ID,
+		Command:    meta.Command,
+		Priority:   meta.Priority,
+		Status:     meta.Status,
+		Error:      meta.Error,
+		Labels:     meta.Labels,
+		CreatedAt:  meta.CreatedAt,
+		StartedAt:  meta.StartedAt,
+		FinishedAt: meta.UpdatedAt,
+		TotalMs:    meta.UpdatedAt.Sub(meta.CreatedAt).Milliseconds(),
+		TraceID:    meta.TraceID,
+	}
+	if meta.StartedAt != nil {
+		p.QueueMs = meta.StartedAt.Sub(meta.CreatedAt).Milliseconds()
+		p.RunMs = meta.UpdatedAt.Sub(*meta.StartedAt).Milliseconds()
+	}
+	p.Result = resultSummary(meta)
+	return p
+}
+
+func resultSummary(meta *JobMeta) *ResultSummary {
+	url := "/jobs/result?id=" + meta.ID
+	if ref := meta.ResultRef; ref != nil {
+		return &ResultSummary{
+			StatusCode:  ref.StatusCode,
+			ContentType: ref.ContentType,
+			Bytes:       ref.Size,
+			SHA256:      ref.SHA256,
+			URL:         url,
+		}
+	}
+	if meta.Result == "" {
+		return nil
+	}
+	var res types.Response
+	if err := json.Unmarshal([]byte(meta.Result), &res); err != nil {
+		return nil
+	}
+	s := &ResultSummary{
+		StatusCode:  res.StatusCode,
+		ContentType: res.Headers["Content-Type"],
+		Bytes:       int64(len(res.Body)),
+		URL:         url,
+	}
+	if len(res.Body) <= maxPreviewBytes && json.Valid(res.Body) {
+		s.Preview = res.Body
+	}
+	return s
+}
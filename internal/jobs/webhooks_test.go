@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A finished job whose callback was still pending when the server stopped is
+// delivered once webhooks are configured: the first attempt gets a 500, the
+// retry is accepted, and both attempts are recorded on the job.
+func TestWebhookRetriesUntilDelivered(t *testing.T) {
+	const secret = "s3cret"
+	var calls int32
+	bodies := make(chan WebhookPayload, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhook(secret, ts, body) {
+			t.Errorf("bad signature on attempt %s", r.Header.Get(WebhookAttemptHeader))
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var p WebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+		bodies <- p
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	created := time.Now().Add(-3 * time.Second)
+	started := created.Add(time.Second)
+	store.Put(&JobMeta{
+		ID:             "job1",
+		Command:        "fibonacci",
+		Status:         StatusDone,
+		Result:         `{"StatusCode":200,"Headers":{"Content-Type":"application/json"},"Body":"eyJuIjo1fQ=="}`,
+		CreatedAt:      created,
+		StartedAt:      &started,
+		UpdatedAt:      started.Add(time.Second),
+		CallbackURL:    srv.URL,
+		DeliveryStatus: DeliveryPending,
+	})
+
+	j, err := NewJobManagerWithStore(store, 4, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.ConfigureWebhooks(WebhookConfig{Secret: secret, InitialBackoff: 10 * time.Millisecond})
+
+	select {
+	case p := <-bodies:
+		if p.JobID != "job1" || p.Status != StatusDone || p.QueueMs != 1000 || p.RunMs != 1000 {
+			t.Fatalf("unexpected payload %+v", p)
+		}
+		if p.Result == nil || string(p.Result.Preview) != `{"n":5}` {
+			t.Fatalf("unexpected result summary %+v", p.Result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		meta, err := j.GetMeta("job1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.DeliveryStatus == DeliveryDelivered {
+			if len(meta.Deliveries) != 2 || meta.Deliveries[0].StatusCode != 500 || meta.Deliveries[1].StatusCode != 200 {
+				t.Fatalf("unexpected attempts %+v", meta.Deliveries)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery status %q after timeout", meta.DeliveryStatus)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
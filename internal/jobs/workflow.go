@@ -0,0 +1,651 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+// Step states besides the job statuses mirrored from the step's job.
+const (
+	StepPending = "pending" // waiting for its dependencies
+	StepSkipped = "skipped" // a dependency failed or was canceled
+)
+
+// WorkflowRunning is the status of a workflow with steps left to finish. A
+// finished workflow is done, error or canceled.
+const WorkflowRunning = "running"
+
+var (
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	ErrInvalidWorkflow  = errors.New("invalid workflow")
+)
+
+// stepRef matches ${step.field} references in step params. field is a dot
+// separated path into the JSON result of the step, e.g. ${sort.output_file}.
+var stepRef = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+)\.([A-Za-z0-9_.-]+)\}`)
+
+// WorkflowStep is one node of a workflow DAG.
+type WorkflowStep struct {
+	ID        string            `json:"id"`
+	Command   string            `json:"command"`
+	Params    map[string]string `json:"params,omitempty"`
+	DependsOn []string          `json:"depends_on,omitempty"` // steps referenced in Params are added implicitly
+	Priority  Priority          `json:"priority,omitempty"`
+	Retry     *RetryPolicy      `json:"retry,omitempty"`
+}
+
+// WorkflowSpec is a workflow submission.
+type WorkflowSpec struct {
+	Steps    []WorkflowStep    `json:"steps"`
+	Priority Priority          `json:"priority,omitempty"` // default priority of the steps
+	Labels   map[string]string `json:"labels,omitempty"`   // added to every step job
+}
+
+// StepState is the progress of a step.
+type StepState struct {
+	WorkflowStep
+	Status     string            `json:"status"`
+	JobID      string            `json:"job_id,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Outputs    map[string]string `json:"outputs,omitempty"` // result fields referenced by later steps
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Workflow is a DAG of jobs run as their dependencies complete.
+type Workflow struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	Priority  Priority          `json:"priority"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Steps     []*StepState      `json:"steps"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	TraceID   string            `json:"trace_id,omitempty"`
+	SpanID    string            `json:"span_id,omitempty"` // parent of the step jobs' spans
+}
+
+func (w *Workflow) step(id string) *StepState {
+	for _, s := range w.Steps {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (w *Workflow) clone() *Workflow {
+	c := *w
+	c.Steps = make([]*StepState, len(w.Steps))
+	for i, s := range w.Steps {
+		sc := *s
+		if s.Outputs != nil {
+			sc.Outputs = make(map[string]string, len(s.Outputs))
+			for k, v := range s.Outputs {
+				sc.Outputs[k] = v
+			}
+		}
+		c.Steps[i] = &sc
+	}
+	return &c
+}
+
+func isStepFinished(status string) bool {
+	return isTerminal(status) || status == StepSkipped
+}
+
+// validateWorkflow checks step IDs, commands and dependencies, adds the
+// dependencies implied by references and rejects cycles.
+func validateWorkflow(spec *WorkflowSpec) error {
+	if len(spec.Steps) == 0 {
+		return fmt.Errorf("%w: no steps", ErrInvalidWorkflow)
+	}
+	ids := make(map[string]bool, len(spec.Steps))
+	for _, s := range spec.Steps {
+		if s.ID == "" || strings.ContainsAny(s.ID, ".${}") {
+			return fmt.Errorf("%w: invalid step id %q", ErrInvalidWorkflow, s.ID)
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("%w: duplicated step id %q", ErrInvalidWorkflow, s.ID)
+		}
+		ids[s.ID] = true
+	}
+
+	deps := make(map[string][]string, len(spec.Steps))
+	for i := range spec.Steps {
+		s := &spec.Steps[i]
+		switch s.Priority {
+		case "", PriorityHigh, PriorityNormal, PriorityLow:
+		default:
+			return fmt.Errorf("%w: step %q: invalid priority %q", ErrInvalidWorkflow, s.ID, s.Priority)
+		}
+		if workers.GetPool(s.Command) == nil {
+			return fmt.Errorf("%w: step %q: unknown command %q", ErrInvalidWorkflow, s.ID, s.Command)
+		}
+		if s.Retry != nil {
+			if err := s.Retry.Validate(); err != nil {
+				return fmt.Errorf("%w: step %q: %v", ErrInvalidWorkflow, s.ID, err)
+			}
+		}
+		for _, v := range s.Params {
+			for _, m := range stepRef.FindAllStringSubmatch(v, -1) {
+				if !containsString(s.DependsOn, m[1]) {
+					s.DependsOn = append(s.DependsOn, m[1])
+				}
+			}
+		}
+		for _, d := range s.DependsOn {
+			if !ids[d] {
+				return fmt.Errorf("%w: step %q depends on unknown step %q", ErrInvalidWorkflow, s.ID, d)
+			}
+			if d == s.ID {
+				return fmt.Errorf("%w: step %q depends on itself", ErrInvalidWorkflow, s.ID)
+			}
+		}
+		deps[s.ID] = s.DependsOn
+	}
+
+	// Kahn: every step must become ready at some point
+	indegree := make(map[string]int, len(deps))
+	dependents := make(map[string][]string)
+	for id, ds := range deps {
+		indegree[id] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], id)
+		}
+	}
+	ready := make([]string, 0)
+	for id, n := range indegree {
+		if n == 0 {
+			ready = append(ready, id)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		id := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		visited++
+		for _, next := range dependents[id] {
+			if indegree[next]--; indegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if visited != len(deps) {
+		return fmt.Errorf("%w: dependency cycle", ErrInvalidWorkflow)
+	}
+	return nil
+}
+
+// ConfigureWorkflows loads the workflows saved at path, resumes the ones
+// that were running and keeps the file updated from then on.
+func (j *JobManager) ConfigureWorkflows(path string) error {
+	var saved []*Workflow
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read workflows: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("decode workflows: %w", err)
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.workflowsPath = path
+	resumed := 0
+	for _, wf := range saved {
+		j.workflows[wf.ID] = wf
+		if wf.Status != WorkflowRunning {
+			continue
+		}
+		// the step jobs kept running, or were recovered, while the
+		// workflows were not loaded: catch up with their current state
+		for _, s := range wf.Steps {
+			if s.JobID == "" || isStepFinished(s.Status) {
+				continue
+			}
+			meta, ok := j.store[s.JobID]
+			if !ok {
+				if meta, err = j.persist.Get(s.JobID); err != nil {
+					s.Status, s.Error = StatusError, "job lost: "+err.Error()
+					continue
+				}
+			}
+			j.syncStepLocked(wf, s, meta)
+		}
+		j.advanceWorkflowLocked(wf)
+		resumed++
+	}
+	if resumed > 0 {
+		util.Info("workflows resumed", util.Fields{"count": resumed})
+	}
+	return j.saveWorkflowsLocked()
+}
+
+// SubmitWorkflow validates spec and starts the steps without dependencies.
+// opts carries the trace context; its Labels are merged with spec.Labels.
+func (j *JobManager) SubmitWorkflow(spec WorkflowSpec, opts SubmitOptions) (*Workflow, error) {
+	if err := validateWorkflow(&spec); err != nil {
+		return nil, err
+	}
+	if spec.Priority == "" {
+		spec.Priority = PriorityNormal
+	}
+	now := time.Now()
+	wf := &Workflow{
+		ID:        util.NewRequestID(),
+		Status:    WorkflowRunning,
+		Priority:  spec.Priority,
+		Labels:    make(map[string]string),
+		CreatedAt: now,
+		UpdatedAt: now,
+		TraceID:   opts.TraceID,
+		SpanID:    opts.ParentSpanID,
+	}
+	for k, v := range opts.Labels {
+		wf.Labels[k] = v
+	}
+	for k, v := range spec.Labels {
+		wf.Labels[k] = v
+	}
+	for _, s := range spec.Steps {
+		wf.Steps = append(wf.Steps, &StepState{WorkflowStep: s, Status: StepPending})
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, s := range wf.Steps {
+		if err := j.checkDrainLocked(s.Command, ActorClient); err != nil {
+			return nil, err
+		}
+	}
+	j.workflows[wf.ID] = wf
+	j.advanceWorkflowLocked(wf)
+	if err := j.saveWorkflowsLocked(); err != nil {
+		util.Error("workflow store write failed", util.Fields{"workflow_id": wf.ID, "error": err.Error()})
+	}
+	util.Debug("workflow submitted", util.Fields{"workflow_id": wf.ID, "steps": len(wf.Steps), "trace_id": wf.TraceID})
+	return wf.clone(), nil
+}
+
+// GetWorkflow returns a copy of the workflow with its per-step status.
+func (j *JobManager) GetWorkflow(id string) (*Workflow, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	wf, ok := j.workflows[id]
+	if !ok {
+		return nil, ErrWorkflowNotFound
+	}
+	return wf.clone(), nil
+}
+
+// CancelWorkflow cancels the steps that did not start and the step jobs in
+// flight. Returns ErrJobCancelled when the workflow already finished.
+func (j *JobManager) CancelWorkflow(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	wf, ok := j.workflows[id]
+	if !ok {
+		return ErrWorkflowNotFound
+	}
+	if wf.Status != WorkflowRunning {
+		return ErrJobCancelled
+	}
+	// pending steps first, so canceling a job cannot unblock them
+	now := time.Now()
+	for _, s := range wf.Steps {
+		if s.Status == StepPending {
+			s.Status, s.Error, s.FinishedAt = StatusCanceled, "workflow canceled", &now
+		}
+	}
+	for _, s := range wf.Steps {
+		if s.JobID != "" && !isStepFinished(s.Status) {
+			// the job's transition to canceled updates the step
+			if err := j.cancelLocked(s.JobID, ActorWorkflow); err != nil && !errors.Is(err, ErrJobCancelled) {
+				util.Warn("unable to cancel workflow step", util.Fields{"workflow_id": id, "step": s.ID, "error": err.Error()})
+			}
+		}
+	}
+	j.advanceWorkflowLocked(wf)
+	return j.saveWorkflowsLocked()
+}
+
+// onWorkflowJobLocked mirrors a status change of a step job on its workflow
+// and starts the steps it unblocks. Only a step finishing is saved: the
+// other changes are caught up from the jobs by ConfigureWorkflows.
+// Must be called with j.mu held.
+func (j *JobManager) onWorkflowJobLocked(meta *JobMeta) {
+	wf, ok := j.workflows[meta.WorkflowID]
+	if !ok {
+		return // not loaded yet, ConfigureWorkflows catches up
+	}
+	s := wf.step(meta.StepID)
+	if s == nil || isStepFinished(s.Status) {
+		return
+	}
+	j.syncStepLocked(wf, s, meta)
+	if !isTerminal(meta.Status) {
+		return
+	}
+	j.advanceWorkflowLocked(wf)
+	if err := j.saveWorkflowsLocked(); err != nil {
+		util.Error("workflow store write failed", util.Fields{"workflow_id": wf.ID, "error": err.Error()})
+	}
+}
+
+// syncStepLocked copies the state of the step's job into the step.
+// Must be called with j.mu held.
+func (j *JobManager) syncStepLocked(wf *Workflow, s *StepState, meta *JobMeta) {
+	s.JobID = meta.ID
+	s.Status = meta.Status
+	s.Error = meta.Error
+	if meta.StartedAt != nil && s.StartedAt == nil {
+		started := *meta.StartedAt
+		s.StartedAt = &started
+	}
+	wf.UpdatedAt = time.Now()
+	if !isTerminal(meta.Status) {
+		return
+	}
+	finished := meta.UpdatedAt
+	s.FinishedAt = &finished
+	if meta.Status != StatusDone {
+		return
+	}
+	if err := j.captureOutputsLocked(wf, s, meta); err != nil {
+		s.Status, s.Error = StatusError, err.Error()
+	}
+}
+
+// captureOutputsLocked keeps the fields of the step result that later steps
+// reference, so they survive the retention of the job.
+// Must be called with j.mu held.
+func (j *JobManager) captureOutputsLocked(wf *Workflow, s *StepState, meta *JobMeta) error {
+	var paths []string
+	for _, other := range wf.Steps {
+		for _, v := range other.Params {
+			for _, m := range stepRef.FindAllStringSubmatch(v, -1) {
+				if m[1] == s.ID && !containsString(paths, m[2]) {
+					paths = append(paths, m[2])
+				}
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	body, err := j.resultBodyLocked(meta)
+	if err != nil {
+		return fmt.Errorf("read step result: %w", err)
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("step result is not JSON: %w", err)
+	}
+	s.Outputs = make(map[string]string, len(paths))
+	for _, p := range paths {
+		if v, ok := lookupPath(doc, p); ok {
+			s.Outputs[p] = v
+		}
+	}
+	return nil
+}
+
+// resultBodyLocked returns the body of a finished job's result.
+// Must be called with j.mu held.
+func (j *JobManager) resultBodyLocked(meta *JobMeta) ([]byte, error) {
+	if meta.ResultRef != nil {
+		if j.blobs == nil {
+			return nil, errors.New("result not stored as blob")
+		}
+		f, err := j.blobs.Open(meta.ResultRef)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	var res types.Response
+	if err := json.Unmarshal([]byte(meta.Result), &res); err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// lookupPath walks a dot separated path of object keys and array indexes.
+// Strings are returned as is and other values as compact JSON.
+func lookupPath(doc interface{}, path string) (string, bool) {
+	cur := doc
+	for _, key := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return "", false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return "", false
+			}
+			cur = v[i]
+		default:
+			return "", false
+		}
+	}
+	if s, ok := cur.(string); ok {
+		return s, true
+	}
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// advanceWorkflowLocked skips the steps whose dependencies failed, submits
+// the steps whose dependencies are done and closes the workflow once every
+// step finished. Must be called with j.mu held.
+func (j *JobManager) advanceWorkflowLocked(wf *Workflow) {
+	if wf.Status != WorkflowRunning {
+		return
+	}
+	now := time.Now()
+	for changed := true; changed; {
+		changed = false
+		for _, s := range wf.Steps {
+			if s.Status != StepPending {
+				continue
+			}
+			ready := true
+			for _, d := range s.DependsOn {
+				dep := wf.step(d)
+				if dep.Status == StatusDone {
+					continue
+				}
+				ready = false
+				if isStepFinished(dep.Status) {
+					s.Status = StepSkipped
+					s.Error = fmt.Sprintf("dependency %q finished with status %s", d, dep.Status)
+					s.FinishedAt = &now
+					changed = true
+				}
+				break
+			}
+			if ready && j.submitStepLocked(wf, s) {
+				changed = true
+			}
+		}
+	}
+
+	status, failed := StatusDone, ""
+	for _, s := range wf.Steps {
+		switch {
+		case !isStepFinished(s.Status):
+			return
+		case s.Status == StatusError || s.Status == StatusTimeout:
+			if status != StatusError {
+				status, failed = StatusError, fmt.Sprintf("step %q: %s", s.ID, s.Error)
+			}
+		case s.Status == StatusCanceled && status == StatusDone:
+			status = StatusCanceled
+		}
+	}
+	wf.Status, wf.Error, wf.UpdatedAt = status, failed, now
+	fields := util.Fields{"workflow_id": wf.ID, "status": status, "duration_ms": now.Sub(wf.CreatedAt).Milliseconds(), "trace_id": wf.TraceID}
+	if failed != "" {
+		fields["error"] = failed
+		util.Warn("workflow finished", fields)
+	} else {
+		util.Info("workflow finished", fields)
+	}
+}
+
+// submitStepLocked resolves the references of a ready step and submits its
+// job. Returns true when the step left the pending state.
+// Must be called with j.mu held.
+func (j *JobManager) submitStepLocked(wf *Workflow, s *StepState) bool {
+	params := make(map[string]string, len(s.Params))
+	var unresolved string
+	for k, v := range s.Params {
+		params[k] = stepRef.ReplaceAllStringFunc(v, func(ref string) string {
+			m := stepRef.FindStringSubmatch(ref)
+			out, ok := wf.step(m[1]).Outputs[m[2]]
+			if !ok && unresolved == "" {
+				unresolved = ref
+			}
+			return out
+		})
+	}
+	if unresolved != "" {
+		now := time.Now()
+		s.Status, s.Error, s.FinishedAt = StatusError, "unresolved reference "+unresolved, &now
+		return true
+	}
+
+	priority := s.Priority
+	if priority == "" {
+		priority = wf.Priority
+	}
+	labels := make(map[string]string, len(wf.Labels)+1)
+	for k, v := range wf.Labels {
+		labels[k] = v
+	}
+	labels["workflow"] = wf.ID
+	_, err := j.submitLocked(s.Command, params, priority, SubmitOptions{
+		TraceID:      wf.TraceID,
+		ParentSpanID: wf.SpanID,
+		Labels:       labels,
+		Retry:        s.Retry,
+		WorkflowID:   wf.ID,
+		StepID:       s.ID,
+		Actor:        ActorWorkflow,
+	})
+	if err != nil {
+		s.Status, s.JobID = StepPending, ""
+		// queues full: the step stays pending and is tried again shortly
+		j.retryWorkflowLocked(wf, 200*time.Millisecond)
+		return false
+	}
+	return true
+}
+
+// retryWorkflowLocked advances wf again after delay, unless a retry is
+// already pending. Must be called with j.mu held.
+func (j *JobManager) retryWorkflowLocked(wf *Workflow, delay time.Duration) {
+	if _, ok := j.wfRetry[wf.ID]; ok {
+		return
+	}
+	j.wfRetry[wf.ID] = time.AfterFunc(delay, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		delete(j.wfRetry, wf.ID)
+		select {
+		case <-j.stop:
+			return
+		default:
+		}
+		j.advanceWorkflowLocked(wf)
+		if err := j.saveWorkflowsLocked(); err != nil {
+			util.Error("workflow store write failed", util.Fields{"workflow_id": wf.ID, "error": err.Error()})
+		}
+	})
+}
+
+// stopWorkflowRetriesLocked cancels the pending workflow retries.
+// Must be called with j.mu held.
+func (j *JobManager) stopWorkflowRetriesLocked() {
+	for id, t := range j.wfRetry {
+		t.Stop()
+		delete(j.wfRetry, id)
+	}
+}
+
+// expireWorkflowsLocked forgets the finished workflows whose step jobs were
+// all evicted by retention. A workflow that never submitted a job follows
+// maxAge instead. Returns how many were forgotten. Must be called with j.mu held.
+func (j *JobManager) expireWorkflowsLocked(now time.Time, maxAge time.Duration) int {
+	n := 0
+	for id, wf := range j.workflows {
+		if wf.Status == WorkflowRunning || !j.workflowExpiredLocked(wf, now, maxAge) {
+			continue
+		}
+		delete(j.workflows, id)
+		n++
+	}
+	return n
+}
+
+func (j *JobManager) workflowExpiredLocked(wf *Workflow, now time.Time, maxAge time.Duration) bool {
+	jobs := 0
+	for _, s := range wf.Steps {
+		if s.JobID == "" {
+			continue
+		}
+		jobs++
+		if _, ok := j.tombstones[s.JobID]; ok {
+			continue
+		}
+		if meta, err := j.persist.Get(s.JobID); err == nil && meta.Status != StatusExpired {
+			return false
+		}
+	}
+	if jobs == 0 {
+		return maxAge > 0 && now.Sub(wf.UpdatedAt) > maxAge
+	}
+	return true
+}
+
+// saveWorkflowsLocked rewrites the workflows file atomically with the running
+// workflows; finished ones stay in memory until retention expires them. It is
+// a no-op until ConfigureWorkflows sets the path. Must be called with j.mu held.
+func (j *JobManager) saveWorkflowsLocked() error {
+	if j.workflowsPath == "" {
+		return nil
+	}
+	list := make([]*Workflow, 0)
+	for _, wf := range j.workflows {
+		if wf.Status == WorkflowRunning {
+			list = append(list, wf)
+		}
+	}
+	return writeJSONAtomic(j.workflowsPath, list)
+}
@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/workers"
+)
+
+func waitWorkflow(t *testing.T, j *JobManager, id string) *Workflow {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		wf, err := j.GetWorkflow(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wf.Status != WorkflowRunning {
+			return wf
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("workflow still running: %+v", wf.Steps)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Outputs of a step feed the params of the next one, a failed step skips its
+// dependents, and a finished workflow is not kept in the workflows file.
+func TestWorkflowChainsOutputs(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	workers.InitPool("toupper", 2, 8)
+	path := filepath.Join(t.TempDir(), "workflows.json")
+
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ConfigureWorkflows(path); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = j.SubmitWorkflow(WorkflowSpec{Steps: []WorkflowStep{
+		{ID: "a", Command: "reverse", DependsOn: []string{"b"}},
+		{ID: "b", Command: "reverse", DependsOn: []string{"a"}},
+	}}, SubmitOptions{})
+	if !errors.Is(err, ErrInvalidWorkflow) {
+		t.Fatalf("expected a cycle to be rejected, got %v", err)
+	}
+
+	wf, err := j.SubmitWorkflow(WorkflowSpec{Steps: []WorkflowStep{
+		{ID: "rev", Command: "reverse", Params: map[string]string{"text": "abc"}},
+		{ID: "up", Command: "toupper", Params: map[string]string{"text": "${rev.output}!"}},
+		{ID: "bad", Command: "toupper", Params: map[string]string{"text": "${rev.missing}"}},
+		{ID: "after", Command: "reverse", Params: map[string]string{"text": "x"}, DependsOn: []string{"bad"}},
+	}}, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf = waitWorkflow(t, j, wf.ID)
+
+	want := map[string]string{"rev": StatusDone, "up": StatusDone, "bad": StatusError, "after": StepSkipped}
+	for _, s := range wf.Steps {
+		if s.Status != want[s.ID] {
+			t.Errorf("step %s: got %s (%s), want %s", s.ID, s.Status, s.Error, want[s.ID])
+		}
+	}
+	if wf.Status != StatusError {
+		t.Errorf("workflow status %s, want error", wf.Status)
+	}
+
+	up := wf.Steps[1]
+	meta, err := j.GetMeta(up.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Params["text"] != "cba!" || meta.WorkflowID != wf.ID || meta.Labels["workflow"] != wf.ID {
+		t.Fatalf("unexpected step job %+v", meta)
+	}
+	j.Close()
+
+	j2, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j2.Close()
+	if err := j2.ConfigureWorkflows(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := j2.GetWorkflow(wf.ID); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Fatalf("finished workflow reloaded: %v %+v", err, got)
+	}
+}
+
+// A workflow still running when the server stops is reloaded and catches up
+// with its step jobs, which recovery runs again.
+func TestWorkflowResumedAfterRestart(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	workers.InitPool("toupper", 2, 8)
+	path := filepath.Join(t.TempDir(), "workflows.json")
+	store := NewMemoryStore()
+
+	j, err := NewJobManagerWithStore(store, 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ConfigureWorkflows(path); err != nil {
+		t.Fatal(err)
+	}
+	j.PauseQueue("toupper")
+	wf, err := j.SubmitWorkflow(WorkflowSpec{Steps: []WorkflowStep{
+		{ID: "rev", Command: "reverse", Params: map[string]string{"text": "abc"}},
+		{ID: "up", Command: "toupper", Params: map[string]string{"text": "${rev.output}"}},
+	}}, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := j.GetWorkflow(wf.ID)
+		if got.Steps[1].JobID != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("second step never submitted: %+v", got.Steps)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	j.Close()
+
+	j2, err := NewJobManagerWithStore(store, 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j2.Close()
+	if err := j2.ConfigureWorkflows(path); err != nil {
+		t.Fatal(err)
+	}
+	got := waitWorkflow(t, j2, wf.ID)
+	if got.Status != StatusDone || got.Steps[1].Status != StatusDone {
+		t.Fatalf("resumed workflow = %s %+v", got.Status, got.Steps[1])
+	}
+}
+
+// Finished workflows are forgotten once retention evicted their step jobs,
+// while running ones are kept.
+func TestSweepExpiresFinishedWorkflows(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	workers.InitPool("toupper", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	done, err := j.SubmitWorkflow(WorkflowSpec{Steps: []WorkflowStep{
+		{ID: "rev", Command: "reverse", Params: map[string]string{"text": "abc"}},
+	}}, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitWorkflow(t, j, done.ID)
+	j.PauseQueue("toupper")
+	running, err := j.SubmitWorkflow(WorkflowSpec{Steps: []WorkflowStep{
+		{ID: "up", Command: "toupper", Params: map[string]string{"text": "abc"}},
+	}}, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j.ConfigureRetention(RetentionPolicy{MaxAge: time.Nanosecond})
+	time.Sleep(time.Millisecond)
+	j.Sweep()
+	if _, err := j.GetWorkflow(done.ID); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Fatalf("finished workflow kept after its jobs expired: %v", err)
+	}
+	if _, err := j.GetWorkflow(running.ID); err != nil {
+		t.Fatalf("running workflow expired: %v", err)
+	}
+}
+
+// A step refused by full queues is retried by a timer that Close stops.
+func TestCloseStopsWorkflowRetry(t *testing.T) {
+	workers.InitPool("reverse", 2, 8)
+	j, err := NewJobManagerWithStore(NewMemoryStore(), 1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.PauseQueue("reverse")
+	for {
+		if _, err := j.Submit("reverse", map[string]string{"text": "abc"}, PriorityNormal); err != nil {
+			break
+		}
+	}
+	wf, err := j.SubmitWorkflow(WorkflowSpec{Steps: []WorkflowStep{
+		{ID: "rev", Command: "reverse", Params: map[string]string{"text": "abc"}},
+	}}, SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Lock()
+	_, pending := j.wfRetry[wf.ID]
+	j.mu.Unlock()
+	if !pending {
+		t.Fatal("no retry scheduled for the refused step")
+	}
+	j.Close()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.wfRetry) != 0 {
+		t.Fatalf("retries left after Close: %d", len(j.wfRetry))
+	}
+}
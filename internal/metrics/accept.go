@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Estado del accept loop del servidor, usado por /healthz.
+var (
+	acceptLoopRunning  int32
+	lastAcceptUnixNano int64
+	acceptErrorsInARow int64
+	acceptErrorsTotal  int64
+)
+
+// AcceptLoopStarted marca que el accept loop está activo.
+func AcceptLoopStarted() {
+	atomic.StoreInt32(&acceptLoopRunning, 1)
+	atomic.StoreInt64(&lastAcceptUnixNano, time.Now().UnixNano())
+}
+
+// AcceptLoopStopped marca que el accept loop terminó.
+func AcceptLoopStopped() {
+	atomic.StoreInt32(&acceptLoopRunning, 0)
+}
+
+// AcceptSucceeded registra una conexión aceptada y reinicia la racha de errores.
+func AcceptSucceeded() {
+	atomic.StoreInt64(&lastAcceptUnixNano, time.Now().UnixNano())
+	atomic.StoreInt64(&acceptErrorsInARow, 0)
+}
+
+// AcceptFailed registra un error de Accept.
+func AcceptFailed() {
+	atomic.AddInt64(&acceptErrorsInARow, 1)
+	atomic.AddInt64(&acceptErrorsTotal, 1)
+}
+
+// AcceptLoopState es una foto del estado del accept loop.
+type AcceptLoopState struct {
+	Running           bool      `json:"running"`
+	LastAccept        time.Time `json:"last_accept"`
+	ConsecutiveErrors int64     `json:"consecutive_errors"`
+	TotalErrors       int64     `json:"total_errors"`
+}
+
+func GetAcceptLoopState() AcceptLoopState {
+	return AcceptLoopState{
+		Running:           atomic.LoadInt32(&acceptLoopRunning) == 1,
+		LastAccept:        time.Unix(0, atomic.LoadInt64(&lastAcceptUnixNano)),
+		ConsecutiveErrors: atomic.LoadInt64(&acceptErrorsInARow),
+		TotalErrors:       atomic.LoadInt64(&acceptErrorsTotal),
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultLatencyBuckets son los límites (en segundos) usados para los histogramas de latencia.
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Histogram acumula observaciones en buckets fijos, al estilo Prometheus.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // len(bounds)+1, el último es +Inf
+	sum    float64
+	count  uint64
+}
+
+// HistogramSnapshot es una copia consistente del histograma.
+// Counts es acumulativo: Counts[i] = observaciones <= Bounds[i].
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Sum    float64
+	Count  uint64
+}
+
+func NewHistogram(bounds []float64) *Histogram {
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	sort.Float64s(b)
+	return &Histogram{bounds: b, counts: make([]uint64, len(b)+1)}
+}
+
+func (h *Histogram) Observe(v float64) {
+	idx := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	h.counts[idx]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snap := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.bounds)),
+		Sum:    h.sum,
+		Count:  h.count,
+	}
+	var acc uint64
+	for i := range h.bounds {
+		acc += h.counts[i]
+		snap.Counts[i] = acc
+	}
+	return snap
+}
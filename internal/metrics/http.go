@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Etiquetas de ruta especiales, así se evita una cardinalidad ilimitada con paths arbitrarios.
+const (
+	RouteUnmatched = "unmatched" // no coincide con ninguna ruta registrada
+	RouteInvalid   = "invalid"   // el request no se pudo parsear
+)
+
+// DefaultSizeBuckets son los límites (en bytes) de los histogramas de tamaño.
+var DefaultSizeBuckets = []float64{128, 512, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+type routeKey struct {
+	route  string
+	method string
+}
+
+type routeStats struct {
+	requests  int64
+	byCode    map[int]int64
+	latency   *Histogram
+	reqSize   *Histogram
+	respSize  *Histogram
+	reqBytes  int64
+	respBytes int64
+}
+
+var (
+	httpMu        sync.Mutex
+	httpRoutes    = make(map[routeKey]*routeStats)
+	parseFailures = make(map[string]int64)
+)
+
+// ObserveRequest registra una respuesta HTTP enviada para la ruta y método indicados.
+func ObserveRequest(route, method string, code int, latency time.Duration, reqBytes, respBytes int) {
+	httpMu.Lock()
+	defer httpMu.Unlock()
+	k := routeKey{route, method}
+	st, ok := httpRoutes[k]
+	if !ok {
+		st = &routeStats{
+			byCode:   make(map[int]int64),
+			latency:  NewHistogram(DefaultLatencyBuckets),
+			reqSize:  NewHistogram(DefaultSizeBuckets),
+			respSize: NewHistogram(DefaultSizeBuckets),
+		}
+		httpRoutes[k] = st
+	}
+	st.requests++
+	st.byCode[code]++
+	st.reqBytes += int64(reqBytes)
+	st.respBytes += int64(respBytes)
+	st.latency.Observe(latency.Seconds())
+	st.reqSize.Observe(float64(reqBytes))
+	st.respSize.Observe(float64(respBytes))
+}
+
+// RecordParseFailure cuenta un request rechazado por ParseRequest según el motivo.
+func RecordParseFailure(reason string) {
+	httpMu.Lock()
+	parseFailures[reason]++
+	httpMu.Unlock()
+}
+
+// StatusClass devuelve la clase del código HTTP ("2xx", "4xx", ...).
+func StatusClass(code int) string {
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// RouteMetrics es la vista exportable de las métricas de una ruta y método.
+type RouteMetrics struct {
+	Route         string            `json:"route"`
+	Method        string            `json:"method"`
+	Requests      int64             `json:"requests"`
+	ByCode        map[int]int64     `json:"by_code"`
+	ByClass       map[string]int64  `json:"by_class"`
+	RequestBytes  int64             `json:"request_bytes"`
+	ResponseBytes int64             `json:"response_bytes"`
+	AvgLatencyMs  float64           `json:"avg_latency_ms"`
+	Latency       HistogramSnapshot `json:"-"`
+	RequestSize   HistogramSnapshot `json:"-"`
+	ResponseSize  HistogramSnapshot `json:"-"`
+}
+
+// HTTPRoutes devuelve las métricas por ruta ordenadas por ruta y método.
+func HTTPRoutes() []RouteMetrics {
+	httpMu.Lock()
+	out := make([]RouteMetrics, 0, len(httpRoutes))
+	for k, st := range httpRoutes {
+		rm := RouteMetrics{
+			Route:         k.route,
+			Method:        k.method,
+			Requests:      st.requests,
+			ByCode:        make(map[int]int64, len(st.byCode)),
+			ByClass:       make(map[string]int64),
+			RequestBytes:  st.reqBytes,
+			ResponseBytes: st.respBytes,
+			Latency:       st.latency.Snapshot(),
+			RequestSize:   st.reqSize.Snapshot(),
+			ResponseSize:  st.respSize.Snapshot(),
+		}
+		for code, n := range st.byCode {
+			rm.ByCode[code] = n
+			rm.ByClass[StatusClass(code)] += n
+		}
+		if rm.Latency.Count > 0 {
+			rm.AvgLatencyMs = rm.Latency.Sum / float64(rm.Latency.Count) * 1000
+		}
+		out = append(out, rm)
+	}
+	httpMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Route != out[j].Route {
+			return out[i].Route < out[j].Route
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// ParseFailures devuelve los rechazos de parseo por motivo.
+func ParseFailures() map[string]int64 {
+	httpMu.Lock()
+	defer httpMu.Unlock()
+	out := make(map[string]int64, len(parseFailures))
+	for k, v := range parseFailures {
+		out[k] = v
+	}
+	return out
+}
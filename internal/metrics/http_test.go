@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Las respuestas se agrupan por ruta y método, con conteos por código y por
+// clase, y los rechazos del parser se cuentan aparte por motivo.
+func TestObserveRequest(t *testing.T) {
+	// nombres únicos: los contadores son globales y sobreviven a -count
+	route := "/test/observe/" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	reason := "test_reason_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	ObserveRequest(route, "GET", 200, 2*time.Millisecond, 100, 300)
+	ObserveRequest(route, "GET", 201, 4*time.Millisecond, 100, 700)
+	ObserveRequest(route, "GET", 404, 6*time.Millisecond, 100, 1000)
+	ObserveRequest(route, "POST", 503, time.Millisecond, 2000, 50)
+	RecordParseFailure(reason)
+	RecordParseFailure(reason)
+
+	var get, post *RouteMetrics
+	for _, rm := range HTTPRoutes() {
+		rm := rm
+		if rm.Route == route && rm.Method == "GET" {
+			get = &rm
+		}
+		if rm.Route == route && rm.Method == "POST" {
+			post = &rm
+		}
+	}
+	if get == nil || post == nil {
+		t.Fatal("faltan las rutas observadas")
+	}
+	if get.Requests != 3 || get.ByCode[200] != 1 || get.ByCode[404] != 1 || get.ByClass["2xx"] != 2 || get.ByClass["4xx"] != 1 {
+		t.Fatalf("GET = %+v", get)
+	}
+	if get.RequestBytes != 300 || get.ResponseBytes != 2000 || get.AvgLatencyMs < 3.99 || get.AvgLatencyMs > 4.01 {
+		t.Fatalf("GET bytes/latencia = %+v", get)
+	}
+	if get.ResponseSize.Count != 3 || get.Latency.Count != 3 {
+		t.Fatalf("histogramas GET = %+v %+v", get.ResponseSize, get.Latency)
+	}
+	if post.Requests != 1 || post.ByClass["5xx"] != 1 {
+		t.Fatalf("POST = %+v", post)
+	}
+	if n := ParseFailures()[reason]; n != 2 {
+		t.Fatalf("parse failures = %d", n)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for code, want := range map[int]string{200: "2xx", 304: "3xx", 404: "4xx", 503: "5xx"} {
+		if got := StatusClass(code); got != want {
+			t.Errorf("StatusClass(%d) = %s, want %s", code, got, want)
+		}
+	}
+}
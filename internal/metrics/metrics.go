@@ -7,7 +7,10 @@ import (
 	"sync/atomic"
 )
 
-var totalConnections int64
+var (
+	totalConnections  int64
+	activeConnections int64
+)
 
 type PoolMetrics struct {
 	mu            sync.Mutex
@@ -15,14 +18,20 @@ type PoolMetrics struct {
 	TotalLatencyMs int64
 	Samples       []int64 // ring buffer-like (append up to cap)
 	maxSamples    int
+	latency       *Histogram
 }
 
 func NewPoolMetrics(maxSamples int) *PoolMetrics {
-	return &PoolMetrics{Samples: make([]int64, 0, maxSamples), maxSamples: maxSamples}
+	return &PoolMetrics{
+		Samples:    make([]int64, 0, maxSamples),
+		maxSamples: maxSamples,
+		latency:    NewHistogram(DefaultLatencyBuckets),
+	}
 }
 
 func (m *PoolMetrics) Record(latency time.Duration) {
 	ms := latency.Milliseconds()
+	m.latency.Observe(latency.Seconds())
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.TotalProcessed++
@@ -63,11 +72,32 @@ func (m *PoolMetrics) Percentile(p float64) int64 {
 	return cp[idx]
 }
 
+// LatencyHistogram devuelve el histograma de latencias (segundos) del pool.
+func (m *PoolMetrics) LatencyHistogram() HistogramSnapshot {
+	return m.latency.Snapshot()
+}
+
+// Processed devuelve el total de jobs procesados.
+func (m *PoolMetrics) Processed() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.TotalProcessed
+}
 
 func IncrementConnections() {
 	atomic.AddInt64(&totalConnections, 1)
+	atomic.AddInt64(&activeConnections, 1)
+}
+
+// ConnectionClosed descuenta una conexión activa.
+func ConnectionClosed() {
+	atomic.AddInt64(&activeConnections, -1)
 }
 
 func GetTotalConnections() int64 {
 	return atomic.LoadInt64(&totalConnections)
+}
+
+func GetActiveConnections() int64 {
+	return atomic.LoadInt64(&activeConnections)
 }
\ No newline at end of file
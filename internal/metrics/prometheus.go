@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PromContentType es el Content-Type del formato de exposición de texto de Prometheus.
+const PromContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PromWriter construye una respuesta en el formato de texto de Prometheus.
+// Los metadatos # HELP / # TYPE se escriben una sola vez por familia.
+type PromWriter struct {
+	buf  bytes.Buffer
+	seen map[string]bool
+}
+
+func NewPromWriter() *PromWriter {
+	return &PromWriter{seen: make(map[string]bool)}
+}
+
+// Family declara una familia de métricas (counter, gauge, histogram).
+func (w *PromWriter) Family(name, typ, help string) {
+	if w.seen[name] {
+		return
+	}
+	w.seen[name] = true
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(&w.buf, "# TYPE %s %s\n", name, typ)
+}
+
+// Sample escribe una muestra. labels se pasa como pares clave, valor.
+func (w *PromWriter) Sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	writeLabels(&w.buf, labels)
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatFloat(value))
+	w.buf.WriteByte('\n')
+}
+
+// Histogram escribe las series _bucket, _sum y _count de un histograma.
+func (w *PromWriter) Histogram(name string, snap HistogramSnapshot, labels ...string) {
+	for i, bound := range snap.Bounds {
+		w.Sample(name+"_bucket", float64(snap.Counts[i]), withLabel(labels, "le", formatFloat(bound))...)
+	}
+	w.Sample(name+"_bucket", float64(snap.Count), withLabel(labels, "le", "+Inf")...)
+	w.Sample(name+"_sum", snap.Sum, labels...)
+	w.Sample(name+"_count", float64(snap.Count), labels...)
+}
+
+func (w *PromWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// withLabel devuelve una copia de labels con el par agregado, sin alterar el slice original.
+func withLabel(labels []string, key, value string) []string {
+	out := make([]string, 0, len(labels)+2)
+	out = append(out, labels...)
+	return append(out, key, value)
+}
+
+func writeLabels(buf *bytes.Buffer, labels []string) {
+	if len(labels) < 2 {
+		return
+	}
+	buf.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(labels[i])
+		buf.WriteString(`="`)
+		buf.WriteString(escapeLabel(labels[i+1]))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+// Verifica metadatos, escape de etiquetas y buckets acumulativos del histograma
+func TestPromWriterHistogram(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	w := NewPromWriter()
+	w.Family("test_latency_seconds", "histogram", "Test latency.")
+	w.Family("test_latency_seconds", "histogram", "Test latency.")
+	w.Histogram("test_latency_seconds", h.Snapshot(), "pool", `a"b`)
+	out := string(w.Bytes())
+
+	if strings.Count(out, "# TYPE test_latency_seconds histogram") != 1 {
+		t.Errorf("TYPE debe aparecer una sola vez:\n%s", out)
+	}
+	expected := []string{
+		`test_latency_seconds_bucket{pool="a\"b",le="0.1"} 1`,
+		`test_latency_seconds_bucket{pool="a\"b",le="1"} 2`,
+		`test_latency_seconds_bucket{pool="a\"b",le="+Inf"} 3`,
+		`test_latency_seconds_sum{pool="a\"b"} 3.55`,
+		`test_latency_seconds_count{pool="a\"b"} 3`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("falta la línea %q en:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Peso del último intervalo en la tasa suavizada (media móvil exponencial).
+const rateSmoothing = 0.3
+
+// Reporter recibe el avance de un algoritmo: fracción completada en [0,1] y
+// una etiqueta de fase. Un *Reporter nil es válido y descarta todo, así los
+// algoritmos pueden llamarlo sin verificar si alguien escucha.
+type Reporter struct {
+	mu        sync.Mutex
+	fraction  float64
+	phase     string
+	startedAt time.Time
+	updatedAt time.Time
+	movedAt   time.Time // último Update que aumentó la fracción
+	rate      float64   // fracción por segundo, suavizada
+	onChange  func(Snapshot)
+}
+
+// Snapshot es el estado del avance en un instante.
+type Snapshot struct {
+	Fraction  float64
+	Phase     string
+	StartedAt time.Time
+	UpdatedAt time.Time
+	ETA       time.Duration
+	HasETA    bool // false hasta tener una tasa de avance medible
+}
+
+// New crea un reporter cuyo reloj empieza ahora.
+func New() *Reporter {
+	now := time.Now()
+	return &Reporter{startedAt: now, updatedAt: now, movedAt: now}
+}
+
+// Update registra la fracción completada del total y la fase actual. Una fase
+// vacía conserva la anterior; fracciones fuera de [0,1] se recortan y el
+// avance nunca retrocede.
+func (r *Reporter) Update(fraction float64, phase string) {
+	if r == nil {
+		return
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	prevPercent, prevPhase := int(r.fraction*100), r.phase
+	if phase != "" {
+		r.phase = phase
+	}
+	if fraction < r.fraction {
+		fraction = r.fraction
+	}
+
+	if dt := now.Sub(r.movedAt).Seconds(); dt > 0 && fraction > r.fraction {
+		inst := (fraction - r.fraction) / dt
+		if r.rate == 0 {
+			r.rate = inst
+		} else {
+			r.rate = rateSmoothing*inst + (1-rateSmoothing)*r.rate
+		}
+		r.movedAt = now
+	}
+	r.fraction = fraction
+	r.updatedAt = now
+	fn := r.onChange
+	changed := int(fraction*100) != prevPercent || r.phase != prevPhase
+	r.mu.Unlock()
+	if fn != nil && changed {
+		fn(r.Snapshot())
+	}
+}
+
+// OnChange registra fn para ser llamada cuando el avance cruza un punto
+// porcentual entero o cambia la fase. fn corre en la goroutine del algoritmo.
+func (r *Reporter) OnChange(fn func(Snapshot)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.onChange = fn
+	r.mu.Unlock()
+}
+
+// Phase cambia la fase sin modificar la fracción.
+func (r *Reporter) Phase(phase string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	changed := r.phase != phase
+	r.phase = phase
+	fn := r.onChange
+	r.mu.Unlock()
+	if fn != nil && changed {
+		fn(r.Snapshot())
+	}
+}
+
+// Snapshot devuelve el avance y una ETA estimada con la tasa suavizada; si
+// todavía no hay tasa, con la tasa promedio desde el inicio.
+func (r *Reporter) Snapshot() Snapshot {
+	if r == nil {
+		return Snapshot{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Snapshot{
+		Fraction:  r.fraction,
+		Phase:     r.phase,
+		StartedAt: r.startedAt,
+		UpdatedAt: r.updatedAt,
+	}
+	rate := r.rate
+	if rate <= 0 {
+		if elapsed := time.Since(r.startedAt).Seconds(); elapsed > 0 {
+			rate = r.fraction / elapsed
+		}
+	}
+	if r.fraction >= 1 {
+		s.HasETA = true
+	} else if rate > 0 {
+		s.ETA = time.Duration((1 - r.fraction) / rate * float64(time.Second))
+		s.HasETA = true
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+// Verifica que un reporter nil no falle y que el avance no retroceda
+func TestReporterNilAndMonotonic(t *testing.T) {
+	var nilRep *Reporter
+	nilRep.Update(0.5, "x")
+	nilRep.Phase("y")
+	if s := nilRep.Snapshot(); s.Fraction != 0 || s.HasETA {
+		t.Fatalf("reporter nil debería devolver un snapshot vacío: %+v", s)
+	}
+
+	r := New()
+	time.Sleep(10 * time.Millisecond)
+	r.Update(0.5, "reading")
+	r.Update(0.2, "sorting")
+	s := r.Snapshot()
+	if s.Fraction != 0.5 || s.Phase != "sorting" {
+		t.Fatalf("avance inesperado: %+v", s)
+	}
+	if !s.HasETA || s.ETA <= 0 {
+		t.Fatalf("se esperaba una ETA positiva: %+v", s)
+	}
+}
@@ -0,0 +1,437 @@
+// Package remote implementa el modo worker: un proceso que se registra en un
+// manager, pide jobs de los comandos que soporta por long polling, los ejecuta
+// con los mismos algoritmos que los pools locales y devuelve el resultado.
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/jobs"
+	"github.com/EngSteven/pso-http-server/internal/progress"
+	"github.com/EngSteven/pso-http-server/internal/types"
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// errUnregistered indica que el manager no conoce al worker (expiró o se
+// reinició) y hay que registrarse de nuevo.
+var errUnregistered = errors.New("worker not registered in the manager")
+
+// Config define a qué manager se conecta el worker y qué ejecuta.
+type Config struct {
+	ManagerURL  string        // p. ej. http://localhost:8080
+	Name        string        // nombre informativo en /workers del manager
+	Commands    []string      // comandos que acepta
+	Concurrency int           // jobs en paralelo
+	PollTimeout time.Duration // duración de cada long poll
+	Token       string        // X-Worker-Token, si el manager lo exige
+	Client      *http.Client  // opcional, para tests
+}
+
+// Job es un job en ejecución en este worker.
+type Job struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+	Progress  float64   `json:"progress"`
+
+	cancel chan struct{}
+	rep    *progress.Reporter
+	sent   time.Time // UpdatedAt del último avance enviado
+}
+
+// Status resume el estado del worker.
+type Status struct {
+	WorkerID  string   `json:"worker_id"`
+	Manager   string   `json:"manager"`
+	Commands  []string `json:"commands"`
+	Running   []*Job   `json:"running"`
+	Completed int64    `json:"completed"`
+	Abandoned int64    `json:"abandoned"` // jobs que el manager pidió abandonar
+	LastError string   `json:"last_error,omitempty"`
+}
+
+// Worker ejecuta jobs de un manager remoto.
+type Worker struct {
+	cfg    Config
+	client *http.Client
+	regMu  sync.Mutex // un solo registro a la vez entre los loops
+
+	mu        sync.Mutex
+	id        string
+	heartbeat time.Duration
+	running   map[string]*Job
+	completed int64
+	abandoned int64
+	lastErr   string
+}
+
+// New crea un worker; Run lo pone a trabajar.
+func New(cfg Config) *Worker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.PollTimeout <= 0 {
+		cfg.PollTimeout = 30 * time.Second
+	}
+	cfg.ManagerURL = strings.TrimRight(cfg.ManagerURL, "/")
+	client := cfg.Client
+	if client == nil {
+		// el long poll puede tardar PollTimeout en responder
+		client = &http.Client{Timeout: cfg.PollTimeout + 10*time.Second}
+	}
+	return &Worker{cfg: cfg, client: client, running: make(map[string]*Job)}
+}
+
+// Run registra el worker y atiende jobs hasta que stop se cierra. Los jobs en
+// curso se cancelan al salir; el manager los vuelve a encolar cuando vence su
+// lease.
+func (w *Worker) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.heartbeatLoop(stop)
+	}()
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.pollLoop(stop)
+		}()
+	}
+	<-stop
+	w.mu.Lock()
+	for _, job := range w.running {
+		close(job.cancel)
+	}
+	w.running = make(map[string]*Job)
+	w.mu.Unlock()
+	wg.Wait()
+}
+
+// Status devuelve una copia del estado del worker.
+func (w *Worker) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := Status{
+		WorkerID:  w.id,
+		Manager:   w.cfg.ManagerURL,
+		Commands:  w.cfg.Commands,
+		Running:   make([]*Job, 0, len(w.running)),
+		Completed: w.completed,
+		Abandoned: w.abandoned,
+		LastError: w.lastErr,
+	}
+	for _, job := range w.running {
+		cp := *job
+		cp.Progress = job.rep.Snapshot().Fraction
+		st.Running = append(st.Running, &cp)
+	}
+	sort.Slice(st.Running, func(a, b int) bool { return st.Running[a].StartedAt.Before(st.Running[b].StartedAt) })
+	return st
+}
+
+// workerID devuelve el id vigente, registrándose si hace falta.
+func (w *Worker) workerID() (string, error) {
+	w.regMu.Lock()
+	defer w.regMu.Unlock()
+	w.mu.Lock()
+	id := w.id
+	w.mu.Unlock()
+	if id != "" {
+		return id, nil
+	}
+
+	q := url.Values{}
+	q.Set("name", w.cfg.Name)
+	q.Set("commands", strings.Join(w.cfg.Commands, ","))
+	q.Set("capacity", strconv.Itoa(w.cfg.Concurrency))
+	var reg struct {
+		WorkerID    string `json:"worker_id"`
+		HeartbeatMs int64  `json:"heartbeat_ms"`
+	}
+	if err := w.call("GET", "/workers/register", q, nil, &reg); err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.id = reg.WorkerID
+	w.heartbeat = time.Duration(reg.HeartbeatMs) * time.Millisecond
+	util.Info("worker registrado en el manager", util.Fields{"worker_id": w.id, "manager": w.cfg.ManagerURL, "commands": w.cfg.Commands})
+	return w.id, nil
+}
+
+// forget descarta un registro que el manager ya no reconoce; sus jobs se
+// abandonan porque el manager los volvió a encolar.
+func (w *Worker) forget(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.id != id {
+		return
+	}
+	w.id = ""
+	for jobID, job := range w.running {
+		close(job.cancel)
+		delete(w.running, jobID)
+		w.abandoned++
+	}
+}
+
+func (w *Worker) recordError(err error) {
+	w.mu.Lock()
+	w.lastErr = err.Error()
+	w.mu.Unlock()
+	util.Warn("error hablando con el manager", util.Fields{"manager": w.cfg.ManagerURL, "error": err.Error()})
+}
+
+// pause espera d o hasta que stop se cierre; devuelve false en el segundo caso.
+func pause(stop <-chan struct{}, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (w *Worker) pollLoop(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		id, err := w.workerID()
+		if err != nil {
+			w.recordError(err)
+			if !pause(stop, time.Second) {
+				return
+			}
+			continue
+		}
+
+		q := url.Values{}
+		q.Set("worker", id)
+		q.Set("timeout_ms", strconv.FormatInt(w.cfg.PollTimeout.Milliseconds(), 10))
+		var lease jobs.Lease
+		err = w.call("GET", "/workers/poll", q, nil, &lease)
+		switch {
+		case errors.Is(err, errUnregistered):
+			w.forget(id)
+			continue
+		case err != nil:
+			w.recordError(err)
+			if !pause(stop, time.Second) {
+				return
+			}
+			continue
+		case lease.JobID == "":
+			continue // 204: no hubo trabajo
+		}
+		w.execute(id, &lease)
+	}
+}
+
+// execute corre el job del lease y reporta el resultado, salvo que el
+// manager haya pedido abandonarlo.
+func (w *Worker) execute(workerID string, lease *jobs.Lease) {
+	job := &Job{
+		ID:        lease.JobID,
+		Command:   lease.Command,
+		StartedAt: time.Now(),
+		cancel:    make(chan struct{}),
+		rep:       progress.New(),
+	}
+	w.mu.Lock()
+	w.running[job.ID] = job
+	w.mu.Unlock()
+	util.Debug("job remoto iniciado", util.Fields{"job_id": job.ID, "command": job.Command})
+
+	res := jobs.RunCommand(lease.Command, lease.Params, job.cancel, job.rep)
+
+	w.mu.Lock()
+	_, still := w.running[job.ID]
+	delete(w.running, job.ID)
+	w.mu.Unlock()
+	if !still {
+		return // abandonado: cancelado, vencido o reasignado
+	}
+	if err := w.complete(workerID, job.ID, res); err != nil {
+		w.recordError(err)
+		return
+	}
+	w.mu.Lock()
+	w.completed++
+	w.mu.Unlock()
+}
+
+// complete envía el resultado; si el manager lo rechaza por tamaño u otro
+// error del request, reporta el job como fallido para no perderlo.
+func (w *Worker) complete(workerID, jobID string, res *types.Response) error {
+	if res == nil {
+		res = &types.Response{StatusCode: 500, Body: []byte(`{"error":"nil response"}`)}
+	}
+	if res.Stream != nil {
+		var buf bytes.Buffer
+		if err := res.Stream(&buf); err != nil {
+			return err
+		}
+		res.Body = buf.Bytes()
+	}
+	q := url.Values{}
+	q.Set("worker", workerID)
+	q.Set("job", jobID)
+	q.Set("status_code", strconv.Itoa(res.StatusCode))
+	q.Set("content_type", res.Headers["Content-Type"])
+	err := w.call("POST", "/workers/complete", q, res.Body, nil)
+	var se *statusError
+	if errors.As(err, &se) && (se.code == 400 || se.code == 413) {
+		msg, _ := json.Marshal(map[string]string{"error": "result rejected by the manager: " + se.Error()})
+		q.Set("status_code", "500")
+		q.Set("content_type", "application/json")
+		err = w.call("POST", "/workers/complete", q, msg, nil)
+	}
+	return err
+}
+
+// heartbeatLoop renueva los leases de los jobs en curso, abandona los que el
+// manager pide y envía el avance de cada job.
+func (w *Worker) heartbeatLoop(stop <-chan struct{}) {
+	for {
+		w.mu.Lock()
+		every := w.heartbeat
+		w.mu.Unlock()
+		if every <= 0 {
+			every = time.Second // aún sin registrar
+		}
+		if !pause(stop, every) {
+			return
+		}
+		w.mu.Lock()
+		id := w.id
+		ids := make([]string, 0, len(w.running))
+		for jobID := range w.running {
+			ids = append(ids, jobID)
+		}
+		w.mu.Unlock()
+		if id == "" {
+			continue
+		}
+
+		q := url.Values{}
+		q.Set("worker", id)
+		q.Set("jobs", strings.Join(ids, ","))
+		var hb struct {
+			Stop []string `json:"stop"`
+		}
+		err := w.call("GET", "/workers/heartbeat", q, nil, &hb)
+		if errors.Is(err, errUnregistered) {
+			w.forget(id)
+			continue
+		}
+		if err != nil {
+			w.recordError(err)
+			continue
+		}
+		w.abandon(hb.Stop)
+		w.sendProgress(id)
+	}
+}
+
+func (w *Worker) abandon(ids []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, jobID := range ids {
+		if job, ok := w.running[jobID]; ok {
+			close(job.cancel)
+			delete(w.running, jobID)
+			w.abandoned++
+			util.Info("job abandonado a pedido del manager", util.Fields{"job_id": jobID})
+		}
+	}
+}
+
+// sendProgress envía el avance de los jobs que cambiaron desde el último envío.
+func (w *Worker) sendProgress(workerID string) {
+	type update struct {
+		id   string
+		snap progress.Snapshot
+	}
+	var updates []update
+	w.mu.Lock()
+	for _, job := range w.running {
+		snap := job.rep.Snapshot()
+		if snap.UpdatedAt.After(job.sent) {
+			job.sent = snap.UpdatedAt
+			updates = append(updates, update{job.ID, snap})
+		}
+	}
+	w.mu.Unlock()
+	for _, u := range updates {
+		q := url.Values{}
+		q.Set("worker", workerID)
+		q.Set("job", u.id)
+		q.Set("fraction", strconv.FormatFloat(u.snap.Fraction, 'f', 4, 64))
+		q.Set("phase", u.snap.Phase)
+		if err := w.call("GET", "/workers/progress", q, nil, nil); err != nil {
+			util.Debug("no se pudo enviar el avance", util.Fields{"job_id": u.id, "error": err.Error()})
+		}
+	}
+}
+
+// statusError es una respuesta del manager con código inesperado.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("manager answered %d: %s", e.code, strings.TrimSpace(e.body))
+}
+
+// call hace un request al manager y decodifica el JSON de la respuesta en out.
+// Un 204 deja out sin tocar; un 404 del protocolo es errUnregistered.
+func (w *Worker) call(method, path string, q url.Values, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, w.cfg.ManagerURL+path+"?"+q.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if w.cfg.Token != "" {
+		req.Header.Set("X-Worker-Token", w.cfg.Token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil
+	case resp.StatusCode == http.StatusNotFound && path != "/workers/register":
+		return errUnregistered
+	case resp.StatusCode != http.StatusOK:
+		return &statusError{code: resp.StatusCode, body: string(data)}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -1,22 +1,52 @@
 package router
 
-import "github.com/EngSteven/pso-http-server/internal/types"
+import (
+	"strings"
+
+	"github.com/EngSteven/pso-http-server/internal/types"
+)
 
 type Router struct {
-	routes map[string]types.HandlerFunc
+	routes   map[string]types.HandlerFunc
+	prefixes map[string]types.HandlerFunc
 }
 
 func NewRouter() *Router {
-	return &Router{routes: make(map[string]types.HandlerFunc)}
+	return &Router{
+		routes:   make(map[string]types.HandlerFunc),
+		prefixes: make(map[string]types.HandlerFunc),
+	}
 }
 
 func (r *Router) Handle(path string, handler types.HandlerFunc) {
 	r.routes[path] = handler
 }
 
+// HandlePrefix registra un handler para todos los paths que empiezan con prefix
+// (por ejemplo "/debug/traces/" para "/debug/traces/{id}").
+func (r *Router) HandlePrefix(prefix string, handler types.HandlerFunc) {
+	r.prefixes[prefix] = handler
+}
+
 func (r *Router) Match(path string) types.HandlerFunc {
+	h, _ := r.MatchRoute(path)
+	return h
+}
+
+// MatchRoute devuelve el handler y el patrón de ruta que coincidió. Las rutas
+// exactas tienen prioridad; entre prefijos gana el más largo.
+func (r *Router) MatchRoute(path string) (types.HandlerFunc, string) {
 	if h, ok := r.routes[path]; ok {
-		return h
+		return h, path
+	}
+	best := ""
+	for prefix := range r.prefixes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best != "" {
+		return r.prefixes[best], best + "*"
 	}
-	return nil
+	return nil, ""
 }
@@ -2,41 +2,84 @@ package server
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/EngSteven/pso-http-server/internal/types"
 )
 
+// Motivos de rechazo de ParseRequest, usados como etiqueta en las métricas.
+const (
+	ParseReasonRead        = "read_error"
+	ParseReasonRequestLine = "malformed_request_line"
+	ParseReasonVersion     = "unsupported_version"
+	ParseReasonMethod      = "method_not_allowed"
+	ParseReasonURL         = "invalid_url"
+	ParseReasonBody        = "invalid_body"
+)
+
+// ErrNoRequest indica que el cliente cerró la conexión sin enviar ningún byte,
+// como hacen los health checks de un balanceador. No es un request inválido.
+var ErrNoRequest = errors.New("conexión cerrada sin request")
+
+// MaxBodyBytes limita el cuerpo aceptado en un POST.
+const MaxBodyBytes = 1 << 20
+
+// ParseError describe por qué un request fue rechazado.
+type ParseError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseError(reason, format string, args ...any) error {
+	return &ParseError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
 func ParseRequest(reader *bufio.Reader) (*types.Request, error) {
 	line, err := reader.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil, ErrNoRequest
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error leyendo request line: %v", err)
+		return nil, parseError(ParseReasonRead, "error leyendo request line: %v", err)
 	}
+	size := len(line)
 	line = strings.TrimSpace(line)
 
 	parts := strings.Split(line, " ")
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("línea inválida: %s", line)
+		return nil, parseError(ParseReasonRequestLine, "línea inválida: %s", line)
 	}
 
 	method, target, version := parts[0], parts[1], parts[2]
 	if version != "HTTP/1.0" && version != "HTTP/1.1" {
-		return nil, fmt.Errorf("versión no soportada: %s", version)
+		return nil, parseError(ParseReasonVersion, "versión no soportada: %s", version)
 	}
-	if method != "GET" {
-		return nil, fmt.Errorf("solo se soporta GET")
+	if method != "GET" && method != "POST" {
+		return nil, parseError(ParseReasonMethod, "solo se soporta GET y POST")
 	}
 
 	u, err := url.Parse(target)
 	if err != nil {
-		return nil, fmt.Errorf("URL inválida: %v", err)
+		return nil, parseError(ParseReasonURL, "URL inválida: %v", err)
 	}
 
 	headers := make(map[string]string)
 	for {
 		line, err := reader.ReadString('\n')
+		size += len(line)
 		if err != nil {
 			break
 		}
@@ -53,11 +96,32 @@ func ParseRequest(reader *bufio.Reader) (*types.Request, error) {
 		headers[strings.ToLower(key)] = value
 	}
 
+	// cuerpo: solo con Content-Length, no se soporta chunked
+	var body []byte
+	if cl, ok := headers["content-length"]; ok {
+		n, err := strconv.Atoi(cl)
+		if err != nil || n < 0 {
+			return nil, parseError(ParseReasonBody, "Content-Length inválido: %s", cl)
+		}
+		if n > MaxBodyBytes {
+			return nil, parseError(ParseReasonBody, "cuerpo demasiado grande: %d bytes", n)
+		}
+		body = make([]byte, n)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return nil, parseError(ParseReasonBody, "error leyendo cuerpo: %v", err)
+		}
+		size += n
+	}
+
 	req := &types.Request{
-		Method:  method,
-		Path:    u.Path,
-		Query:   u.Query(),
-		Headers: headers,
+		Method:   method,
+		Path:     u.Path,
+		RawQuery: u.RawQuery,
+		Proto:    version,
+		Query:    u.Query(),
+		Headers:  headers,
+		Body:     body,
+		Size:     size,
 	}
 	return req, nil
 }
@@ -2,14 +2,17 @@ package server
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/EngSteven/pso-http-server/internal/metrics"
 	"github.com/EngSteven/pso-http-server/internal/router"
+	"github.com/EngSteven/pso-http-server/internal/tracing"
+	"github.com/EngSteven/pso-http-server/internal/types"
 	"github.com/EngSteven/pso-http-server/internal/util"
 )
 
@@ -30,14 +33,18 @@ func (s *Server) Start() error {
 	if err != nil {
 		return fmt.Errorf("error al iniciar servidor: %v", err)
 	}
-	log.Printf("Servidor escuchando en %s", s.Address)
+	util.Info("servidor escuchando", util.Fields{"address": s.Address})
+	metrics.AcceptLoopStarted()
+	defer metrics.AcceptLoopStopped()
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("Error al aceptar conexión: %v", err)
+			metrics.AcceptFailed()
+			util.Error("error al aceptar conexión", util.Fields{"error": err.Error()})
 			continue
 		}
+		metrics.AcceptSucceeded()
 
 		// 🔹 Incrementa contador global sin crear ciclo
 		metrics.IncrementConnections()
@@ -48,48 +55,109 @@ func (s *Server) Start() error {
 
 func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
+	defer metrics.ConnectionClosed()
 
 	start := time.Now()
 	reader := bufio.NewReader(conn)
 
 	request, err := ParseRequest(reader)
+	if errors.Is(err, ErrNoRequest) {
+		return // cierre limpio: no se cuenta ni se registra
+	}
 	if err != nil {
 		response := NewResponse(400, "Bad Request", "text/plain", []byte("400 Bad Request"))
-		conn.Write(response.Bytes())
-		log.Printf("[ERROR] parse request: %v", err)
+		n, _ := conn.Write(response.Bytes())
+		reason := ParseReasonRead
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			reason = perr.Reason
+		}
+		metrics.RecordParseFailure(reason)
+		metrics.ObserveRequest(metrics.RouteInvalid, "UNKNOWN", response.StatusCode, time.Since(start), 0, n)
+		util.Warn("request inválido", util.Fields{
+			"reason":      reason,
+			"error":       err.Error(),
+			"client_addr": conn.RemoteAddr().String(),
+		})
 		return
 	}
 
+	parsedAt := time.Now()
 	request.ID = util.NewRequestID()
+	request.RemoteAddr = conn.RemoteAddr().String()
+
+	handler, route := s.Router.MatchRoute(request.Path)
+	if handler == nil {
+		route = metrics.RouteUnmatched
+	}
+
+	// span raíz del request; continúa la traza del cliente si envió traceparent
+	span := tracing.StartTrace(request.Headers["traceparent"], request.Method+" "+route, start)
+	span.SetAttr("http.method", request.Method)
+	span.SetAttr("http.route", route)
+	span.SetAttr("http.target", request.Path)
+	span.SetAttr("request.id", request.ID)
+	tracing.RecordSpan(span.TraceID, span.SpanID, "http.parse", start, parsedAt, nil)
+	request.TraceID = span.TraceID
+	request.SpanID = span.SpanID
 
-	handler := s.Router.Match(request.Path)
 	if handler == nil {
 		response := NewResponse(404, "Not Found", "text/plain", []byte("404 Not Found"))
 		response.Headers["X-Request-Id"] = request.ID
 		response.Headers["X-Worker-Pid"] = fmt.Sprint(os.Getpid())
-		conn.Write(response.Bytes())
-		log.Printf("[%s] %s %s -> 404 (%.2f ms)", request.ID, request.Method, request.Path, time.Since(start).Seconds()*1000)
+		response.Headers["traceparent"] = span.Traceparent()
+		n, _ := conn.Write(response.Bytes())
+		span.SetAttr("http.status_code", "404")
+		span.End()
+		metrics.ObserveRequest(metrics.RouteUnmatched, request.Method, response.StatusCode, time.Since(start), request.Size, n)
+		logAccess(request, response, route, start, n)
 		return
 	}
 
+	handlerSpan := tracing.StartSpan(span.TraceID, span.SpanID, "http.handler")
 	response := handler(request)
+	handlerSpan.End()
 
 	if response.Headers == nil {
 		response.Headers = make(map[string]string)
 	}
 	response.Headers["X-Request-Id"] = request.ID
 	response.Headers["X-Worker-Pid"] = fmt.Sprint(os.Getpid())
+	response.Headers["traceparent"] = span.Traceparent()
 
-	conn.Write(response.Bytes())
+	writeSpan := tracing.StartSpan(span.TraceID, span.SpanID, "http.write")
+	written, err := response.WriteTo(conn)
+	if err != nil {
+		writeSpan.SetAttr("error", err.Error())
+		util.Warn("error al escribir respuesta", util.Fields{"request_id": request.ID, "error": err.Error()})
+	}
+	writeSpan.End()
+	n := int(written)
 
 	duration := time.Since(start)
-	log.Printf("[%s] %s %s -> %d (%s) [PID=%d] [%.2f ms]",
-		request.ID,
-		request.Method,
-		request.Path,
-		response.StatusCode,
-		response.StatusText,
-		os.Getpid(),
-		duration.Seconds()*1000,
-	)
+	span.SetAttr("http.status_code", strconv.Itoa(response.StatusCode))
+	span.End()
+	metrics.ObserveRequest(route, request.Method, response.StatusCode, duration, request.Size, n)
+	logAccess(request, response, route, start, n)
+}
+
+// logAccess emite la entrada del access log con los campos del request y la respuesta.
+func logAccess(req *types.Request, resp *types.Response, route string, start time.Time, written int) {
+	util.Access(util.AccessEntry{
+		Time:       start,
+		RequestID:  req.ID,
+		JobID:      resp.Headers["X-Job-Id"],
+		TraceID:    req.TraceID,
+		ClientAddr: req.RemoteAddr,
+		Method:     req.Method,
+		Path:       req.Path,
+		RawQuery:   req.RawQuery,
+		Proto:      req.Proto,
+		Route:      route,
+		Status:     resp.StatusCode,
+		Bytes:      written,
+		Duration:   time.Since(start),
+		Referer:    req.Headers["referer"],
+		UserAgent:  req.Headers["user-agent"],
+	})
 }
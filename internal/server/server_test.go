@@ -1,9 +1,14 @@
 package server
 
 import (
+	"bufio"
+	"errors"
 	"net"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/metrics"
 )
 
 // Test básico: el servidor responde correctamente por TCP
@@ -42,3 +47,43 @@ func TestHelloServer(t *testing.T) {
 		t.Error("No se recibió respuesta del servidor")
 	}
 }
+
+// Un POST con Content-Length entrega el cuerpo; uno más grande que el límite se rechaza.
+func TestParseRequestBody(t *testing.T) {
+	raw := "POST /workflows/submit HTTP/1.0\r\nContent-Length: 11\r\n\r\n{\"steps\":1}"
+	req, err := ParseRequest(bufio.NewReader(strings.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if req.Method != "POST" || string(req.Body) != `{"steps":1}` || req.Size != len(raw) {
+		t.Fatalf("request mal parseado: %+v", req)
+	}
+
+	raw = "POST / HTTP/1.0\r\nContent-Length: 99999999\r\n\r\n"
+	_, err = ParseRequest(bufio.NewReader(strings.NewReader(raw)))
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Reason != ParseReasonBody {
+		t.Fatalf("se esperaba %s, se obtuvo %v", ParseReasonBody, err)
+	}
+}
+
+// Una conexión que se cierra sin enviar nada no es un request inválido; una
+// request line cortada sí.
+func TestParseRequestEmptyConnection(t *testing.T) {
+	if _, err := ParseRequest(bufio.NewReader(strings.NewReader(""))); !errors.Is(err, ErrNoRequest) {
+		t.Fatalf("se esperaba ErrNoRequest, se obtuvo %v", err)
+	}
+	_, err := ParseRequest(bufio.NewReader(strings.NewReader("GET /hel")))
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Reason != ParseReasonRead {
+		t.Fatalf("se esperaba %s, se obtuvo %v", ParseReasonRead, err)
+	}
+
+	before := metrics.ParseFailures()[ParseReasonRead]
+	server, client := net.Pipe()
+	client.Close()
+	(&Server{}).handleConnection(server)
+	if got := metrics.ParseFailures()[ParseReasonRead]; got != before {
+		t.Fatalf("el cierre limpio se contó como fallo de parseo: %d -> %d", before, got)
+	}
+}
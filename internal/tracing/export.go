@@ -0,0 +1,232 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EngSteven/pso-http-server/internal/util"
+)
+
+// ServiceName se reporta como atributo service.name del recurso OTLP.
+var ServiceName = "pso-http-server"
+
+// Exporter envía lotes de spans terminados a un destino externo.
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+// FileExporter agrega cada lote como una línea de OTLP/JSON al archivo indicado.
+type FileExporter struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (e *FileExporter) Export(spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f, err := os.OpenFile(e.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(ToOTLP(spans), '\n'))
+	return err
+}
+
+// HTTPExporter envía cada lote por POST a un collector OTLP/HTTP
+// (por ejemplo http://localhost:4318/v1/traces).
+type HTTPExporter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (e *HTTPExporter) Export(spans []SpanData) error {
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Post(e.URL, "application/json", bytes.NewReader(ToOTLP(spans)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector respondió %d", resp.StatusCode)
+	}
+	return nil
+}
+
+const (
+	batchSize     = 128
+	flushInterval = 5 * time.Second
+)
+
+var (
+	exportMu  sync.Mutex
+	exporters []Exporter
+	pending   chan SpanData
+)
+
+// AddExporter registra un exporter y arranca el procesador de lotes la primera vez.
+func AddExporter(e Exporter) {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	exporters = append(exporters, e)
+	if pending == nil {
+		pending = make(chan SpanData, 4*batchSize)
+		go batchLoop(pending)
+	}
+}
+
+// HasExporters indica si hay algún destino configurado.
+func HasExporters() bool {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	return len(exporters) > 0
+}
+
+// ExportNow envía los spans a todos los exporters de forma sincrónica.
+func ExportNow(spans []SpanData) error {
+	exportMu.Lock()
+	list := append([]Exporter(nil), exporters...)
+	exportMu.Unlock()
+	var firstErr error
+	for _, e := range list {
+		if err := e.Export(spans); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// exportSpan encola un span terminado sin bloquear; si la cola está llena se descarta.
+func exportSpan(s SpanData) {
+	exportMu.Lock()
+	ch := pending
+	exportMu.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- s:
+	default:
+	}
+}
+
+func batchLoop(ch chan SpanData) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := ExportNow(batch); err != nil {
+			util.Warn("trace export failed", util.Fields{"spans": len(batch), "error": err.Error()})
+		}
+		batch = make([]SpanData, 0, batchSize)
+	}
+	for {
+		select {
+		case s := <-ch:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// --- Codificación OTLP/JSON (ExportTraceServiceRequest) ---
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// span kinds de OTLP
+const (
+	kindInternal = 1
+	kindServer   = 2
+)
+
+// ToOTLP codifica los spans como un ExportTraceServiceRequest en JSON.
+func ToOTLP(spans []SpanData) []byte {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scope.Scope.Name = ServiceName + "/tracing"
+	for _, s := range spans {
+		kind := kindInternal
+		if s.ParentID == "" || s.Attributes["http.route"] != "" {
+			kind = kindServer
+		}
+		scope.Spans = append(scope.Spans, otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        toAttrs(s.Attributes),
+		})
+	}
+	rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	rs.Resource.Attributes = []otlpAttr{{Key: "service.name", Value: otlpValue{ServiceName}}}
+	b, _ := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{rs}})
+	return b
+}
+
+func toAttrs(m map[string]string) []otlpAttr {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpAttr, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, otlpAttr{Key: k, Value: otlpValue{m[k]}})
+	}
+	return out
+}
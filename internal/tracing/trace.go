@@ -0,0 +1,302 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span es una etapa con tiempo de inicio y fin dentro de una traza.
+type Span struct {
+	mu         sync.Mutex
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Flags      string // trace flags W3C en hex ("01" = sampled)
+	Name       string
+	Start      time.Time
+	EndTime    time.Time
+	Attributes map[string]string
+}
+
+// SpanData es una copia inmutable de un span, lista para serializar.
+type SpanData struct {
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	DurationMs float64           `json:"duration_ms"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Trace agrupa los spans que comparten un trace ID.
+type Trace struct {
+	ID    string     `json:"trace_id"`
+	Spans []SpanData `json:"spans"`
+}
+
+// TraceSummary es la vista resumida usada en el listado de trazas recientes.
+type TraceSummary struct {
+	ID         string    `json:"trace_id"`
+	Root       string    `json:"root"`
+	Start      time.Time `json:"start"`
+	DurationMs float64   `json:"duration_ms"`
+	Spans      int       `json:"spans"`
+}
+
+type traceEntry struct {
+	flags string
+	spans []*Span
+}
+
+// sampledFlags son los flags de una traza iniciada aquí.
+const sampledFlags = "01"
+
+var (
+	storeMu   sync.Mutex
+	traces    = make(map[string]*traceEntry)
+	order     []string
+	maxTraces = 256
+)
+
+// SetBufferSize fija cuántas trazas recientes se conservan en memoria.
+func SetBufferSize(n int) {
+	if n <= 0 {
+		return
+	}
+	storeMu.Lock()
+	maxTraces = n
+	evictLocked()
+	storeMu.Unlock()
+}
+
+func evictLocked() {
+	for len(order) > maxTraces {
+		delete(traces, order[0])
+		order = order[1:]
+	}
+}
+
+func register(s *Span) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	e, ok := traces[s.TraceID]
+	if !ok {
+		e = &traceEntry{flags: s.Flags}
+		traces[s.TraceID] = e
+		order = append(order, s.TraceID)
+		evictLocked()
+	}
+	// los spans hijos heredan los flags con los que empezó la traza
+	s.Flags = e.flags
+	e.spans = append(e.spans, s)
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// StartTrace inicia el span raíz de un request. Si traceparent es válido
+// se continúa esa traza; en otro caso se crea una nueva.
+func StartTrace(traceparent, name string, start time.Time) *Span {
+	traceID, parentID, flags, ok := ParseTraceparent(traceparent)
+	if !ok {
+		traceID, parentID, flags = newID(16), "", sampledFlags
+	}
+	return startSpan(traceID, parentID, flags, name, start)
+}
+
+// StartSpan inicia un span hijo de parentID dentro de la traza traceID.
+func StartSpan(traceID, parentID, name string) *Span {
+	return startSpan(traceID, parentID, sampledFlags, name, time.Now())
+}
+
+// RecordSpan registra un span ya terminado, útil para etapas medidas a posteriori
+// (por ejemplo el tiempo de espera en una cola).
+func RecordSpan(traceID, parentID, name string, start, end time.Time, attrs map[string]string) {
+	if traceID == "" {
+		return
+	}
+	s := startSpan(traceID, parentID, sampledFlags, name, start)
+	for k, v := range attrs {
+		s.SetAttr(k, v)
+	}
+	s.EndAt(end)
+}
+
+// startSpan crea y registra un span. flags solo cuenta si el span abre una
+// traza nueva en el buffer; si la traza ya existe se heredan los suyos.
+func startSpan(traceID, parentID, flags, name string, start time.Time) *Span {
+	s := &Span{
+		TraceID:    traceID,
+		SpanID:     newID(8),
+		ParentID:   parentID,
+		Flags:      flags,
+		Name:       name,
+		Start:      start,
+		Attributes: make(map[string]string),
+	}
+	register(s)
+	return s
+}
+
+// SetAttr agrega un atributo al span. Es seguro llamarlo sobre un span nil.
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// End cierra el span con la hora actual.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt cierra el span en el instante indicado. Solo el primer cierre cuenta.
+func (s *Span) EndAt(t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.EndTime.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.EndTime = t
+	s.mu.Unlock()
+	exportSpan(s.Data())
+}
+
+// Traceparent devuelve el header W3C que identifica a este span.
+func (s *Span) Traceparent() string {
+	return FormatTraceparent(s.TraceID, s.SpanID, s.Flags)
+}
+
+// TraceFlags devuelve los flags de la traza traceID, o sampled si ya no está
+// en memoria.
+func TraceFlags(traceID string) string {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if e, ok := traces[traceID]; ok {
+		return e.flags
+	}
+	return sampledFlags
+}
+
+// Data devuelve una copia del span. Un span abierto se reporta con fin = ahora.
+func (s *Span) Data() SpanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	end := s.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	attrs := make(map[string]string, len(s.Attributes))
+	for k, v := range s.Attributes {
+		attrs[k] = v
+	}
+	return SpanData{
+		TraceID:    s.TraceID,
+		SpanID:     s.SpanID,
+		ParentID:   s.ParentID,
+		Name:       s.Name,
+		Start:      s.Start,
+		End:        end,
+		DurationMs: float64(end.Sub(s.Start).Microseconds()) / 1000,
+		Attributes: attrs,
+	}
+}
+
+// Get devuelve la traza con sus spans ordenados por inicio.
+func Get(id string) (*Trace, bool) {
+	storeMu.Lock()
+	e, ok := traces[strings.ToLower(id)]
+	var spans []*Span
+	if ok {
+		spans = append(spans, e.spans...)
+	}
+	storeMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	t := &Trace{ID: strings.ToLower(id), Spans: make([]SpanData, 0, len(spans))}
+	for _, s := range spans {
+		t.Spans = append(t.Spans, s.Data())
+	}
+	sort.SliceStable(t.Spans, func(a, b int) bool { return t.Spans[a].Start.Before(t.Spans[b].Start) })
+	return t, true
+}
+
+// Recent devuelve un resumen de las trazas más recientes primero.
+func Recent(limit int) []TraceSummary {
+	storeMu.Lock()
+	ids := make([]string, len(order))
+	copy(ids, order)
+	storeMu.Unlock()
+
+	out := make([]TraceSummary, 0, limit)
+	for i := len(ids) - 1; i >= 0 && len(out) < limit; i-- {
+		t, ok := Get(ids[i])
+		if !ok || len(t.Spans) == 0 {
+			continue
+		}
+		sum := TraceSummary{ID: t.ID, Root: t.Spans[0].Name, Start: t.Spans[0].Start, Spans: len(t.Spans)}
+		end := t.Spans[0].End
+		for _, sp := range t.Spans {
+			if sp.End.After(end) {
+				end = sp.End
+			}
+		}
+		sum.DurationMs = float64(end.Sub(sum.Start).Microseconds()) / 1000
+		out = append(out, sum)
+	}
+	return out
+}
+
+// ParseTraceparent valida un header W3C traceparent (versión 00) y devuelve
+// el trace ID, el span padre y los trace flags.
+func ParseTraceparent(h string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(strings.ToLower(h)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", "", "", false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", "", false
+	}
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) {
+		return "", "", "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", "", "", false
+	}
+	return traceID, parentID, flags, true
+}
+
+// FormatTraceparent arma un header traceparent con los flags indicados; sin
+// flags se marca como sampled.
+func FormatTraceparent(traceID, spanID, flags string) string {
+	if flags == "" {
+		flags = sampledFlags
+	}
+	return fmt.Sprintf("00-%s-%s-%s", traceID, spanID, flags)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+)
+
+// Verifica que un traceparent válido se continúe y que uno inválido genere una traza nueva
+func TestStartTraceHonorsTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	span := StartTrace(tp, "GET /test", time.Now())
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentID != "00f067aa0ba902b7" {
+		t.Fatalf("no se continuó la traza: %+v", span)
+	}
+	span.End()
+
+	if _, ok := Get(span.TraceID); !ok {
+		t.Fatal("la traza no quedó registrada")
+	}
+
+	for _, bad := range []string{"", "00-0000-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		s := StartTrace(bad, "GET /test", time.Now())
+		if s.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentID != "" || len(s.TraceID) != 32 {
+			t.Errorf("traceparent %q debió iniciar una traza nueva: %+v", bad, s)
+		}
+	}
+}
+
+// Verifica que los trace flags entrantes se conserven en el span raíz, en sus hijos y en el traceparent
+func TestTraceparentKeepsFlags(t *testing.T) {
+	const tp = "00-5bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+	span := StartTrace(tp, "GET /test", time.Now())
+	defer span.End()
+	if span.Flags != "00" {
+		t.Fatalf("flags del span raíz = %q", span.Flags)
+	}
+	if got, want := span.Traceparent(), "00-5bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanID+"-00"; got != want {
+		t.Fatalf("traceparent = %s, se esperaba %s", got, want)
+	}
+	child := StartSpan(span.TraceID, span.SpanID, "child")
+	child.End()
+	if child.Flags != "00" || TraceFlags(span.TraceID) != "00" {
+		t.Fatalf("el span hijo no heredó los flags: %q", child.Flags)
+	}
+
+	fresh := StartTrace("", "GET /test", time.Now())
+	fresh.End()
+	if tp := fresh.Traceparent(); tp[len(tp)-3:] != "-01" {
+		t.Fatalf("una traza nueva debe quedar sampled: %s", tp)
+	}
+}
@@ -3,15 +3,23 @@ package types
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"net/url"
 )
 
 type Request struct {
-	Method  string
-	Path    string
-	Query   url.Values
-	Headers map[string]string
-	ID      string
+	Method     string
+	Path       string
+	RawQuery   string
+	Proto      string
+	Query      url.Values
+	Headers    map[string]string
+	Body       []byte // cuerpo de un POST, nil si no se envió
+	ID         string
+	Size       int    // bytes leídos del request line, headers y cuerpo
+	TraceID    string // traza W3C a la que pertenece el request
+	SpanID     string // span raíz del request en el servidor
+	RemoteAddr string // dirección del cliente (host:puerto)
 }
 
 type Response struct {
@@ -19,6 +27,9 @@ type Response struct {
 	StatusText string
 	Headers    map[string]string
 	Body       []byte
+	// Stream, si no es nil, escribe el cuerpo directamente en la conexión en
+	// lugar de Body; el handler debe fijar Content-Length.
+	Stream func(w io.Writer) error `json:"-"`
 }
 
 type HandlerFunc func(req *Request) *Response
@@ -34,3 +45,30 @@ func (r *Response) Bytes() []byte {
 	buf.Write(r.Body)
 	return buf.Bytes()
 }
+
+// WriteTo escribe la respuesta en w, usando Stream para el cuerpo cuando está definido.
+func (r *Response) WriteTo(w io.Writer) (int64, error) {
+	if r.Stream == nil {
+		n, err := w.Write(r.Bytes())
+		return int64(n), err
+	}
+	cw := &countingWriter{w: w}
+	head := *r
+	head.Body = nil
+	if _, err := cw.Write(head.Bytes()); err != nil {
+		return cw.n, err
+	}
+	err := r.Stream(cw)
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,144 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formatos soportados para el access log.
+const (
+	AccessLogOff      = "off"
+	AccessLogJSON     = "json"     // una línea JSON por request, vía el logger estructurado
+	AccessLogCombined = "combined" // formato Apache combined
+)
+
+// AccessEntry describe un request HTTP atendido.
+type AccessEntry struct {
+	Time       time.Time
+	RequestID  string
+	JobID      string
+	TraceID    string
+	ClientAddr string
+	Method     string
+	Path       string
+	RawQuery   string
+	Proto      string
+	Route      string
+	Status     int
+	Bytes      int
+	Duration   time.Duration
+	Referer    string
+	UserAgent  string
+}
+
+var (
+	accessMu     sync.Mutex
+	accessFormat = AccessLogJSON
+	accessOut    io.Writer // nil = usa el logger estructurado
+)
+
+// ConfigureAccessLog define el formato y el destino del access log.
+// Con out == nil las entradas JSON salen por el logger principal.
+func ConfigureAccessLog(format string, out io.Writer) {
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	switch strings.ToLower(format) {
+	case AccessLogOff, AccessLogCombined:
+		accessFormat = strings.ToLower(format)
+	default:
+		accessFormat = AccessLogJSON
+	}
+	accessOut = out
+}
+
+// Access registra un request atendido según el formato configurado.
+func Access(e AccessEntry) {
+	accessMu.Lock()
+	format, out := accessFormat, accessOut
+	accessMu.Unlock()
+
+	switch format {
+	case AccessLogOff:
+		return
+	case AccessLogCombined:
+		line := combinedLine(e)
+		writeAccess(out, func(w io.Writer) { fmt.Fprintln(w, line) })
+		return
+	}
+
+	fields := Fields{
+		"request_id":  e.RequestID,
+		"route":       e.Route,
+		"method":      e.Method,
+		"path":        e.Path,
+		"status":      e.Status,
+		"bytes":       e.Bytes,
+		"duration_ms": float64(e.Duration.Microseconds()) / 1000,
+		"client_addr": e.ClientAddr,
+	}
+	if e.JobID != "" {
+		fields["job_id"] = e.JobID
+	}
+	if e.TraceID != "" {
+		fields["trace_id"] = e.TraceID
+	}
+	if e.UserAgent != "" {
+		fields["user_agent"] = e.UserAgent
+	}
+	if out == nil {
+		Info("access", fields)
+		return
+	}
+	entry := logEntry{Time: e.Time.Format(time.RFC3339Nano), Level: LevelInfo, Message: "access", Fields: fields}
+	writeAccess(out, func(w io.Writer) { writeJSONLine(w, entry) })
+}
+
+// writeAccess escribe una entrada en out, o en la salida del logger si out es
+// nil, con el mismo lock que protege a ese destino para no intercalar líneas.
+func writeAccess(out io.Writer, write func(w io.Writer)) {
+	if out == nil {
+		mu.Lock()
+		defer mu.Unlock()
+		write(output)
+		return
+	}
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	write(out)
+}
+
+// combinedLine arma: %h - - [%t] "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func combinedLine(e AccessEntry) string {
+	host := e.ClientAddr
+	if i := strings.LastIndex(host, ":"); i > 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		host = "-"
+	}
+	target := e.Path
+	if e.RawQuery != "" {
+		target += "?" + e.RawQuery
+	}
+	size := "-"
+	if e.Bytes > 0 {
+		size = fmt.Sprint(e.Bytes)
+	}
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s "%s" "%s"`,
+		host,
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, target, orDash(e.Proto),
+		e.Status, size,
+		orDash(e.Referer), orDash(e.UserAgent),
+	)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
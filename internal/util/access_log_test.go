@@ -0,0 +1,76 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEntry = AccessEntry{
+	Time:       time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC),
+	RequestID:  "req-1",
+	ClientAddr: "10.0.0.7:51234",
+	Method:     "GET",
+	Path:       "/jobs/status",
+	RawQuery:   "id=abc",
+	Proto:      "HTTP/1.0",
+	Route:      "/jobs/status",
+	Status:     200,
+	Bytes:      42,
+	Duration:   1500 * time.Microsecond,
+	UserAgent:  `curl "8"`,
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	var buf bytes.Buffer
+	ConfigureAccessLog(AccessLogCombined, &buf)
+	defer ConfigureAccessLog(AccessLogJSON, nil)
+
+	Access(testEntry)
+	want := `10.0.0.7 - - [05/Mar/2024:14:07:09 +0000] "GET /jobs/status?id=abc HTTP/1.0" 200 42 "-" "curl \"8\""` + "\n"
+	if buf.String() != want {
+		t.Fatalf("línea combined:\n got %q\nwant %q", buf.String(), want)
+	}
+
+	// sin destino propio va a la salida del logger
+	var out bytes.Buffer
+	SetOutput(&out)
+	defer SetOutput(os.Stdout)
+	ConfigureAccessLog(AccessLogCombined, nil)
+	Access(testEntry)
+	if out.String() != want {
+		t.Fatalf("salida del logger = %q", out.String())
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	ConfigureAccessLog(AccessLogJSON, &buf)
+	defer ConfigureAccessLog(AccessLogJSON, nil)
+
+	Access(testEntry)
+	var entry struct {
+		Time    string         `json:"time"`
+		Message string         `json:"message"`
+		Fields  map[string]any `json:"fields"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	if entry.Message != "access" || !strings.HasPrefix(entry.Time, "2024-03-05T14:07:09") {
+		t.Fatalf("entrada = %+v", entry)
+	}
+	if entry.Fields["status"] != float64(200) || entry.Fields["duration_ms"] != 1.5 || entry.Fields["request_id"] != "req-1" {
+		t.Fatalf("campos = %v", entry.Fields)
+	}
+
+	ConfigureAccessLog(AccessLogOff, &buf)
+	buf.Reset()
+	Access(testEntry)
+	if buf.Len() != 0 {
+		t.Fatalf("con off se escribió %q", buf.String())
+	}
+}
@@ -3,7 +3,9 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -11,11 +13,22 @@ import (
 type LogLevel string
 
 const (
+	LevelDebug LogLevel = "DEBUG"
 	LevelInfo  LogLevel = "INFO"
 	LevelWarn  LogLevel = "WARN"
 	LevelError LogLevel = "ERROR"
 )
 
+var levelRank = map[LogLevel]int{
+	LevelDebug: 0,
+	LevelInfo:  1,
+	LevelWarn:  2,
+	LevelError: 3,
+}
+
+// Fields son los campos estructurados que acompañan a un mensaje.
+type Fields map[string]any
+
 type logEntry struct {
 	Time    string   `json:"time"`
 	Level   LogLevel `json:"level"`
@@ -25,11 +38,16 @@ type logEntry struct {
 
 var (
 	mu       sync.Mutex
-	logLevel = LevelInfo
+	logLevel           = LevelInfo
+	output   io.Writer = os.Stdout
 )
 
 func SetLogLevel(level string) {
-	switch level {
+	mu.Lock()
+	defer mu.Unlock()
+	switch strings.ToLower(level) {
+	case "debug":
+		logLevel = LevelDebug
 	case "warn":
 		logLevel = LevelWarn
 	case "error":
@@ -39,9 +57,26 @@ func SetLogLevel(level string) {
 	}
 }
 
+// SetOutput cambia el destino de los logs (por defecto stdout).
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	output = w
+	mu.Unlock()
+}
+
+// Enabled indica si un mensaje del nivel dado se emitiría.
+func Enabled(level LogLevel) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return levelRank[level] >= levelRank[logLevel]
+}
+
 func Log(level LogLevel, msg string, fields any) {
 	mu.Lock()
 	defer mu.Unlock()
+	if levelRank[level] < levelRank[logLevel] {
+		return
+	}
 
 	entry := logEntry{
 		Time:    time.Now().Format(time.RFC3339Nano),
@@ -50,10 +85,15 @@ func Log(level LogLevel, msg string, fields any) {
 		Fields:  fields,
 	}
 
+	writeJSONLine(output, entry)
+}
+
+func writeJSONLine(w io.Writer, entry logEntry) {
 	data, _ := json.Marshal(entry)
-	fmt.Fprintln(os.Stdout, string(data))
+	fmt.Fprintln(w, string(data))
 }
 
+func Debug(msg string, fields any) { Log(LevelDebug, msg, fields) }
 func Info(msg string, fields any)  { Log(LevelInfo, msg, fields) }
 func Warn(msg string, fields any)  { Log(LevelWarn, msg, fields) }
 func Error(msg string, fields any) { Log(LevelError, msg, fields) }
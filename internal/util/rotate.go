@@ -0,0 +1,143 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile es un io.Writer sobre un archivo que rota por tamaño y/o tiempo.
+// Los archivos rotados se renombran como <path>.<timestamp> y se conservan
+// según MaxBackups y MaxAge.
+type RotatingFile struct {
+	Path       string
+	MaxBytes   int64         // 0 = sin límite de tamaño
+	Interval   time.Duration // 0 = sin rotación por tiempo
+	MaxBackups int           // 0 = sin límite de cantidad
+	MaxAge     time.Duration // 0 = sin límite de antigüedad
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// OpenRotatingFile abre (o crea) el archivo de log y devuelve el writer.
+func OpenRotatingFile(path string, maxBytes int64, interval time.Duration, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	r := &RotatingFile{Path: path, MaxBytes: maxBytes, Interval: interval, MaxBackups: maxBackups, MaxAge: maxAge}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	if dir := filepath.Dir(r.Path); dir != "" {
+		os.MkdirAll(dir, 0755)
+	}
+	f, err := os.OpenFile(r.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) shouldRotate(next int64) bool {
+	if r.MaxBytes > 0 && r.size > 0 && r.size+next > r.MaxBytes {
+		return true
+	}
+	return r.Interval > 0 && time.Since(r.openedAt) >= r.Interval
+}
+
+// Rotate fuerza una rotación inmediata.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	backup := fmt.Sprintf("%s.%s", r.Path, time.Now().Format("20060102-150405.000000000"))
+	if err := os.Rename(r.Path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune elimina los respaldos que exceden MaxBackups o MaxAge.
+func (r *RotatingFile) prune() {
+	matches, err := filepath.Glob(r.Path + ".*")
+	if err != nil {
+		return
+	}
+	prefix := r.Path + "."
+	backups := matches[:0]
+	for _, m := range matches {
+		if strings.HasPrefix(m, prefix) {
+			backups = append(backups, m)
+		}
+	}
+	// el sufijo es un timestamp ordenable: el más nuevo queda al final
+	sort.Strings(backups)
+	for i, b := range backups {
+		tooMany := r.MaxBackups > 0 && i < len(backups)-r.MaxBackups
+		tooOld := false
+		if r.MaxAge > 0 {
+			if info, err := os.Stat(b); err == nil && time.Since(info.ModTime()) > r.MaxAge {
+				tooOld = true
+			}
+		}
+		if tooMany || tooOld {
+			os.Remove(b)
+		}
+	}
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
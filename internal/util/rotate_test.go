@@ -0,0 +1,80 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	m, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Al superar MaxBytes el archivo actual pasa a ser un respaldo y se sigue
+// escribiendo en uno nuevo.
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := OpenRotatingFile(path, 10, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Write([]byte("12345678\n"))
+	if n := len(backups(t, path)); n != 0 {
+		t.Fatalf("rotó antes de llegar al límite: %d respaldos", n)
+	}
+	r.Write([]byte("abcdefgh\n"))
+	b := backups(t, path)
+	if len(b) != 1 {
+		t.Fatalf("respaldos = %v, se esperaba 1", b)
+	}
+	if data, _ := os.ReadFile(b[0]); string(data) != "12345678\n" {
+		t.Fatalf("respaldo = %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "abcdefgh\n" {
+		t.Fatalf("archivo actual = %q", data)
+	}
+}
+
+// prune conserva solo los MaxBackups respaldos más nuevos y borra los que
+// superan MaxAge.
+func TestRotatingFilePrunesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := OpenRotatingFile(path, 0, 0, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i := 0; i < 4; i++ {
+		r.Write([]byte("x\n"))
+		if err := r.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b := backups(t, path); len(b) != 2 {
+		t.Fatalf("respaldos = %v, se esperaban 2", b)
+	}
+
+	r.MaxBackups = 0
+	r.MaxAge = time.Hour
+	old := backups(t, path)
+	past := time.Now().Add(-2 * time.Hour)
+	for _, b := range old {
+		os.Chtimes(b, past, past)
+	}
+	r.Write([]byte("y\n"))
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	b := backups(t, path)
+	if len(b) != 1 || b[0] == old[0] || b[0] == old[1] {
+		t.Fatalf("respaldos = %v, solo debía quedar el nuevo", b)
+	}
+}
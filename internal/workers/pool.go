@@ -169,13 +169,28 @@ func (p *Pool) Info() PoolInfo {
 		Workers:        p.workers,
 		BusyWorkers:    atomic.LoadInt32(&p.busy),
 		QueueLength:    len(p.queue),
-		TotalProcessed: p.metrics.TotalProcessed,
+		TotalProcessed: p.metrics.Processed(),
 		AvgLatencyMs:   p.metrics.AvgLatencyMs(),
 		P50Ms:          p.metrics.Percentile(50),
 		P95Ms:          p.metrics.Percentile(95),
 	}
 }
 
+// Name devuelve el nombre del pool.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// QueueCapacity devuelve la capacidad máxima de la cola del pool.
+func (p *Pool) QueueCapacity() int {
+	return cap(p.queue)
+}
+
+// LatencyHistogram devuelve el histograma de latencias de ejecución del pool.
+func (p *Pool) LatencyHistogram() metrics.HistogramSnapshot {
+	return p.metrics.LatencyHistogram()
+}
+
 func GetPoolInfo(name string) (*PoolInfo, error) {
 	p := GetPool(name)
 	if p == nil {